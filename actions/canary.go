@@ -0,0 +1,252 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	haproxy "../proxy"
+)
+
+// promotedWeight is the weight of the color that remains after a promotion
+const promotedWeight = 100
+
+// CanaryChange describes a change of the weights of the colors of a service. Only one of ColorWeights,
+// Color with Step, and Promote is used, in that order.
+type CanaryChange struct {
+	ServiceName  string
+	ColorWeights []ColorWeight
+	Color        string
+	Step         int
+	Promote      string
+}
+
+type Canaryable interface {
+	Executable
+	GetColorWeights() []ColorWeight
+	IsRuntime() bool
+	IsReloaded() bool
+	GetConfigChange() ConfigChange
+}
+
+// Canary changes the weights of the colors of a service. When the colors do not change, the weights are set
+// through the HAProxy runtime API and the proxy is not reloaded. Otherwise, or when the runtime API fails, it is reloaded.
+type Canary struct {
+	BaseReconfigure
+	CanaryChange
+	colorWeights []ColorWeight
+	runtime      bool
+	reloaded     bool
+	configChange ConfigChange
+}
+
+var NewCanary = func(baseData BaseReconfigure, change CanaryChange) Canaryable {
+	return &Canary{BaseReconfigure: baseData, CanaryChange: change}
+}
+
+// Execute changes the weights, writes the configuration of the service, and applies the weights to the running proxy
+func (m *Canary) Execute(args []string) error {
+	action, sameColors, err := m.updateConfigs()
+	if err != nil {
+		return err
+	}
+	sr := action.ServiceReconfigure
+	if sameColors && isSwarm(sr.Mode) {
+		if err := setRuntimeWeights(&sr); err != nil {
+			logPrintf("Could not set the weights through the runtime API. The proxy will be reloaded.\n%s", err.Error())
+		} else {
+			m.runtime = true
+		}
+	}
+	if !m.runtime {
+		reloaded, err := haproxy.Instance.Reload()
+		if err != nil {
+			return err
+		}
+		m.reloaded = reloaded
+	}
+	if len(m.ConsulAddresses) > 0 || !isSwarm(sr.Mode) {
+		if err := action.putToConsul(m.ConsulAddresses, sr, m.InstanceName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateConfigs changes the weights of the service and writes its configuration. The service is read and written
+// while holding mu so that concurrent changes of the service are not lost.
+// It returns the reconfiguration of the service and whether the colors stayed the same.
+func (m *Canary) updateConfigs() (*Reconfigure, bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	sr, ok := getService(m.ServiceName)
+	if !ok {
+		return nil, false, fmt.Errorf("The service %s is not configured", m.ServiceName)
+	}
+	if len(sr.ColorWeights) == 0 {
+		return nil, false, fmt.Errorf("The service %s does not have weighted colors", m.ServiceName)
+	}
+	weights, err := m.getWeights(sr.ColorWeights)
+	if err != nil {
+		return nil, false, err
+	}
+	if errs := validateColorWeights(weights); len(errs) > 0 {
+		return nil, false, errors.New(errs[0].Message)
+	}
+	for _, cw := range weights {
+		if indexOfColor(sr.ColorWeights, cw.Color) < 0 {
+			return nil, false, fmt.Errorf("The color %s is not deployed. New colors must be added through the reconfigure request", cw.Color)
+		}
+	}
+	sameColors := hasSameColors(sr.ColorWeights, weights)
+	sr.ColorWeights = weights
+	action := &Reconfigure{BaseReconfigure: m.BaseReconfigure, ServiceReconfigure: sr}
+	if err := action.writeConfigs(); err != nil {
+		return nil, false, err
+	}
+	m.configChange = action.configChange
+	m.colorWeights = weights
+	return action, sameColors, nil
+}
+
+// GetColorWeights returns the weights of the colors after the change
+func (m *Canary) GetColorWeights() []ColorWeight {
+	return m.colorWeights
+}
+
+// IsRuntime returns whether the weights were applied through the runtime API without a reload
+func (m *Canary) IsRuntime() bool {
+	return m.runtime
+}
+
+// IsReloaded returns whether the proxy was reloaded by the last execution
+func (m *Canary) IsReloaded() bool {
+	return m.reloaded
+}
+
+// GetConfigChange returns the proxy configuration before and after the last execution
+func (m *Canary) GetConfigChange() ConfigChange {
+	return m.configChange
+}
+
+func (m *Canary) getWeights(current []ColorWeight) ([]ColorWeight, error) {
+	switch {
+	case len(m.ColorWeights) > 0:
+		return m.ColorWeights, nil
+	case len(m.Color) > 0:
+		return shiftWeights(current, m.Color, m.Step)
+	case len(m.Promote) > 0:
+		if indexOfColor(current, m.Promote) < 0 {
+			return nil, fmt.Errorf("The service %s does not have the color %s", m.ServiceName, m.Promote)
+		}
+		return []ColorWeight{{Color: m.Promote, Weight: promotedWeight}}, nil
+	}
+	return nil, fmt.Errorf("The colorWeights, color, or promote parameter is mandatory")
+}
+
+// shiftWeights moves step from the other colors to the color. The total weight does not change and
+// the other colors keep their proportions. A negative step moves the weight back to the other colors.
+func shiftWeights(current []ColorWeight, color string, step int) ([]ColorWeight, error) {
+	index := indexOfColor(current, color)
+	if index < 0 {
+		return nil, fmt.Errorf("The color %s is not one of the colors of the service", color)
+	}
+	total := 0
+	for _, cw := range current {
+		total += cw.Weight
+	}
+	target := current[index].Weight + step
+	if target < 0 {
+		target = 0
+	} else if target > total {
+		target = total
+	}
+	others := total - current[index].Weight
+	remaining := total - target
+	weights := make([]ColorWeight, len(current))
+	copy(weights, current)
+	weights[index].Weight = target
+	assigned, last := 0, -1
+	for i := range weights {
+		if i == index {
+			continue
+		}
+		if others > 0 {
+			weights[i].Weight = current[i].Weight * remaining / others
+		} else {
+			weights[i].Weight = remaining / (len(weights) - 1)
+		}
+		assigned += weights[i].Weight
+		last = i
+	}
+	if last >= 0 {
+		weights[last].Weight += remaining - assigned
+	}
+	return weights, nil
+}
+
+// setRuntimeWeights sets the weights of the servers of all the colors through the HAProxy runtime API
+func setRuntimeWeights(sr *ServiceReconfigure) error {
+	backends := []string{fmt.Sprintf("%s-be", sr.AclName)}
+	if sr.HttpsPort > 0 {
+		backends = append(backends, fmt.Sprintf("https-%s-be", sr.AclName))
+	}
+	for _, backend := range backends {
+		for _, cw := range sr.ColorWeights {
+			for _, server := range getColorServers(sr, cw.Color) {
+				if err := haproxy.Instance.SetServerWeight(backend, server, cw.Weight); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasSameColors(current, weights []ColorWeight) bool {
+	if len(current) != len(weights) {
+		return false
+	}
+	for _, cw := range weights {
+		if indexOfColor(current, cw.Color) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOfColor(weights []ColorWeight, color string) int {
+	for i, cw := range weights {
+		if cw.Color == color {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseColorWeights parses comma separated color:weight pairs (e.g. blue:90,green:10)
+func ParseColorWeights(value string) ([]ColorWeight, error) {
+	weights := []ColorWeight{}
+	for _, entry := range strings.Split(value, ",") {
+		colorWeight := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(colorWeight) != 2 {
+			return nil, fmt.Errorf("The color weight %s must have the format color:weight", entry)
+		}
+		weight, err := strconv.Atoi(colorWeight[1])
+		if err != nil {
+			return nil, fmt.Errorf("The weight %s of the color %s must be a number", colorWeight[1], colorWeight[0])
+		}
+		weights = append(weights, ColorWeight{Color: colorWeight[0], Weight: weight})
+	}
+	return weights, nil
+}
+
+// FormatColorWeights returns the weights in the format accepted by ParseColorWeights
+func FormatColorWeights(weights []ColorWeight) string {
+	entries := []string{}
+	for _, cw := range weights {
+		entries = append(entries, fmt.Sprintf("%s:%d", cw.Color, cw.Weight))
+	}
+	return strings.Join(entries, ",")
+}
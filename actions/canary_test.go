@@ -0,0 +1,230 @@
+// +build !integration
+
+package actions
+
+import (
+	haproxy "../proxy"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type CanaryTestSuite struct {
+	suite.Suite
+	dir       string
+	proxyMock *ProxyMock
+	proxyOrig haproxy.Proxy
+	base      BaseReconfigure
+}
+
+func (s *CanaryTestSuite) SetupTest() {
+	s.dir, _ = ioutil.TempDir("", "canary")
+	services = map[string]ServiceReconfigure{}
+	s.proxyOrig = haproxy.Instance
+	s.proxyMock = getProxyMock("")
+	haproxy.Instance = s.proxyMock
+	s.base = BaseReconfigure{TemplatesPath: s.dir}
+	PutService(ServiceReconfigure{
+		ServiceName:  "my-service",
+		AclName:      "my-service",
+		ServicePath:  []string{"/my/path"},
+		Port:         "8080",
+		Mode:         "swarm",
+		ColorWeights: []ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}},
+	})
+}
+
+func (s *CanaryTestSuite) TearDownTest() {
+	haproxy.Instance = s.proxyOrig
+	os.RemoveAll(s.dir)
+}
+
+// Execute
+
+func (s *CanaryTestSuite) Test_Execute_ShiftsWeights() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Color: "green", Step: 20})
+
+	err := canary.Execute([]string{})
+
+	s.NoError(err)
+	expected := []ColorWeight{{Color: "blue", Weight: 70}, {Color: "green", Weight: 30}}
+	s.Equal(expected, canary.GetColorWeights())
+	actual, _ := getService("my-service")
+	s.Equal(expected, actual.ColorWeights)
+}
+
+func (s *CanaryTestSuite) Test_Execute_WritesBeTemplate() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Color: "green", Step: 20})
+
+	canary.Execute([]string{})
+
+	actual, _ := ioutil.ReadFile(fmt.Sprintf("%s/my-service-be.cfg", s.dir))
+	s.Contains(string(actual), "server my-service-blue my-service-blue:8080 weight 70")
+	s.Contains(string(actual), "server my-service-green my-service-green:8080 weight 30")
+}
+
+func (s *CanaryTestSuite) Test_Execute_SetsWeightsThroughRuntimeApi_WhenColorsDoNotChange() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Color: "green", Step: 20})
+
+	canary.Execute([]string{})
+
+	s.True(canary.IsRuntime())
+	s.False(canary.IsReloaded())
+	s.proxyMock.AssertCalled(s.T(), "SetServerWeight", "my-service-be", "my-service-blue", 70)
+	s.proxyMock.AssertCalled(s.T(), "SetServerWeight", "my-service-be", "my-service-green", 30)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *CanaryTestSuite) Test_Execute_SetsWeightsOfAllTasks_WhenTaskRoutingIsEnabled() {
+	sr, _ := getService("my-service")
+	sr.TaskRouting = true
+	sr.MaxTasks = 2
+	PutService(sr)
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Color: "green", Step: 20})
+
+	canary.Execute([]string{})
+
+	s.proxyMock.AssertCalled(s.T(), "SetServerWeight", "my-service-be", "my-service-green1", 30)
+	s.proxyMock.AssertCalled(s.T(), "SetServerWeight", "my-service-be", "my-service-green2", 30)
+}
+
+func (s *CanaryTestSuite) Test_Execute_ReloadsProxy_WhenRuntimeApiFails() {
+	s.proxyMock = getProxyMock("SetServerWeight")
+	s.proxyMock.On("SetServerWeight", "my-service-be", "my-service-blue", 70).Return(fmt.Errorf("This is an error"))
+	haproxy.Instance = s.proxyMock
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Color: "green", Step: 20})
+
+	err := canary.Execute([]string{})
+
+	s.NoError(err)
+	s.False(canary.IsRuntime())
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+}
+
+func (s *CanaryTestSuite) Test_Execute_DropsOtherColorsAndReloads_WhenPromoting() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Promote: "green"})
+
+	err := canary.Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([]ColorWeight{{Color: "green", Weight: promotedWeight}}, canary.GetColorWeights())
+	s.False(canary.IsRuntime())
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+	s.proxyMock.AssertNotCalled(s.T(), "SetServerWeight", "my-service-be", "my-service-green", promotedWeight)
+	actual, _ := ioutil.ReadFile(fmt.Sprintf("%s/my-service-be.cfg", s.dir))
+	s.False(strings.Contains(string(actual), "my-service-blue"))
+}
+
+func (s *CanaryTestSuite) Test_Execute_SetsColorWeights() {
+	weights := []ColorWeight{{Color: "blue", Weight: 50}, {Color: "green", Weight: 50}}
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", ColorWeights: weights})
+
+	canary.Execute([]string{})
+
+	s.Equal(weights, canary.GetColorWeights())
+	s.True(canary.IsRuntime())
+}
+
+func (s *CanaryTestSuite) Test_Execute_ReturnsError_WhenServiceDoesNotExist() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "unknown", Promote: "green"})
+
+	s.Error(canary.Execute([]string{}))
+}
+
+func (s *CanaryTestSuite) Test_Execute_ReturnsError_WhenServiceDoesNotHaveColors() {
+	PutService(ServiceReconfigure{ServiceName: "no-colors", Mode: "swarm"})
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "no-colors", Promote: "green"})
+
+	s.Error(canary.Execute([]string{}))
+}
+
+func (s *CanaryTestSuite) Test_Execute_ReturnsError_WhenColorDoesNotExist() {
+	for _, change := range []CanaryChange{
+		{ServiceName: "my-service", Promote: "red"},
+		{ServiceName: "my-service", Color: "red", Step: 10},
+		{ServiceName: "my-service", ColorWeights: []ColorWeight{{Color: "blue", Weight: 50}, {Color: "red", Weight: 50}}},
+	} {
+		canary := NewCanary(s.base, change)
+
+		s.Error(canary.Execute([]string{}), change)
+	}
+	s.proxyMock.AssertNotCalled(s.T(), "CreateConfigFromTemplates")
+}
+
+func (s *CanaryTestSuite) Test_Execute_ReturnsError_WhenWeightsAreNotValid() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", ColorWeights: []ColorWeight{{Color: "blue", Weight: 300}}})
+
+	s.Error(canary.Execute([]string{}))
+	s.proxyMock.AssertNotCalled(s.T(), "CreateConfigFromTemplates")
+}
+
+func (s *CanaryTestSuite) Test_Execute_WaitsForOtherChanges() {
+	canary := NewCanary(s.base, CanaryChange{ServiceName: "my-service", Color: "green", Step: 20})
+	done := make(chan error)
+	mu.Lock()
+
+	go func() { done <- canary.Execute([]string{}) }()
+
+	select {
+	case <-done:
+		s.T().Error("The canary was executed while another change was in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	mu.Unlock()
+	s.NoError(<-done)
+}
+
+// shiftWeights
+
+func (s *CanaryTestSuite) Test_ShiftWeights_KeepsTotalAndProportions() {
+	current := []ColorWeight{{Color: "blue", Weight: 60}, {Color: "green", Weight: 20}, {Color: "red", Weight: 20}}
+
+	actual, _ := shiftWeights(current, "red", 40)
+
+	s.Equal([]ColorWeight{{Color: "blue", Weight: 30}, {Color: "green", Weight: 10}, {Color: "red", Weight: 60}}, actual)
+	s.Equal(60, current[0].Weight)
+}
+
+func (s *CanaryTestSuite) Test_ShiftWeights_LimitsWeightToTotal() {
+	current := []ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}
+
+	actual, _ := shiftWeights(current, "green", 200)
+
+	s.Equal([]ColorWeight{{Color: "blue", Weight: 0}, {Color: "green", Weight: 100}}, actual)
+}
+
+func (s *CanaryTestSuite) Test_ShiftWeights_MovesWeightBack_WhenStepIsNegative() {
+	current := []ColorWeight{{Color: "blue", Weight: 0}, {Color: "green", Weight: 100}}
+
+	actual, _ := shiftWeights(current, "green", -30)
+
+	s.Equal([]ColorWeight{{Color: "blue", Weight: 30}, {Color: "green", Weight: 70}}, actual)
+}
+
+// ParseColorWeights
+
+func (s *CanaryTestSuite) Test_ParseColorWeights_ReturnsWeights() {
+	actual, err := ParseColorWeights("blue:90, green:10")
+
+	s.NoError(err)
+	s.Equal([]ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}, actual)
+	s.Equal("blue:90,green:10", FormatColorWeights(actual))
+}
+
+func (s *CanaryTestSuite) Test_ParseColorWeights_ReturnsError_WhenFormatIsNotValid() {
+	for _, value := range []string{"blue", "blue:ninety", "blue:90;green:10"} {
+		_, err := ParseColorWeights(value)
+
+		s.Error(err, value)
+	}
+}
+
+// Suite
+
+func TestCanaryUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CanaryTestSuite))
+}
@@ -0,0 +1,180 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	haproxy "../proxy"
+)
+
+const (
+	HistoryActionReconfigure = "reconfigure"
+	HistoryActionRemove      = "remove"
+	HistoryActionReload      = "reload"
+	HistoryActionRollback    = "rollback"
+)
+
+// ConfigVersion is a proxy configuration together with the snippets and the services it was created from,
+// and the change that created it
+type ConfigVersion struct {
+	Version         int
+	Timestamp       time.Time
+	ServiceName     string
+	Action          string
+	RollbackVersion int                  `json:",omitempty"`
+	Config          string               `json:",omitempty"`
+	Snippets        map[string]string    `json:",omitempty"`
+	Services        []ServiceReconfigure `json:",omitempty"`
+}
+
+var historyMu = &sync.Mutex{}
+var historyPath string
+var historySize int
+
+// SetHistory enables the history of configuration versions stored in the file located in the path.
+// Only the last size versions are kept. The history is disabled if the size is zero.
+func SetHistory(path string, size int) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	historyPath = path
+	historySize = size
+}
+
+// AddConfigVersion stores the current proxy configuration, the snippets from the templates path,
+// and the services as a new version created by the action on the service
+func AddConfigVersion(templatesPath, serviceName, action string) error {
+	return addConfigVersion(templatesPath, ConfigVersion{ServiceName: serviceName, Action: action})
+}
+
+func addConfigVersion(templatesPath string, version ConfigVersion) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if len(historyPath) == 0 || historySize <= 0 {
+		return nil
+	}
+	config, err := haproxy.Instance.ReadConfig()
+	if err != nil {
+		return fmt.Errorf("Could not read the proxy configuration\n%s", err.Error())
+	}
+	snippets, err := readSnippets(templatesPath)
+	if err != nil {
+		return err
+	}
+	versions, err := readHistory()
+	if err != nil {
+		return err
+	}
+	version.Version = 1
+	if len(versions) > 0 {
+		version.Version = versions[len(versions)-1].Version + 1
+	}
+	version.Timestamp = time.Now().UTC()
+	version.Config = config
+	version.Snippets = snippets
+	version.Services = listServices()
+	versions = append(versions, version)
+	if len(versions) > historySize {
+		versions = versions[len(versions)-historySize:]
+	}
+	js, _ := json.Marshal(versions)
+	if err := writeConfigFile(historyPath, js, 0664); err != nil {
+		return fmt.Errorf("Could not write the history to %s\n%s", historyPath, err.Error())
+	}
+	return nil
+}
+
+// GetConfigVersions returns all the stored versions from the oldest to the newest
+func GetConfigVersions() ([]ConfigVersion, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	return readHistory()
+}
+
+// GetConfigVersion returns the stored version with the number
+func GetConfigVersion(number int) (ConfigVersion, error) {
+	versions, err := GetConfigVersions()
+	if err != nil {
+		return ConfigVersion{}, err
+	}
+	for _, version := range versions {
+		if version.Version == number {
+			return version, nil
+		}
+	}
+	return ConfigVersion{}, fmt.Errorf("The version %d does not exist", number)
+}
+
+// Summary returns the version without the configuration, the snippets, and the services
+func (v ConfigVersion) Summary() ConfigVersion {
+	return ConfigVersion{
+		Version:         v.Version,
+		Timestamp:       v.Timestamp,
+		ServiceName:     v.ServiceName,
+		Action:          v.Action,
+		RollbackVersion: v.RollbackVersion,
+	}
+}
+
+// Redacted returns the version with the passwords of the users redacted from the services, the snippets, and the configuration.
+// Certificates of the services are redacted as well.
+func (v ConfigVersion) Redacted() ConfigVersion {
+	services := []ServiceReconfigure{}
+	for _, sr := range v.Services {
+		services = append(services, redactService(sr))
+	}
+	v.Services = services
+	if len(v.Snippets) > 0 {
+		snippets := map[string]string{}
+		for name, content := range v.Snippets {
+			snippets[name] = haproxy.RedactConfig(content)
+		}
+		v.Snippets = snippets
+	}
+	v.Config = haproxy.RedactConfig(v.Config)
+	return v
+}
+
+func readHistory() ([]ConfigVersion, error) {
+	versions := []ConfigVersion{}
+	if len(historyPath) == 0 {
+		return versions, nil
+	}
+	content, err := readConfigFile(historyPath)
+	if os.IsNotExist(err) {
+		return versions, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not read the history from %s\n%s", historyPath, err.Error())
+	}
+	if err := json.Unmarshal(content, &versions); err != nil {
+		return nil, fmt.Errorf("Could not parse the history from %s\n%s", historyPath, err.Error())
+	}
+	return versions, nil
+}
+
+// readSnippets returns the contents of the frontend and backend snippets stored in the templates path
+func readSnippets(templatesPath string) (map[string]string, error) {
+	files, err := readTemplatesDir(templatesPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the directory %s\n%s", templatesPath, err.Error())
+	}
+	snippets := map[string]string{}
+	for _, file := range files {
+		if !isSnippet(file.Name()) {
+			continue
+		}
+		content, err := readConfigFile(fmt.Sprintf("%s/%s", templatesPath, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the file %s\n%s", file.Name(), err.Error())
+		}
+		snippets[file.Name()] = string(content)
+	}
+	return snippets, nil
+}
+
+func isSnippet(name string) bool {
+	return strings.HasSuffix(name, "-fe.cfg") || strings.HasSuffix(name, "-be.cfg")
+}
@@ -0,0 +1,187 @@
+// +build !integration
+
+package actions
+
+import (
+	haproxy "../proxy"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type HistoryTestSuite struct {
+	suite.Suite
+	dir           string
+	templatesPath string
+	proxyMock     *ProxyMock
+	proxyOrig     haproxy.Proxy
+}
+
+func (s *HistoryTestSuite) SetupTest() {
+	s.dir, _ = ioutil.TempDir("", "history")
+	s.templatesPath = fmt.Sprintf("%s/tmpl", s.dir)
+	os.Mkdir(s.templatesPath, 0755)
+	ioutil.WriteFile(fmt.Sprintf("%s/my-service-fe.cfg", s.templatesPath), []byte("my-service front"), 0664)
+	ioutil.WriteFile(fmt.Sprintf("%s/my-service-be.cfg", s.templatesPath), []byte("my-service back"), 0664)
+	ioutil.WriteFile(fmt.Sprintf("%s/haproxy.tmpl", s.templatesPath), []byte("template"), 0664)
+	SetHistory(fmt.Sprintf("%s/history.json", s.dir), 3)
+	services = map[string]ServiceReconfigure{}
+	s.proxyOrig = haproxy.Instance
+	s.proxyMock = getProxyMock("ReadConfig")
+	s.proxyMock.On("ReadConfig").Return("my-config", nil)
+	haproxy.Instance = s.proxyMock
+}
+
+func (s *HistoryTestSuite) TearDownTest() {
+	haproxy.Instance = s.proxyOrig
+	SetHistory("", 0)
+	os.RemoveAll(s.dir)
+}
+
+// AddConfigVersion
+
+func (s *HistoryTestSuite) Test_AddConfigVersion_StoresConfigSnippetsAndServices() {
+	PutService(ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/my-path"}})
+
+	err := AddConfigVersion(s.templatesPath, "my-service", HistoryActionReconfigure)
+
+	s.NoError(err)
+	actual, _ := GetConfigVersions()
+	s.Len(actual, 1)
+	s.Equal(1, actual[0].Version)
+	s.Equal("my-service", actual[0].ServiceName)
+	s.Equal(HistoryActionReconfigure, actual[0].Action)
+	s.False(actual[0].Timestamp.IsZero())
+	s.Equal("my-config", actual[0].Config)
+	s.Equal(map[string]string{"my-service-fe.cfg": "my-service front", "my-service-be.cfg": "my-service back"}, actual[0].Snippets)
+	s.Equal([]ServiceReconfigure{{ServiceName: "my-service", ServicePath: []string{"/my-path"}}}, actual[0].Services)
+}
+
+func (s *HistoryTestSuite) Test_AddConfigVersion_KeepsOnlyTheLastVersions() {
+	for i := 1; i <= 5; i++ {
+		AddConfigVersion(s.templatesPath, fmt.Sprintf("service-%d", i), HistoryActionReconfigure)
+	}
+
+	actual, _ := GetConfigVersions()
+
+	s.Len(actual, 3)
+	s.Equal(3, actual[0].Version)
+	s.Equal("service-3", actual[0].ServiceName)
+	s.Equal(5, actual[2].Version)
+	s.Equal("service-5", actual[2].ServiceName)
+}
+
+func (s *HistoryTestSuite) Test_AddConfigVersion_DoesNothing_WhenHistoryIsDisabled() {
+	SetHistory("", 0)
+
+	err := AddConfigVersion(s.templatesPath, "my-service", HistoryActionReconfigure)
+
+	s.NoError(err)
+	s.proxyMock.AssertNotCalled(s.T(), "ReadConfig")
+}
+
+func (s *HistoryTestSuite) Test_AddConfigVersion_ReturnsError_WhenConfigCannotBeRead() {
+	mockObj := getProxyMock("ReadConfig")
+	mockObj.On("ReadConfig").Return("", fmt.Errorf("This is an error"))
+	haproxy.Instance = mockObj
+
+	err := AddConfigVersion(s.templatesPath, "my-service", HistoryActionReconfigure)
+
+	s.Error(err)
+}
+
+func (s *HistoryTestSuite) Test_AddConfigVersion_ReturnsError_WhenTemplatesPathDoesNotExist() {
+	err := AddConfigVersion("/this/path/does/not/exist", "my-service", HistoryActionReconfigure)
+
+	s.Error(err)
+}
+
+// GetConfigVersion
+
+func (s *HistoryTestSuite) Test_GetConfigVersion_ReturnsVersion() {
+	AddConfigVersion(s.templatesPath, "service-1", HistoryActionReconfigure)
+	AddConfigVersion(s.templatesPath, "service-2", HistoryActionRemove)
+
+	actual, err := GetConfigVersion(2)
+
+	s.NoError(err)
+	s.Equal("service-2", actual.ServiceName)
+	s.Equal(HistoryActionRemove, actual.Action)
+}
+
+func (s *HistoryTestSuite) Test_GetConfigVersion_ReturnsError_WhenVersionDoesNotExist() {
+	AddConfigVersion(s.templatesPath, "service-1", HistoryActionReconfigure)
+
+	_, err := GetConfigVersion(2)
+
+	s.Error(err)
+}
+
+func (s *HistoryTestSuite) Test_GetConfigVersions_ReturnsEmptySlice_WhenHistoryFileDoesNotExist() {
+	actual, err := GetConfigVersions()
+
+	s.NoError(err)
+	s.Equal([]ConfigVersion{}, actual)
+}
+
+// Summary
+
+func (s *HistoryTestSuite) Test_Summary_RemovesConfigSnippetsAndServices() {
+	version := ConfigVersion{
+		Version:     2,
+		ServiceName: "my-service",
+		Action:      HistoryActionReconfigure,
+		Config:      "my-config",
+		Snippets:    map[string]string{"my-service-fe.cfg": "front"},
+		Services:    []ServiceReconfigure{{ServiceName: "my-service"}},
+	}
+
+	actual := version.Summary()
+
+	s.Equal(ConfigVersion{Version: 2, ServiceName: "my-service", Action: HistoryActionReconfigure}, actual)
+}
+
+// Redacted
+
+func (s *HistoryTestSuite) Test_Redacted_RedactsPasswordsOfServiceUsers() {
+	version := ConfigVersion{
+		Services: []ServiceReconfigure{{ServiceName: "my-service", Users: []User{{Username: "my-user", Password: "my-pass"}}}},
+	}
+
+	actual := version.Redacted()
+
+	s.Equal([]User{{Username: "my-user", Password: redactedPassword}}, actual.Services[0].Users)
+	s.Equal("my-pass", version.Services[0].Users[0].Password)
+}
+
+func (s *HistoryTestSuite) Test_Redacted_RedactsCertsOfServices() {
+	version := ConfigVersion{
+		Services: []ServiceReconfigure{{ServiceName: "my-service", ServiceCert: "my-cert"}},
+	}
+
+	actual := version.Redacted()
+
+	s.Equal(redactedPassword, actual.Services[0].ServiceCert)
+}
+
+func (s *HistoryTestSuite) Test_Redacted_RedactsPasswordsInConfigAndSnippets() {
+	version := ConfigVersion{
+		Config:   "userlist defaultUsers\n    user my-user insecure-password my-pass\n",
+		Snippets: map[string]string{"my-service-be.cfg": "userlist my-serviceUsers\n    user my-user password $6$salt$hash\n"},
+	}
+
+	actual := version.Redacted()
+
+	s.Equal("userlist defaultUsers\n    user my-user insecure-password *****\n", actual.Config)
+	s.Equal("userlist my-serviceUsers\n    user my-user password *****\n", actual.Snippets["my-service-be.cfg"])
+	s.Contains(version.Snippets["my-service-be.cfg"], "$6$salt$hash")
+}
+
+// Suite
+
+func TestHistoryUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(HistoryTestSuite))
+}
@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RouteMatch is a condition on a request header, cookie, or query parameter that must be met,
+// together with the path and the domain, for a request to be sent to the service.
+// The value is a regular expression when Regexp is true.
+type RouteMatch struct {
+	Name   string
+	Value  string
+	Regexp bool
+}
+
+// ParseRouteMatches parses comma separated name:value (exact) and name~regexp pairs (e.g. X-Canary:true,X-Api-Version~^2)
+func ParseRouteMatches(value string) ([]RouteMatch, error) {
+	matches := []RouteMatch{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		index := strings.IndexAny(entry, ":~")
+		if index <= 0 {
+			return nil, fmt.Errorf("The match %s must have the format name:value or name~regexp", entry)
+		}
+		matches = append(matches, RouteMatch{
+			Name:   entry[:index],
+			Value:  entry[index+1:],
+			Regexp: entry[index] == '~',
+		})
+	}
+	return matches, nil
+}
+
+// formatRouteMatches returns the matches as JSON so that regular expressions with commas survive a round trip through Consul
+func formatRouteMatches(matches []RouteMatch) string {
+	if len(matches) == 0 {
+		return ""
+	}
+	js, _ := json.Marshal(matches)
+	return string(js)
+}
+
+func parseStoredRouteMatches(value string) []RouteMatch {
+	matches := []RouteMatch{}
+	json.Unmarshal([]byte(value), &matches)
+	return matches
+}
+
+// getMatchAcls returns the ACLs of the header, cookie, query, and method matches of the service
+// and the condition that combines them
+func getMatchAcls(sr *ServiceReconfigure) (acls, condition string) {
+	for _, match := range []struct {
+		prefix  string
+		fetch   string
+		matches []RouteMatch
+	}{
+		{"header", "req.hdr", sr.HeaderMatches},
+		{"cookie", "req.cook", sr.CookieMatches},
+		{"query", "urlp", sr.QueryMatches},
+	} {
+		for i, m := range match.matches {
+			flag := "str"
+			if m.Regexp {
+				flag = "reg"
+			}
+			name := fmt.Sprintf("%s_{{.ServiceName}}_%d", match.prefix, i+1)
+			acls += fmt.Sprintf(`
+    acl %s %s(%s) -m %s %s`, name, match.fetch, m.Name, flag, m.Value)
+			condition += fmt.Sprintf(" %s_%s_%d", match.prefix, sr.ServiceName, i+1)
+		}
+	}
+	if len(sr.Methods) > 0 {
+		acls += `
+    acl method_{{.ServiceName}} method ` + strings.Join(sr.Methods, " ")
+		condition += fmt.Sprintf(" method_%s", sr.ServiceName)
+	}
+	return acls, condition
+}
@@ -0,0 +1,52 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type MatchTestSuite struct {
+	suite.Suite
+}
+
+// ParseRouteMatches
+
+func (s *MatchTestSuite) Test_ParseRouteMatches_ReturnsExactAndRegexpMatches() {
+	actual, err := ParseRouteMatches("X-Canary:true, X-Api-Version~^2:[0-9]+$")
+
+	s.NoError(err)
+	s.Equal([]RouteMatch{
+		{Name: "X-Canary", Value: "true"},
+		{Name: "X-Api-Version", Value: "^2:[0-9]+$", Regexp: true},
+	}, actual)
+}
+
+func (s *MatchTestSuite) Test_ParseRouteMatches_ReturnsError_WhenNameIsMissing() {
+	for _, value := range []string{"X-Canary", ":true", "~^2"} {
+		_, err := ParseRouteMatches(value)
+
+		s.Error(err, value)
+	}
+}
+
+// formatRouteMatches
+
+func (s *MatchTestSuite) Test_FormatRouteMatches_ReturnsValueThatCanBeParsed() {
+	matches := []RouteMatch{{Name: "X-Api-Version", Value: "^2{1,2}$", Regexp: true}}
+
+	actual := parseStoredRouteMatches(formatRouteMatches(matches))
+
+	s.Equal(matches, actual)
+}
+
+func (s *MatchTestSuite) Test_FormatRouteMatches_ReturnsEmptyString_WhenThereAreNoMatches() {
+	s.Empty(formatRouteMatches(nil))
+}
+
+// Suite
+
+func TestMatchUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MatchTestSuite))
+}
@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	haproxy "../proxy"
 	"../registry"
@@ -19,6 +20,9 @@ import (
 const ServiceTemplateFeFilename = "service-formatted-fe.ctmpl"
 const ServiceTemplateBeFilename = "service-formatted-be.ctmpl"
 
+// defaultMaxTasks is the number of server slots reserved for the tasks of a service when MaxTasks is not set
+const defaultMaxTasks = 10
+
 var mu = &sync.Mutex{}
 
 type Reconfigurable interface {
@@ -26,16 +30,40 @@ type Reconfigurable interface {
 	GetData() (BaseReconfigure, ServiceReconfigure)
 	ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error
 	GetTemplates(sr *ServiceReconfigure) (front, back string, err error)
+	GetDiff() (string, error)
+	IsReloaded() bool
+	GetConfigChange() ConfigChange
 }
 
 type Reconfigure struct {
 	BaseReconfigure
 	ServiceReconfigure
+	reloaded     bool
+	configChange ConfigChange
+}
+
+// ConfigChange is the proxy configuration before and after an action changed it
+type ConfigChange struct {
+	Before string
+	After  string
+}
+
+type configBackup struct {
+	path    string
+	content []byte
+	exists  bool
 }
 
 type User struct {
-	Username string
-	Password string
+	Username      string
+	Password      string
+	PassEncrypted bool
+}
+
+// ColorWeight is the weight of the servers of a service color (release) in weighted canary deployments
+type ColorWeight struct {
+	Color  string
+	Weight int
 }
 
 type ServiceReconfigure struct {
@@ -56,15 +84,41 @@ type ServiceReconfigure struct {
 	AclName              string
 	AclCondition         string
 	Users                []User
+	UsersPassEncrypted   bool
+	UsersSecret          string
 	FullServiceName      string
 	Host                 string
 	Distribute           bool
 	LookupRetry          int
 	LookupRetryInterval  int
+	HealthCheckPath      string
+	HealthCheckMethod    string
+	HealthCheckStatus    int
+	HealthCheckInterval  int
+	HealthCheckRise      int
+	HealthCheckFall      int
+	HealthCheckPort      int
+	TimeoutServer        int
+	TimeoutConnect       int
+	TimeoutQueue         int
+	TimeoutTunnel        int
+	MaxConn              int
+	FullConn             int
+	Balance              string
+	StickySessions       bool
+	TaskRouting          bool
+	MaxTasks             int
+	ColorWeights         []ColorWeight
+	HeaderMatches        []RouteMatch
+	CookieMatches        []RouteMatch
+	QueryMatches         []RouteMatch
+	Methods              []string
+	Priority             int
 	ReqRepSearch         string
 	ReqRepReplace        string
 	TemplateFePath       string
 	TemplateBePath       string
+	DryRun               bool `long:"dry-run" description:"If set, the proxy is not reconfigured. Instead, the difference between the current and the proposed configuration is output."`
 }
 
 type BaseReconfigure struct {
@@ -78,32 +132,44 @@ type BaseReconfigure struct {
 var ReconfigureInstance Reconfigure
 
 var NewReconfigure = func(baseData BaseReconfigure, serviceData ServiceReconfigure) Reconfigurable {
-	return &Reconfigure{baseData, serviceData}
+	return &Reconfigure{BaseReconfigure: baseData, ServiceReconfigure: serviceData}
 }
 
 // TODO: Remove args
 func (m *Reconfigure) Execute(args []string) error {
-	mu.Lock()
-	defer mu.Unlock()
+	if m.DryRun {
+		diff, err := m.GetDiff()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, diff)
+		return nil
+	}
 	if isSwarm(m.ServiceReconfigure.Mode) && !m.skipAddressValidation {
-		host := m.ServiceName
+		hosts := []string{m.ServiceName}
 		if len(m.OutboundHostname) > 0 {
-			host = m.OutboundHostname
+			hosts = []string{m.OutboundHostname}
+		} else if len(m.ColorWeights) > 0 {
+			hosts = []string{}
+			for _, cw := range m.ColorWeights {
+				hosts = append(hosts, fmt.Sprintf("%s-%s", m.ServiceName, cw.Color))
+			}
 		}
-		if _, err := lookupHost(host); err != nil {
-			logPrintf("Could not reach the service %s. Is the service running and connected to the same network as the proxy?", host)
-			return err
+		for _, host := range hosts {
+			if err := m.lookupHostWithRetry(host); err != nil {
+				logPrintf("Could not reach the service %s. Is the service running and connected to the same network as the proxy?", host)
+				return err
+			}
 		}
 	}
-	if err := m.createConfigs(m.TemplatesPath, &m.ServiceReconfigure); err != nil {
-		return err
-	}
-	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+	if err := m.updateConfigs(); err != nil {
 		return err
 	}
-	if err := haproxy.Instance.Reload(); err != nil {
+	reloaded, err := haproxy.Instance.Reload()
+	if err != nil {
 		return err
 	}
+	m.reloaded = reloaded
 	if len(m.ConsulAddresses) > 0 || !isSwarm(m.ServiceReconfigure.Mode) {
 		if err := m.putToConsul(m.ConsulAddresses, m.ServiceReconfigure, m.InstanceName); err != nil {
 			return err
@@ -112,10 +178,162 @@ func (m *Reconfigure) Execute(args []string) error {
 	return nil
 }
 
+// updateConfigs writes the service configuration and creates the proxy configuration from it.
+// The proxy is reloaded separately so that reloads of concurrent requests can be coalesced.
+func (m *Reconfigure) updateConfigs() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return m.writeConfigs()
+}
+
+// writeConfigs writes the service configuration and creates the proxy configuration from it. The caller must hold mu.
+func (m *Reconfigure) writeConfigs() (err error) {
+	m.configChange, err = changeServiceConfigs(m.TemplatesPath, m.getConfigName(&m.ServiceReconfigure), m.ServiceName, HistoryActionReconfigure, func() error {
+		return m.createConfigs(m.TemplatesPath, &m.ServiceReconfigure)
+	})
+	return err
+}
+
+// ChangeServiceConfigs applies the change of the configuration files of a service and creates the proxy configuration.
+// Changes are applied one at a time. The files and the service are restored if the change fails
+// or the proxy configuration cannot be created. It returns the proxy configuration before and after the change.
+func ChangeServiceConfigs(templatesPath, configName, serviceName, historyAction string, change func() error) (ConfigChange, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return changeServiceConfigs(templatesPath, configName, serviceName, historyAction, change)
+}
+
+// changeServiceConfigs is ChangeServiceConfigs for the callers that hold mu
+func changeServiceConfigs(templatesPath, configName, serviceName, historyAction string, change func() error) (ConfigChange, error) {
+	backups := backupConfigs(templatesPath, configName)
+	prevService, prevExists := getService(serviceName)
+	restore := func() {
+		logPrintf("Restoring the previous configuration of the service %s", serviceName)
+		restoreConfigs(backups)
+		if prevExists {
+			PutService(prevService)
+		} else {
+			RemoveService(serviceName)
+		}
+	}
+	if err := change(); err != nil {
+		restore()
+		return ConfigChange{}, err
+	}
+	configChange, err := createConfig()
+	if err != nil {
+		restore()
+		return ConfigChange{}, err
+	}
+	if err := AddConfigVersion(templatesPath, serviceName, historyAction); err != nil {
+		logPrintf("Could not add the version to the history\n%s", err.Error())
+	}
+	return configChange, nil
+}
+
+// CreateConfig creates the proxy configuration from the templates while no other change is in progress.
+// It returns the proxy configuration before and after it was created.
+func CreateConfig() (ConfigChange, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return createConfig()
+}
+
+// createConfig creates the proxy configuration from the templates. The caller must hold mu.
+func createConfig() (ConfigChange, error) {
+	before, _ := haproxy.Instance.ReadConfig()
+	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+		return ConfigChange{}, err
+	}
+	after, _ := haproxy.Instance.ReadConfig()
+	return ConfigChange{Before: before, After: after}, nil
+}
+
+// GetDiff returns the difference between the current and the proposed proxy configuration
+// in the unified diff format. Nothing is written, reloaded, or sent to Consul.
+func (m *Reconfigure) GetDiff() (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	sr := m.ServiceReconfigure
+	front, back, err := m.GetTemplates(&sr)
+	if err != nil {
+		return "", err
+	}
+	if !isSwarm(sr.Mode) {
+		if front, back, err = m.renderConsulTemplates(&sr, front, back); err != nil {
+			return "", err
+		}
+	}
+	name := m.getConfigName(&sr)
+	return haproxy.Instance.GetConfigDiff(haproxy.ConfigChanges{
+		Put: map[string]string{
+			fmt.Sprintf("%s-fe.cfg", name): front,
+			fmt.Sprintf("%s-be.cfg", name): back,
+		},
+	})
+}
+
+// renderConsulTemplates renders the Consul templates of the service in a temporary directory
+// so that the configuration files are created without changing the templates directory
+func (m *Reconfigure) renderConsulTemplates(sr *ServiceReconfigure, feTemplate, beTemplate string) (front, back string, err error) {
+	if len(m.ConsulAddresses) == 0 {
+		return "", "", fmt.Errorf("Consul addresses are required to render the configuration of the service %s", sr.ServiceName)
+	}
+	dir, err := ioutil.TempDir("", "dry-run")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(dir)
+	args := registry.CreateConfigsArgs{
+		Addresses:     m.ConsulAddresses,
+		TemplatesPath: dir,
+		FeFile:        ServiceTemplateFeFilename,
+		FeTemplate:    feTemplate,
+		BeFile:        ServiceTemplateBeFilename,
+		BeTemplate:    beTemplate,
+		ServiceName:   sr.ServiceName,
+	}
+	if err := registryInstance.CreateConfigs(&args); err != nil {
+		return "", "", err
+	}
+	feContent, err := readConfigFile(fmt.Sprintf("%s/%s-fe.cfg", dir, sr.ServiceName))
+	if err != nil {
+		return "", "", err
+	}
+	beContent, err := readConfigFile(fmt.Sprintf("%s/%s-be.cfg", dir, sr.ServiceName))
+	if err != nil {
+		return "", "", err
+	}
+	return string(feContent), string(beContent), nil
+}
+
+// RecreateConfig creates the proxy configuration from the templates again and reloads the proxy.
+// It picks up the changes of the configuration data that does not come from the services (e.g. the dfp_users secret).
+func RecreateConfig() (reloaded bool, err error) {
+	mu.Lock()
+	err = haproxy.Instance.CreateConfigFromTemplates()
+	mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	return haproxy.Instance.Reload()
+}
+
 func (m *Reconfigure) GetData() (BaseReconfigure, ServiceReconfigure) {
 	return m.BaseReconfigure, m.ServiceReconfigure
 }
 
+// IsReloaded returns whether the proxy was reloaded by the last execution.
+// The proxy is not reloaded when the configuration did not change.
+func (m *Reconfigure) IsReloaded() bool {
+	return m.reloaded
+}
+
+// GetConfigChange returns the proxy configuration before and after the last execution
+func (m *Reconfigure) GetConfigChange() ConfigChange {
+	return m.configChange
+}
+
 func (m *Reconfigure) ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error {
 	if len(listenerAddress) > 0 {
 		fullAddress := fmt.Sprintf("%s/v1/docker-flow-swarm-listener/notify-services", listenerAddress)
@@ -195,7 +413,11 @@ func (m *Reconfigure) reloadFromRegistry(addresses []string, instanceName, mode
 	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
 		return err
 	}
-	return haproxy.Instance.Reload()
+	if err := AddConfigVersion(m.TemplatesPath, "", HistoryActionReload); err != nil {
+		logPrintf("Could not add the version to the history\n%s", err.Error())
+	}
+	_, err = haproxy.Instance.Reload()
+	return err
 }
 
 func (m *Reconfigure) getService(addresses []string, serviceName, instanceName string, c chan ServiceReconfigure) {
@@ -215,6 +437,46 @@ func (m *Reconfigure) getService(addresses []string, serviceName, instanceName s
 		sr.ConsulTemplateFePath, _ = m.getServiceAttribute(addresses, serviceName, registry.CONSUL_TEMPLATE_FE_PATH_KEY, instanceName)
 		sr.ConsulTemplateBePath, _ = m.getServiceAttribute(addresses, serviceName, registry.CONSUL_TEMPLATE_BE_PATH_KEY, instanceName)
 		sr.Port, _ = m.getServiceAttribute(addresses, serviceName, registry.PORT, instanceName)
+		sr.HealthCheckPath, _ = m.getServiceAttribute(addresses, serviceName, registry.HEALTH_CHECK_PATH_KEY, instanceName)
+		sr.HealthCheckMethod, _ = m.getServiceAttribute(addresses, serviceName, registry.HEALTH_CHECK_METHOD_KEY, instanceName)
+		sr.Balance, _ = m.getServiceAttribute(addresses, serviceName, registry.BALANCE_KEY, instanceName)
+		stickySessions, _ := m.getServiceAttribute(addresses, serviceName, registry.STICKY_SESSIONS_KEY, instanceName)
+		sr.StickySessions, _ = strconv.ParseBool(stickySessions)
+		taskRouting, _ := m.getServiceAttribute(addresses, serviceName, registry.TASK_ROUTING_KEY, instanceName)
+		sr.TaskRouting, _ = strconv.ParseBool(taskRouting)
+		if colorWeights, _ := m.getServiceAttribute(addresses, serviceName, registry.COLOR_WEIGHTS_KEY, instanceName); len(colorWeights) > 0 {
+			sr.ColorWeights, _ = ParseColorWeights(colorWeights)
+		}
+		for key, value := range map[string]*[]RouteMatch{
+			registry.HEADER_MATCHES_KEY: &sr.HeaderMatches,
+			registry.COOKIE_MATCHES_KEY: &sr.CookieMatches,
+			registry.QUERY_MATCHES_KEY:  &sr.QueryMatches,
+		} {
+			if attr, _ := m.getServiceAttribute(addresses, serviceName, key, instanceName); len(attr) > 0 {
+				*value = parseStoredRouteMatches(attr)
+			}
+		}
+		if methods, _ := m.getServiceAttribute(addresses, serviceName, registry.METHODS_KEY, instanceName); len(methods) > 0 {
+			sr.Methods = strings.Split(methods, ",")
+		}
+		for key, value := range map[string]*int{
+			registry.HEALTH_CHECK_STATUS_KEY:   &sr.HealthCheckStatus,
+			registry.HEALTH_CHECK_INTERVAL_KEY: &sr.HealthCheckInterval,
+			registry.HEALTH_CHECK_RISE_KEY:     &sr.HealthCheckRise,
+			registry.HEALTH_CHECK_FALL_KEY:     &sr.HealthCheckFall,
+			registry.HEALTH_CHECK_PORT_KEY:     &sr.HealthCheckPort,
+			registry.TIMEOUT_SERVER_KEY:        &sr.TimeoutServer,
+			registry.TIMEOUT_CONNECT_KEY:       &sr.TimeoutConnect,
+			registry.TIMEOUT_QUEUE_KEY:         &sr.TimeoutQueue,
+			registry.TIMEOUT_TUNNEL_KEY:        &sr.TimeoutTunnel,
+			registry.MAX_CONN_KEY:              &sr.MaxConn,
+			registry.FULL_CONN_KEY:             &sr.FullConn,
+			registry.MAX_TASKS_KEY:             &sr.MaxTasks,
+			registry.PRIORITY_KEY:              &sr.Priority,
+		} {
+			attr, _ := m.getServiceAttribute(addresses, serviceName, key, instanceName)
+			*value, _ = strconv.Atoi(attr)
+		}
 	}
 	c <- sr
 }
@@ -240,9 +502,7 @@ func (m *Reconfigure) createConfigs(templatesPath string, sr *ServiceReconfigure
 		return err
 	}
 	if strings.EqualFold(sr.Mode, "service") || strings.EqualFold(sr.Mode, "swarm") {
-		if len(sr.AclName) == 0 {
-			sr.AclName = sr.ServiceName
-		}
+		sr.AclName = m.getConfigName(sr)
 		destFe := fmt.Sprintf("%s/%s-fe.cfg", templatesPath, sr.AclName)
 		writeFeTemplate(destFe, []byte(feTemplate), 0664)
 		destBe := fmt.Sprintf("%s/%s-be.cfg", templatesPath, sr.AclName)
@@ -261,9 +521,38 @@ func (m *Reconfigure) createConfigs(templatesPath string, sr *ServiceReconfigure
 			return err
 		}
 	}
+	PutService(*sr)
 	return nil
 }
 
+// getConfigName returns the name used for the service configuration files
+func (m *Reconfigure) getConfigName(sr *ServiceReconfigure) string {
+	if isSwarm(sr.Mode) && len(sr.AclName) > 0 {
+		return sr.AclName
+	}
+	return sr.ServiceName
+}
+
+func backupConfigs(templatesPath, name string) []configBackup {
+	backups := []configBackup{}
+	for _, confType := range []string{"fe", "be"} {
+		path := fmt.Sprintf("%s/%s-%s.cfg", templatesPath, name, confType)
+		content, err := readConfigFile(path)
+		backups = append(backups, configBackup{path: path, content: content, exists: err == nil})
+	}
+	return backups
+}
+
+func restoreConfigs(backups []configBackup) {
+	for _, b := range backups {
+		if b.exists {
+			writeConfigFile(b.path, b.content, 0664)
+		} else {
+			removeConfigFile(b.path)
+		}
+	}
+}
+
 func (m *Reconfigure) putToConsul(addresses []string, sr ServiceReconfigure, instanceName string) error {
 	r := registry.Registry{
 		ServiceName:          sr.ServiceName,
@@ -277,6 +566,29 @@ func (m *Reconfigure) putToConsul(addresses []string, sr ServiceReconfigure, ins
 		ConsulTemplateFePath: sr.ConsulTemplateFePath,
 		ConsulTemplateBePath: sr.ConsulTemplateBePath,
 		Port:                 sr.Port,
+		HealthCheckPath:      sr.HealthCheckPath,
+		HealthCheckMethod:    sr.HealthCheckMethod,
+		HealthCheckStatus:    sr.HealthCheckStatus,
+		HealthCheckInterval:  sr.HealthCheckInterval,
+		HealthCheckRise:      sr.HealthCheckRise,
+		HealthCheckFall:      sr.HealthCheckFall,
+		HealthCheckPort:      sr.HealthCheckPort,
+		TimeoutServer:        sr.TimeoutServer,
+		TimeoutConnect:       sr.TimeoutConnect,
+		TimeoutQueue:         sr.TimeoutQueue,
+		TimeoutTunnel:        sr.TimeoutTunnel,
+		MaxConn:              sr.MaxConn,
+		FullConn:             sr.FullConn,
+		Balance:              sr.Balance,
+		StickySessions:       sr.StickySessions,
+		TaskRouting:          sr.TaskRouting,
+		MaxTasks:             sr.MaxTasks,
+		ColorWeights:         FormatColorWeights(sr.ColorWeights),
+		HeaderMatches:        formatRouteMatches(sr.HeaderMatches),
+		CookieMatches:        formatRouteMatches(sr.CookieMatches),
+		QueryMatches:         formatRouteMatches(sr.QueryMatches),
+		Methods:              strings.Join(sr.Methods, ","),
+		Priority:             sr.Priority,
 	}
 	if err := registryInstance.PutService(addresses, instanceName, r); err != nil {
 		return err
@@ -332,22 +644,29 @@ func (m *Reconfigure) formatData(sr *ServiceReconfigure) {
 	if len(sr.PathType) == 0 {
 		sr.PathType = "path_beg"
 	}
+	if sr.TaskRouting && sr.MaxTasks == 0 {
+		sr.MaxTasks = defaultMaxTasks
+	}
 }
 
 func (m *Reconfigure) getFrontTemplate(sr *ServiceReconfigure) string {
-	tmpl := `
+	tmpl := ""
+	if sr.Priority != 0 {
+		tmpl += `
+    # priority {{.Priority}}`
+	}
+	tmpl += `
     acl url_{{.ServiceName}}{{range .ServicePath}} {{$.PathType}} {{.}}{{end}}`
 	if len(sr.ServiceDomain) > 0 {
 		domFunc := "hdr_dom"
-		for i, domain := range sr.ServiceDomain {
+		for _, domain := range sr.ServiceDomain {
 			if strings.HasPrefix(domain, "*") {
-				sr.ServiceDomain[i] = strings.Trim(domain, "*")
 				domFunc = "hdr_end"
 			}
 		}
 		tmpl += fmt.Sprintf(
 			`
-    acl domain_{{.ServiceName}} %s(host) -i{{range .ServiceDomain}} {{.}}{{end}}`,
+    acl domain_{{.ServiceName}} %s(host) -i{{range .ServiceDomain}} {{trimWildcard .}}{{end}}`,
 			domFunc,
 		)
 		sr.AclCondition = fmt.Sprintf(" domain_%s", sr.ServiceName)
@@ -357,6 +676,9 @@ func (m *Reconfigure) getFrontTemplate(sr *ServiceReconfigure) string {
     acl http_{{.ServiceName}} dst_port 80
     acl https_{{.ServiceName}} dst_port 443`
 	}
+	matchAcls, matchCondition := getMatchAcls(sr)
+	tmpl += matchAcls
+	sr.AclCondition += matchCondition
 	tmpl += `
     use_backend {{.AclName}}-be if url_{{.ServiceName}}{{.AclCondition}}`
 	if sr.HttpsPort > 0 {
@@ -387,29 +709,61 @@ func (m *Reconfigure) getBackTemplateProtocol(protocol string, sr *ServiceReconf
     mode http`,
 		prefix,
 	)
+	if len(sr.Balance) > 0 {
+		tmpl += `
+    balance {{.Balance}}`
+	}
+	if sr.StickySessions && sr.isTaskRouting() {
+		tmpl += `
+    cookie SERVERID insert indirect nocache dynamic
+    dynamic-cookie-key {{.ServiceName}}`
+	} else if sr.StickySessions {
+		tmpl += `
+    cookie SERVERID insert indirect nocache`
+	}
+	if sr.FullConn > 0 {
+		tmpl += `
+    fullconn {{.FullConn}}`
+	}
+	for _, timeout := range []struct {
+		name  string
+		field string
+		value int
+	}{
+		{"connect", "TimeoutConnect", sr.TimeoutConnect},
+		{"server", "TimeoutServer", sr.TimeoutServer},
+		{"queue", "TimeoutQueue", sr.TimeoutQueue},
+		{"tunnel", "TimeoutTunnel", sr.TimeoutTunnel},
+	} {
+		if timeout.value > 0 {
+			tmpl += fmt.Sprintf(`
+    timeout %s {{.%s}}s`, timeout.name, timeout.field)
+		}
+	}
 	if len(sr.ReqRepSearch) > 0 && len(sr.ReqRepReplace) > 0 {
 		tmpl += `
     reqrep {{.ReqRepSearch}}     {{.ReqRepReplace}}`
 	}
-	if strings.EqualFold(sr.Mode, "service") || strings.EqualFold(sr.Mode, "swarm") {
-		if strings.EqualFold(protocol, "https") {
-			tmpl += `
-    server {{.ServiceName}} {{.Host}}:{{.HttpsPort}}`
-		} else {
+	if len(sr.HealthCheckPath) > 0 && !sr.SkipCheck {
+		tmpl += `
+    option httpchk {{if .HealthCheckMethod}}{{.HealthCheckMethod}}{{else}}GET{{end}} {{.HealthCheckPath}}`
+		if sr.HealthCheckStatus > 0 {
 			tmpl += `
-    server {{.ServiceName}} {{.Host}}:{{.Port}}`
+    http-check expect status {{.HealthCheckStatus}}`
 		}
-	} else { // It's Consul
-		tmpl += `
-    {{"{{"}}range $i, $e := service "{{.FullServiceName}}" "any"{{"}}"}}
-    server {{"{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}"}}{{if eq .SkipCheck false}} check{{end}}
-    {{"{{end}}"}}`
+	}
+	if len(sr.ColorWeights) > 0 {
+		for i := range sr.ColorWeights {
+			tmpl += getServers(protocol, sr, i)
+		}
+	} else {
+		tmpl += getServers(protocol, sr, -1)
 	}
 	if len(sr.Users) > 0 {
 		tmpl += `
     acl {{.ServiceName}}UsersAcl http_auth({{.ServiceName}}Users)
     http-request auth realm {{.ServiceName}}Realm if !{{.ServiceName}}UsersAcl`
-	} else if len(os.Getenv("USERS")) > 0 {
+	} else if haproxy.HasGlobalUsers() {
 		tmpl += `
     acl defaultUsersAcl http_auth(defaultUsers)
     http-request auth realm defaultRealm if !defaultUsersAcl`
@@ -417,20 +771,133 @@ func (m *Reconfigure) getBackTemplateProtocol(protocol string, sr *ServiceReconf
 	return tmpl
 }
 
+// getServers returns the server lines of the backend. If colorIndex is not negative, the servers of the color
+// with that index in ColorWeights are returned with its weight.
+func getServers(protocol string, sr *ServiceReconfigure, colorIndex int) string {
+	name, host, consulService, consulPrefix, weight := "{{.ServiceName}}", "{{.Host}}", "{{.FullServiceName}}", "", ""
+	if colorIndex >= 0 {
+		color := fmt.Sprintf("{{(index .ColorWeights %d).Color}}", colorIndex)
+		name = "{{.ServiceName}}-" + color
+		host = name
+		consulService = name
+		consulPrefix = color + "_"
+		weight = fmt.Sprintf(" weight {{(index .ColorWeights %d).Weight}}", colorIndex)
+	}
+	port := "{{.Port}}"
+	if strings.EqualFold(protocol, "https") {
+		port = "{{.HttpsPort}}"
+	}
+	tmpl := ""
+	if sr.isTaskRouting() {
+		tmpl += `
+    server-template ` + name + ` {{.MaxTasks}} tasks.` + name + `:` + port + weight + ` resolvers ` + haproxy.ResolversName + ` init-addr none`
+		tmpl += getServerOptions(sr, sr.hasHealthCheck())
+	} else if isSwarm(sr.Mode) {
+		tmpl += `
+    server ` + name + ` ` + host + `:` + port + weight + ` resolvers ` + haproxy.ResolversName + ` init-addr last,libc,none`
+		if sr.StickySessions {
+			tmpl += " cookie " + name
+		}
+		tmpl += getServerOptions(sr, sr.hasHealthCheck())
+	} else { // It's Consul
+		tmpl += `
+    {{"{{"}}range $i, $e := service "` + consulService + `" "any"{{"}}"}}
+    server ` + consulPrefix + `{{"{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}}"}}` + weight
+		if sr.StickySessions {
+			tmpl += ` cookie ` + consulPrefix + `{{"{{$e.Node}}_{{$i}}_{{$e.Port}}"}}`
+		}
+		tmpl += getServerOptions(sr, !sr.SkipCheck)
+		tmpl += `
+    {{"{{end}}"}}`
+	}
+	return tmpl
+}
+
+// getColorServers returns the names of the servers of the color in the swarm mode
+func getColorServers(sr *ServiceReconfigure, color string) []string {
+	name := fmt.Sprintf("%s-%s", sr.ServiceName, color)
+	if !sr.isTaskRouting() {
+		return []string{name}
+	}
+	servers := []string{}
+	for i := 1; i <= sr.MaxTasks; i++ {
+		servers = append(servers, fmt.Sprintf("%s%d", name, i))
+	}
+	return servers
+}
+
+// lookupHostWithRetry looks up the host and retries LookupRetry times, waiting LookupRetryInterval milliseconds between the attempts
+func (m *Reconfigure) lookupHostWithRetry(host string) error {
+	for i := 0; ; i++ {
+		_, err := lookupHost(host)
+		if err == nil || i >= m.LookupRetry {
+			return err
+		}
+		logPrintf("Could not resolve %s. Retrying in %d milliseconds", host, m.LookupRetryInterval)
+		sleep(time.Duration(m.LookupRetryInterval) * time.Millisecond)
+	}
+}
+
+// isTaskRouting returns whether the requests should be sent to the individual tasks of a Swarm service instead of its VIP
+func (sr *ServiceReconfigure) isTaskRouting() bool {
+	return sr.TaskRouting && isSwarm(sr.Mode)
+}
+
+// hasHealthCheck returns whether any of the health check parameters is set and checks are not skipped
+func (sr *ServiceReconfigure) hasHealthCheck() bool {
+	if sr.SkipCheck {
+		return false
+	}
+	return len(sr.HealthCheckPath) > 0 || sr.HealthCheckInterval > 0 || sr.HealthCheckRise > 0 || sr.HealthCheckFall > 0 || sr.HealthCheckPort > 0
+}
+
+// getServerOptions returns the options of the server lines. Check options are added only if check is true.
+func getServerOptions(sr *ServiceReconfigure, check bool) string {
+	options := ""
+	if sr.MaxConn > 0 {
+		options += " maxconn {{.MaxConn}}"
+	}
+	if !check {
+		return options
+	}
+	options += " check"
+	if sr.HealthCheckInterval > 0 {
+		options += " inter {{.HealthCheckInterval}}"
+	}
+	if sr.HealthCheckRise > 0 {
+		options += " rise {{.HealthCheckRise}}"
+	}
+	if sr.HealthCheckFall > 0 {
+		options += " fall {{.HealthCheckFall}}"
+	}
+	if sr.HealthCheckPort > 0 {
+		options += " port {{.HealthCheckPort}}"
+	}
+	return options
+}
+
 func (m *Reconfigure) getUsersList(sr *ServiceReconfigure) string {
 	if len(sr.Users) > 0 {
 		return `userlist {{.ServiceName}}Users{{range .Users}}
-    user {{.Username}} insecure-password {{.Password}}{{end}}
+    user {{.Username}} {{if .PassEncrypted}}password{{else}}insecure-password{{end}} {{.Password}}{{end}}
 
 `
 	}
 	return ""
 }
 
+// templateFuncs are the functions available to the service snippets
+var templateFuncs = template.FuncMap{
+	// trimWildcard removes the wildcard of a domain since hdr_end matches the domain suffix
+	"trimWildcard": func(domain string) string {
+		return strings.Trim(domain, "*")
+	},
+}
+
 func (m *Reconfigure) parseTemplate(front, usersList, back string, sr *ServiceReconfigure) (pFront, pBack string) {
-	tmplFront, _ := template.New("template").Parse(front)
-	tmplUsersList, _ := template.New("template").Parse(usersList)
-	tmplBack, _ := template.New("template").Parse(back)
+	tmplFront, _ := template.New("template").Funcs(templateFuncs).Parse(front)
+	tmplUsersList, _ := template.New("template").Funcs(templateFuncs).Parse(usersList)
+	tmplBack, _ := template.New("template").Funcs(templateFuncs).Parse(back)
 	var ctFront bytes.Buffer
 	var ctUsersList bytes.Buffer
 	var ctBack bytes.Buffer
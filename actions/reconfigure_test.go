@@ -5,6 +5,7 @@ package actions
 import (
 	haproxy "../proxy"
 	"../registry"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/mock"
@@ -12,8 +13,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 type ReconfigureTestSuite struct {
@@ -150,7 +153,7 @@ func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent() {
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenUsersEnvIsPresent() {
 	usersOrig := os.Getenv("USERS")
 	defer func() { os.Setenv("USERS", usersOrig) }()
-	os.Setenv("USERS", "anything")
+	os.Setenv("USERS", "my-user:my-pass")
 	expected := `backend myService-be
     mode http
     {{range $i, $e := service "myService" "any"}}
@@ -186,6 +189,20 @@ backend myService-be
 	s.Equal(expected, back)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesPasswordKeyword_WhenPasswordIsEncrypted() {
+	s.reconfigure.Users = []User{
+		{Username: "user-1", Password: "$6$salt$hash", PassEncrypted: true},
+		{Username: "user-2", Password: "pass-2"},
+	}
+
+	_, back, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Contains(back, `userlist myServiceUsers
+    user user-1 password $6$salt$hash
+    user user-2 insecure-password pass-2
+`)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent_WhenModeIsSwarm() {
 	modes := []string{"service", "sWARm"}
 	for _, mode := range modes {
@@ -193,7 +210,7 @@ func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent_WhenMode
 		s.reconfigure.ServiceReconfigure.Port = "1234"
 		expected := `backend myService-be
     mode http
-    server myService myService:1234`
+    server myService myService:1234 resolvers docker init-addr last,libc,none`
 
 		_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
 
@@ -204,12 +221,12 @@ func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFormattedContent_WhenMode
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndUsersEnvIsPresent() {
 	usersOrig := os.Getenv("USERS")
 	defer func() { os.Setenv("USERS", usersOrig) }()
-	os.Setenv("USERS", "anything")
+	os.Setenv("USERS", "my-user:my-pass")
 	s.reconfigure.ServiceReconfigure.Mode = "swarm"
 	s.reconfigure.ServiceReconfigure.Port = "1234"
 	expected := `backend myService-be
     mode http
-    server myService myService:1234
+    server myService myService:1234 resolvers docker init-addr last,libc,none
     acl defaultUsersAcl http_auth(defaultUsers)
     http-request auth realm defaultRealm if !defaultUsersAcl`
 
@@ -231,7 +248,7 @@ func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpAuth_WhenModeIsSwarmAndU
 
 backend myService-be
     mode http
-    server myService myService:1234
+    server myService myService:1234 resolvers docker init-addr last,libc,none
     acl myServiceUsersAcl http_auth(myServiceUsers)
     http-request auth realm myServiceRealm if !myServiceUsersAcl`
 
@@ -251,6 +268,49 @@ func (s ReconfigureTestSuite) Test_GetTemplates_AddsHosts() {
 	s.Equal(s.ConsulTemplateFe, actual)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsPriority_WhenPresent() {
+	s.reconfigure.Priority = 10
+	expected := `
+    # priority 10
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    use_backend myService-be if url_myService`
+
+	actual, _, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMatchAcls_WhenMatchesArePresent() {
+	expected := `
+    acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
+    acl domain_myService hdr_dom(host) -i my-domain.com
+    acl header_myService_1 req.hdr(X-Canary) -m str true
+    acl header_myService_2 req.hdr(X-Api-Version) -m reg ^2(\.[0-9]+)?$
+    acl cookie_myService_1 req.cook(tester) -m str yes
+    acl query_myService_1 urlp(beta) -m str 1
+    acl method_myService method GET POST
+    use_backend myService-be if url_myService domain_myService header_myService_1 header_myService_2 cookie_myService_1 query_myService_1 method_myService`
+	s.reconfigure.ServiceDomain = []string{"my-domain.com"}
+	s.reconfigure.HeaderMatches = []RouteMatch{{Name: "X-Canary", Value: "true"}, {Name: "X-Api-Version", Value: `^2(\.[0-9]+)?$`, Regexp: true}}
+	s.reconfigure.CookieMatches = []RouteMatch{{Name: "tester", Value: "yes"}}
+	s.reconfigure.QueryMatches = []RouteMatch{{Name: "beta", Value: "1"}}
+	s.reconfigure.Methods = []string{"GET", "POST"}
+
+	actual, _, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMatchAclsToHttpsBackend_WhenHttpsPortIsPresent() {
+	s.reconfigure.HttpsPort = 4321
+	s.reconfigure.HeaderMatches = []RouteMatch{{Name: "X-Canary", Value: "true"}}
+
+	actual, _, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Contains(actual, "use_backend myService-be if url_myService header_myService_1 http_myService")
+	s.Contains(actual, "use_backend https-myService-be if url_myService header_myService_1 https_myService")
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpsPort_WhenPresent() {
 	expectedFront := `
     acl url_myService path_beg path/to/my/service/api path_beg path/to/my/other/service/api
@@ -260,11 +320,11 @@ func (s ReconfigureTestSuite) Test_GetTemplates_AddsHttpsPort_WhenPresent() {
     use_backend https-myService-be if url_myService https_myService`
 	expectedBack := `backend myService-be
     mode http
-    server myService myService:1234
+    server myService myService:1234 resolvers docker init-addr last,libc,none
 
 backend https-myService-be
     mode http
-    server myService myService:4321`
+    server myService myService:4321 resolvers docker init-addr last,libc,none`
 	s.reconfigure.Port = "1234"
 	s.reconfigure.Mode = "service"
 	s.reconfigure.HttpsPort = 4321
@@ -287,6 +347,18 @@ func (s ReconfigureTestSuite) Test_GetTemplates_AddsHostsStartingWithWildcard()
 	s.Equal(s.ConsulTemplateFe, actual)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotChangeServiceDomain() {
+	s.reconfigure.ServiceDomain = []string{"acme.com", "*.domain.com"}
+	sr := s.reconfigure.ServiceReconfigure
+
+	first, _, _ := s.reconfigure.GetTemplates(&sr)
+	second, _, _ := s.reconfigure.GetTemplates(&sr)
+
+	s.Equal([]string{"acme.com", "*.domain.com"}, sr.ServiceDomain)
+	s.Equal([]string{"acme.com", "*.domain.com"}, s.reconfigure.ServiceDomain)
+	s.Equal(first, second)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_AddsReqRep_WhenReqRepSearchAndReqRepReplaceArePresent() {
 	s.reconfigure.ReqRepSearch = "this"
 	s.reconfigure.ReqRepReplace = "that"
@@ -306,6 +378,20 @@ func (s ReconfigureTestSuite) Test_GetTemplates_AddsReqRep_WhenReqRepSearchAndRe
 	s.Equal(expected, backend)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotEscapeServicePathAndReqRep() {
+	s.reconfigure.ServicePath = []string{"/a&b"}
+	s.reconfigure.ReqRepSearch = "^([^\\ ]*)\\ /api/(.*)"
+	s.reconfigure.ReqRepReplace = "\\1\\ /'\\2"
+	expectedFront := `
+    acl url_myService path_beg /a&b
+    use_backend myService-be if url_myService`
+
+	actualFront, actualBack, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expectedFront, actualFront)
+	s.Contains(actualBack, "reqrep ^([^\\ ]*)\\ /api/(.*)     \\1\\ /'\\2")
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_UsesAclNameForFrontEnd() {
 	s.reconfigure.AclName = "my-acl"
 	s.ConsulTemplateFe = `
@@ -343,6 +429,254 @@ func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotSetCheckWhenSkipCheckIsTr
 	s.Equal(s.ConsulTemplateBe, actual)
 }
 
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHealthCheck_WhenModeIsSwarm() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.HealthCheckPath = "/health"
+	s.reconfigure.HealthCheckStatus = 200
+	s.reconfigure.HealthCheckInterval = 2000
+	s.reconfigure.HealthCheckRise = 2
+	s.reconfigure.HealthCheckFall = 3
+	s.reconfigure.HealthCheckPort = 8081
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /health
+    http-check expect status 200
+    server myService myService:1234 resolvers docker init-addr last,libc,none check inter 2000 rise 2 fall 3 port 8081`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotEscapeHealthCheckPath() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.HealthCheckPath = "/health?a=1&b=2+3"
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /health?a=1&b=2+3
+    server myService myService:1234 resolvers docker init-addr last,libc,none check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsCheckWithoutHttpchk_WhenModeIsSwarmAndOnlyIntervalIsSet() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.HealthCheckInterval = 5000
+	expected := `backend myService-be
+    mode http
+    server myService myService:1234 resolvers docker init-addr last,libc,none check inter 5000`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_DoesNotAddHealthCheck_WhenModeIsSwarmAndSkipCheckIsTrue() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.HealthCheckPath = "/health"
+	s.reconfigure.HealthCheckInterval = 2000
+	s.reconfigure.SkipCheck = true
+	expected := `backend myService-be
+    mode http
+    server myService myService:1234 resolvers docker init-addr last,libc,none`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsHealthCheck_WhenModeIsNotSwarm() {
+	s.reconfigure.HealthCheckPath = "/health"
+	s.reconfigure.HealthCheckMethod = "HEAD"
+	s.reconfigure.HealthCheckStatus = 204
+	s.reconfigure.HealthCheckInterval = 2000
+	s.reconfigure.HealthCheckRise = 2
+	s.reconfigure.HealthCheckFall = 3
+	expected := `backend myService-be
+    mode http
+    option httpchk HEAD /health
+    http-check expect status 204
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} check inter 2000 rise 2 fall 3
+    {{end}}`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsTimeoutsAndConnectionLimits_WhenModeIsSwarm() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TimeoutServer = 300
+	s.reconfigure.TimeoutConnect = 2
+	s.reconfigure.TimeoutQueue = 10
+	s.reconfigure.TimeoutTunnel = 3600
+	s.reconfigure.MaxConn = 50
+	s.reconfigure.FullConn = 500
+	expected := `backend myService-be
+    mode http
+    fullconn 500
+    timeout connect 2s
+    timeout server 300s
+    timeout queue 10s
+    timeout tunnel 3600s
+    server myService myService:1234 resolvers docker init-addr last,libc,none maxconn 50`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsMaxConn_WhenModeIsNotSwarm() {
+	s.reconfigure.TimeoutServer = 300
+	s.reconfigure.MaxConn = 50
+	expected := `backend myService-be
+    mode http
+    timeout server 300s
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} maxconn 50 check
+    {{end}}`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBalanceAndStickySessions_WhenModeIsSwarm() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.Balance = "hdr(X-Tenant)"
+	s.reconfigure.StickySessions = true
+	expected := `backend myService-be
+    mode http
+    balance hdr(X-Tenant)
+    cookie SERVERID insert indirect nocache
+    server myService myService:1234 resolvers docker init-addr last,libc,none cookie myService`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsBalanceAndStickySessions_WhenModeIsNotSwarm() {
+	s.reconfigure.Balance = "leastconn"
+	s.reconfigure.StickySessions = true
+	expected := `backend myService-be
+    mode http
+    balance leastconn
+    cookie SERVERID insert indirect nocache
+    {{range $i, $e := service "myService" "any"}}
+    server {{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} cookie {{$e.Node}}_{{$i}}_{{$e.Port}} check
+    {{end}}`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServerTemplate_WhenTaskRoutingIsTrue() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TaskRouting = true
+	s.reconfigure.HealthCheckPath = "/health"
+	expected := `backend myService-be
+    mode http
+    option httpchk GET /health
+    server-template myService 10 tasks.myService:1234 resolvers docker init-addr none check`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsDynamicCookies_WhenTaskRoutingAndStickySessionsAreTrue() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TaskRouting = true
+	s.reconfigure.MaxTasks = 20
+	s.reconfigure.StickySessions = true
+	expected := `backend myService-be
+    mode http
+    cookie SERVERID insert indirect nocache dynamic
+    dynamic-cookie-key myService
+    server-template myService 20 tasks.myService:1234 resolvers docker init-addr none`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_UsesHttpsPortInServerTemplate_WhenTaskRoutingIsTrue() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.ServiceReconfigure.HttpsPort = 4321
+	s.reconfigure.TaskRouting = true
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Contains(actual, `backend https-myService-be
+    mode http
+    server-template myService 10 tasks.myService:4321 resolvers docker init-addr none`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServerForEachColor_WhenColorWeightsAreSet() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.ColorWeights = []ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}
+	expected := `backend myService-be
+    mode http
+    server myService-blue myService-blue:1234 weight 90 resolvers docker init-addr last,libc,none
+    server myService-green myService-green:1234 weight 10 resolvers docker init-addr last,libc,none`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsServerTemplateForEachColor_WhenColorWeightsAndTaskRoutingAreSet() {
+	s.reconfigure.ServiceReconfigure.Mode = "swarm"
+	s.reconfigure.ServiceReconfigure.Port = "1234"
+	s.reconfigure.TaskRouting = true
+	s.reconfigure.MaxTasks = 5
+	s.reconfigure.ColorWeights = []ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}
+	expected := `backend myService-be
+    mode http
+    server-template myService-blue 5 tasks.myService-blue:1234 weight 90 resolvers docker init-addr none
+    server-template myService-green 5 tasks.myService-green:1234 weight 10 resolvers docker init-addr none`
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(expected, actual)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_AddsConsulServiceForEachColor_WhenColorWeightsAreSet() {
+	s.reconfigure.ColorWeights = []ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Contains(actual, `{{range $i, $e := service "myService-blue" "any"}}
+    server blue_{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} weight 90 check
+    {{end}}
+    {{range $i, $e := service "myService-green" "any"}}
+    server green_{{$e.Node}}_{{$i}}_{{$e.Port}} {{$e.Address}}:{{$e.Port}} weight 10 check
+    {{end}}`)
+}
+
+func (s ReconfigureTestSuite) Test_GetTemplates_IgnoresTaskRouting_WhenModeIsNotSwarm() {
+	s.reconfigure.TaskRouting = true
+
+	_, actual, _ := s.reconfigure.GetTemplates(&s.reconfigure.ServiceReconfigure)
+
+	s.Equal(s.ConsulTemplateBe, actual)
+}
+
 func (s ReconfigureTestSuite) Test_GetTemplates_ReturnsFileContent_WhenConsulTemplatePathIsSet() {
 	expected := "This is content of a template"
 	readTemplateFileOrig := readTemplateFile
@@ -496,7 +830,7 @@ func (s ReconfigureTestSuite) Test_Execute_WritesBeTemplate_WhenModeIsService()
 	s.reconfigure.Port = "1234"
 	var actualFilename, actualData string
 	expectedFilename := fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.ServiceName)
-	expectedData := fmt.Sprintf("backend %s-be\n    mode http\n    server %s %s:%s", s.ServiceName, s.ServiceName, s.ServiceName, s.reconfigure.Port)
+	expectedData := fmt.Sprintf("backend %s-be\n    mode http\n    server %s %s:%s resolvers docker init-addr last,libc,none", s.ServiceName, s.ServiceName, s.ServiceName, s.reconfigure.Port)
 	writeBeTemplateOrig := writeBeTemplate
 	defer func() { writeBeTemplate = writeBeTemplateOrig }()
 	writeBeTemplate = func(filename string, data []byte, perm os.FileMode) error {
@@ -516,7 +850,7 @@ func (s ReconfigureTestSuite) Test_Execute_WritesBeTemplate_WhenModeIsSwarm() {
 	s.reconfigure.Port = "1234"
 	var actualFilename, actualData string
 	expectedFilename := fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.ServiceName)
-	expectedData := fmt.Sprintf("backend %s-be\n    mode http\n    server %s %s:%s", s.ServiceName, s.ServiceName, s.ServiceName, s.reconfigure.Port)
+	expectedData := fmt.Sprintf("backend %s-be\n    mode http\n    server %s %s:%s resolvers docker init-addr last,libc,none", s.ServiceName, s.ServiceName, s.ServiceName, s.reconfigure.Port)
 	writeBeTemplateOrig := writeBeTemplate
 	defer func() { writeBeTemplate = writeBeTemplateOrig }()
 	writeBeTemplate = func(filename string, data []byte, perm os.FileMode) error {
@@ -560,7 +894,7 @@ func (s ReconfigureTestSuite) Test_Execute_WritesBeTemplateAsAclName_WhenModeIsS
 	var actualFilename, actualData string
 	expectedFilename := fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.reconfigure.AclName)
 	expectedData := fmt.Sprintf(
-		"backend %s-be\n    mode http\n    server %s %s:%s",
+		"backend %s-be\n    mode http\n    server %s %s:%s resolvers docker init-addr last,libc,none",
 		s.reconfigure.AclName,
 		s.ServiceName,
 		s.ServiceName,
@@ -625,6 +959,20 @@ func (s ReconfigureTestSuite) Test_Execute_ReturnsError_WhenRegistrarableCreateC
 	s.Error(actual)
 }
 
+func (s ReconfigureTestSuite) Test_Execute_PutsService() {
+	servicesOrig := services
+	defer func() { services = servicesOrig }()
+	services = map[string]ServiceReconfigure{}
+	s.reconfigure.Mode = "swarm"
+
+	s.reconfigure.Execute([]string{})
+
+	actual := GetServices()
+	s.Equal(1, len(actual))
+	s.Equal(s.ServiceName, actual[0].ServiceName)
+	s.Equal(s.ServicePath, actual[0].ServicePath)
+}
+
 func (s ReconfigureTestSuite) Test_Execute_InvokesProxyCreateConfigFromTemplates() {
 	mockObj := getProxyMock("")
 	proxyOrig := haproxy.Instance
@@ -648,6 +996,67 @@ func (s ReconfigureTestSuite) Test_Execute_ReturnsError_WhenProxyFails() {
 	s.Error(err)
 }
 
+func (s ReconfigureTestSuite) Test_Execute_RestoresConfigs_WhenProxyFails() {
+	s.reconfigure.Mode = "swarm"
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(fmt.Errorf("This is an error"))
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	fePath := fmt.Sprintf("%s/%s-fe.cfg", s.TemplatesPath, s.ServiceName)
+	bePath := fmt.Sprintf("%s/%s-be.cfg", s.TemplatesPath, s.ServiceName)
+	readConfigFileOrig := readConfigFile
+	defer func() { readConfigFile = readConfigFileOrig }()
+	readConfigFile = func(filename string) ([]byte, error) {
+		if filename == fePath {
+			return []byte("previous fe content"), nil
+		}
+		return nil, fmt.Errorf("This is an error")
+	}
+	actualWritten := map[string]string{}
+	writeConfigFileOrig := writeConfigFile
+	defer func() { writeConfigFile = writeConfigFileOrig }()
+	writeConfigFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualWritten[filename] = string(data)
+		return nil
+	}
+	actualRemoved := []string{}
+	removeConfigFileOrig := removeConfigFile
+	defer func() { removeConfigFile = removeConfigFileOrig }()
+	removeConfigFile = func(name string) error {
+		actualRemoved = append(actualRemoved, name)
+		return nil
+	}
+
+	err := s.reconfigure.Execute([]string{})
+
+	s.Error(err)
+	s.Equal(map[string]string{fePath: "previous fe content"}, actualWritten)
+	s.Equal([]string{bePath}, actualRemoved)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s ReconfigureTestSuite) Test_Execute_RestoresService_WhenProxyFails() {
+	servicesOrig := services
+	defer func() { services = servicesOrig }()
+	previous := ServiceReconfigure{ServiceName: s.ServiceName, ServicePath: []string{"/previous"}}
+	services = map[string]ServiceReconfigure{s.ServiceName: previous}
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(fmt.Errorf("This is an error"))
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	removeConfigFileOrig := removeConfigFile
+	defer func() { removeConfigFile = removeConfigFileOrig }()
+	removeConfigFile = func(name string) error {
+		return nil
+	}
+
+	s.reconfigure.Execute([]string{})
+
+	s.Equal([]ServiceReconfigure{previous}, GetServices())
+}
+
 func (s ReconfigureTestSuite) Test_Execute_InvokesHaProxyReload() {
 	proxyOrig := haproxy.Instance
 	defer func() { haproxy.Instance = proxyOrig }()
@@ -659,6 +1068,20 @@ func (s ReconfigureTestSuite) Test_Execute_InvokesHaProxyReload() {
 	mock.AssertCalled(s.T(), "Reload")
 }
 
+func (s ReconfigureTestSuite) Test_Execute_SetsReloaded() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	for _, expected := range []bool{true, false} {
+		mockObj := getProxyMock("Reload")
+		mockObj.On("Reload").Return(expected, nil)
+		haproxy.Instance = mockObj
+
+		s.reconfigure.Execute([]string{})
+
+		s.Equal(expected, s.reconfigure.IsReloaded())
+	}
+}
+
 func (s *ReconfigureTestSuite) Test_Execute_PutsDataToConsul() {
 	s.SkipCheck = true
 	s.reconfigure.SkipCheck = true
@@ -751,6 +1174,187 @@ func (s *ReconfigureTestSuite) Test_Execute_ReturnsError_WhenAddressIsNotAccessi
 	//	s.NoError(err)
 }
 
+func (s *ReconfigureTestSuite) Test_Execute_RetriesLookup_WhenHostCannotBeResolved() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.LookupRetry = 3
+	s.reconfigure.LookupRetryInterval = 250
+	skipAddressValidationOrig := s.reconfigure.skipAddressValidation
+	defer func() { s.reconfigure.skipAddressValidation = skipAddressValidationOrig }()
+	s.reconfigure.skipAddressValidation = false
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	attempts := 0
+	lookupHost = func(host string) ([]string, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, fmt.Errorf("This is an error")
+		}
+		return []string{"10.0.0.1"}, nil
+	}
+	sleepOrig := sleep
+	defer func() { sleep = sleepOrig }()
+	actualSleeps := []time.Duration{}
+	sleep = func(d time.Duration) {
+		actualSleeps = append(actualSleeps, d)
+	}
+
+	err := s.reconfigure.Execute([]string{})
+
+	s.NoError(err)
+	s.Equal(3, attempts)
+	s.Equal([]time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, actualSleeps)
+}
+
+func (s *ReconfigureTestSuite) Test_Execute_ReturnsError_WhenLookupRetriesAreExhausted() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.LookupRetry = 2
+	skipAddressValidationOrig := s.reconfigure.skipAddressValidation
+	defer func() { s.reconfigure.skipAddressValidation = skipAddressValidationOrig }()
+	s.reconfigure.skipAddressValidation = false
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	attempts := 0
+	lookupHost = func(host string) ([]string, error) {
+		attempts++
+		return nil, fmt.Errorf("This is an error")
+	}
+	sleepOrig := sleep
+	defer func() { sleep = sleepOrig }()
+	sleep = func(d time.Duration) {}
+
+	err := s.reconfigure.Execute([]string{})
+
+	s.Error(err)
+	s.Equal(3, attempts)
+}
+
+func (s ReconfigureTestSuite) Test_Execute_DoesNotWriteOrReload_WhenDryRun() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.DryRun = true
+	mockObj := getProxyMock("")
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	written := false
+	writeFeTemplateOrig := writeFeTemplate
+	defer func() { writeFeTemplate = writeFeTemplateOrig }()
+	writeFeTemplate = func(filename string, data []byte, perm os.FileMode) error {
+		written = true
+		return nil
+	}
+	stdoutOrig := stdout
+	defer func() { stdout = stdoutOrig }()
+	stdout = &bytes.Buffer{}
+
+	err := s.reconfigure.Execute([]string{})
+
+	s.NoError(err)
+	s.False(written)
+	mockObj.AssertNotCalled(s.T(), "CreateConfigFromTemplates")
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
+// GetDiff
+
+func (s ReconfigureTestSuite) Test_GetDiff_InvokesProxyGetConfigDiff() {
+	s.reconfigure.Mode = "swarm"
+	s.reconfigure.AclName = "my-acl"
+	mockObj := getProxyMock("GetConfigDiff")
+	mockObj.On("GetConfigDiff", mock.Anything).Return("this is a diff", nil)
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	sr := s.reconfigure.ServiceReconfigure
+	front, back, _ := s.reconfigure.GetTemplates(&sr)
+	expected := haproxy.ConfigChanges{
+		Put: map[string]string{
+			"my-acl-fe.cfg": front,
+			"my-acl-be.cfg": back,
+		},
+	}
+
+	actual, err := s.reconfigure.GetDiff()
+
+	s.NoError(err)
+	s.Equal("this is a diff", actual)
+	mockObj.AssertCalled(s.T(), "GetConfigDiff", expected)
+}
+
+func (s ReconfigureTestSuite) Test_GetDiff_RendersConsulTemplatesOutsideTemplatesPath_WhenModeIsNotSwarm() {
+	mockObj := getProxyMock("GetConfigDiff")
+	mockObj.On("GetConfigDiff", mock.Anything).Return("this is a diff", nil)
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	registryMock := getRegistrarableMock("")
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = registryMock
+	readConfigFileOrig := readConfigFile
+	defer func() { readConfigFile = readConfigFileOrig }()
+	readConfigFile = func(filename string) ([]byte, error) {
+		return []byte("rendered " + filepath.Base(filename)), nil
+	}
+	expected := haproxy.ConfigChanges{
+		Put: map[string]string{
+			s.ServiceName + "-fe.cfg": "rendered " + s.ServiceName + "-fe.cfg",
+			s.ServiceName + "-be.cfg": "rendered " + s.ServiceName + "-be.cfg",
+		},
+	}
+
+	actual, err := s.reconfigure.GetDiff()
+
+	s.NoError(err)
+	s.Equal("this is a diff", actual)
+	mockObj.AssertCalled(s.T(), "GetConfigDiff", expected)
+	args := registryMock.Calls[0].Arguments.Get(0).(*registry.CreateConfigsArgs)
+	s.NotEqual(s.TemplatesPath, args.TemplatesPath)
+	s.Equal(s.ConsulTemplateFe, args.FeTemplate)
+	s.Equal(s.ConsulTemplateBe, args.BeTemplate)
+}
+
+func (s ReconfigureTestSuite) Test_GetDiff_ReturnsError_WhenConsulTemplatesCannotBeRendered() {
+	registryMock := getRegistrarableMock("CreateConfigs")
+	registryMock.On("CreateConfigs", mock.Anything).Return(fmt.Errorf("This is an error"))
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = registryMock
+
+	_, err := s.reconfigure.GetDiff()
+
+	s.Error(err)
+}
+
+// RecreateConfig
+
+func (s ReconfigureTestSuite) Test_RecreateConfig_CreatesConfigAndReloads() {
+	mockObj := getProxyMock("Reload")
+	mockObj.On("Reload").Return(true, nil)
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+
+	reloaded, err := RecreateConfig()
+
+	s.NoError(err)
+	s.True(reloaded)
+	mockObj.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	mockObj.AssertCalled(s.T(), "Reload")
+}
+
+func (s ReconfigureTestSuite) Test_RecreateConfig_ReturnsError_WhenConfigCannotBeCreated() {
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(fmt.Errorf("This is an error"))
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+
+	_, err := RecreateConfig()
+
+	s.Error(err)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
 // NewReconfigure
 
 func (s *ReconfigureTestSuite) Test_NewReconfigure_AddsBaseAndService() {
@@ -779,6 +1383,57 @@ func (s *ReconfigureTestSuite) Test_NewReconfigure_CreatesNewStruct() {
 
 // ReloadAllServices
 
+func (s *ReconfigureTestSuite) Test_ReloadAllServices_RestoresBackendOptions() {
+	values := map[string]string{
+		registry.TIMEOUT_SERVER_KEY:  "300",
+		registry.TIMEOUT_CONNECT_KEY: "2",
+		registry.TIMEOUT_QUEUE_KEY:   "10",
+		registry.TIMEOUT_TUNNEL_KEY:  "3600",
+		registry.MAX_CONN_KEY:        "50",
+		registry.FULL_CONN_KEY:       "500",
+		registry.BALANCE_KEY:         "leastconn",
+		registry.STICKY_SESSIONS_KEY: "true",
+		registry.TASK_ROUTING_KEY:    "true",
+		registry.MAX_TASKS_KEY:       "20",
+		registry.PRIORITY_KEY:        "-5",
+		registry.COLOR_WEIGHTS_KEY:   "blue:90,green:10",
+		registry.HEADER_MATCHES_KEY:  `[{"Name":"X-Api-Version","Value":"^2{1,2}$","Regexp":true}]`,
+		registry.METHODS_KEY:         "GET,POST",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := fmt.Sprintf("/v1/kv/%s/%s/", s.InstanceName, s.ServiceName)
+		if value, ok := values[strings.TrimPrefix(r.URL.Path, prefix)]; ok {
+			w.Write([]byte(value))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = getRegistrarableMock("")
+	c := make(chan ServiceReconfigure, 1)
+
+	s.reconfigure.getService([]string{srv.URL}, s.ServiceName, s.InstanceName, c)
+
+	actual := <-c
+	s.Equal(300, actual.TimeoutServer)
+	s.Equal(2, actual.TimeoutConnect)
+	s.Equal(10, actual.TimeoutQueue)
+	s.Equal(3600, actual.TimeoutTunnel)
+	s.Equal(50, actual.MaxConn)
+	s.Equal(500, actual.FullConn)
+	s.Equal("leastconn", actual.Balance)
+	s.True(actual.StickySessions)
+	s.True(actual.TaskRouting)
+	s.Equal(20, actual.MaxTasks)
+	s.Equal(-5, actual.Priority)
+	s.Equal([]ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}, actual.ColorWeights)
+	s.Equal([]RouteMatch{{Name: "X-Api-Version", Value: "^2{1,2}$", Regexp: true}}, actual.HeaderMatches)
+	s.Empty(actual.CookieMatches)
+	s.Equal([]string{"GET", "POST"}, actual.Methods)
+}
+
 func (s *ReconfigureTestSuite) Test_ReloadAllServices_ReturnsError_WhenFail() {
 	err := s.reconfigure.ReloadAllServices([]string{"this/address/does/not/exist"}, s.InstanceName, s.Mode, "")
 
@@ -821,7 +1476,7 @@ func (s *ReconfigureTestSuite) Test_ReloadAllServices_InvokesProxyReload() {
 
 func (s *ReconfigureTestSuite) Test_ReloadAllServices_ReturnsError_WhenProxyReloadFails() {
 	mockObj := getProxyMock("Reload")
-	mockObj.On("Reload").Return(fmt.Errorf("This is an error"))
+	mockObj.On("Reload").Return(false, fmt.Errorf("This is an error"))
 	proxyOrig := haproxy.Instance
 	defer func() { haproxy.Instance = proxyOrig }()
 	haproxy.Instance = mockObj
@@ -904,6 +1559,21 @@ func (m *ReconfigureMock) GetTemplates(sr ServiceReconfigure) (front, back strin
 	return params.String(0), params.String(1), params.Error(2)
 }
 
+func (m *ReconfigureMock) GetDiff() (string, error) {
+	params := m.Called()
+	return params.String(0), params.Error(1)
+}
+
+func (m *ReconfigureMock) IsReloaded() bool {
+	params := m.Called()
+	return params.Bool(0)
+}
+
+func (m *ReconfigureMock) GetConfigChange() ConfigChange {
+	params := m.Called()
+	return params.Get(0).(ConfigChange)
+}
+
 func getReconfigureMock(skipMethod string) *ReconfigureMock {
 	mockObj := new(ReconfigureMock)
 	if skipMethod != "Execute" {
@@ -918,6 +1588,15 @@ func getReconfigureMock(skipMethod string) *ReconfigureMock {
 	if skipMethod != "GetTemplates" {
 		mockObj.On("GetTemplates", mock.Anything).Return("", "", nil)
 	}
+	if skipMethod != "GetDiff" {
+		mockObj.On("GetDiff").Return("", nil)
+	}
+	if skipMethod != "IsReloaded" {
+		mockObj.On("IsReloaded").Return(false)
+	}
+	if skipMethod != "GetConfigChange" {
+		mockObj.On("GetConfigChange").Return(ConfigChange{})
+	}
 	return mockObj
 }
 
@@ -998,20 +1677,54 @@ func (m *ProxyMock) ReadConfig() (string, error) {
 	return params.String(0), params.Error(1)
 }
 
-func (m *ProxyMock) Reload() error {
+func (m *ProxyMock) GetConfigDiff(changes haproxy.ConfigChanges) (string, error) {
+	params := m.Called(changes)
+	return params.String(0), params.Error(1)
+}
+
+func (m *ProxyMock) Reload() (bool, error) {
 	params := m.Called()
-	return params.Error(0)
+	return params.Bool(0), params.Error(1)
 }
 
 func (m *ProxyMock) AddCert(certName string) {
 	m.Called(certName)
 }
 
+func (m *ProxyMock) RemoveCert(certName string) {
+	m.Called(certName)
+}
+
 func (m *ProxyMock) GetCerts() map[string]string {
 	params := m.Called()
 	return params.Get(0).(map[string]string)
 }
 
+func (m *ProxyMock) SetServerState(backend, server, state string) error {
+	params := m.Called(backend, server, state)
+	return params.Error(0)
+}
+
+func (m *ProxyMock) SetServerWeight(backend, server string, weight int) error {
+	params := m.Called(backend, server, weight)
+	return params.Error(0)
+}
+
+func (m *ProxyMock) GetServerState(backend, server string) (haproxy.ServerState, error) {
+	params := m.Called(backend, server)
+	return params.Get(0).(haproxy.ServerState), params.Error(1)
+}
+
+func (m *ProxyMock) GetProcessState() haproxy.ProcessState {
+	params := m.Called()
+	return params.Get(0).(haproxy.ProcessState)
+}
+
+func (m *ProxyMock) GetStats() ([]haproxy.Stats, error) {
+	params := m.Called()
+	return params.Get(0).([]haproxy.Stats), params.Error(1)
+}
+
 func getProxyMock(skipMethod string) *ProxyMock {
 	mockObj := new(ProxyMock)
 	if skipMethod != "RunCmd" {
@@ -1023,15 +1736,36 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "ReadConfig" {
 		mockObj.On("ReadConfig").Return("", nil)
 	}
+	if skipMethod != "GetConfigDiff" {
+		mockObj.On("GetConfigDiff", mock.Anything).Return("", nil)
+	}
 	if skipMethod != "Reload" {
-		mockObj.On("Reload").Return(nil)
+		mockObj.On("Reload").Return(false, nil)
 	}
 	if skipMethod != "AddCert" {
 		mockObj.On("AddCert", mock.Anything).Return(nil)
 	}
+	if skipMethod != "RemoveCert" {
+		mockObj.On("RemoveCert", mock.Anything).Return(nil)
+	}
 	if skipMethod != "GetCerts" {
 		mockObj.On("GetCerts").Return(map[string]string{})
 	}
+	if skipMethod != "SetServerState" {
+		mockObj.On("SetServerState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "SetServerWeight" {
+		mockObj.On("SetServerWeight", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "GetServerState" {
+		mockObj.On("GetServerState", mock.Anything, mock.Anything).Return(haproxy.ServerState{}, nil)
+	}
+	if skipMethod != "GetProcessState" {
+		mockObj.On("GetProcessState").Return(haproxy.ProcessState{Running: true})
+	}
+	if skipMethod != "GetStats" {
+		mockObj.On("GetStats").Return([]haproxy.Stats{}, nil)
+	}
 	return mockObj
 }
 
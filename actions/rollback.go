@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"fmt"
+
+	haproxy "../proxy"
+)
+
+type Rollbackable interface {
+	Executable
+	IsReloaded() bool
+	GetConfigChange() ConfigChange
+}
+
+// Rollback restores the snippets and the services of a stored configuration version
+type Rollback struct {
+	BaseReconfigure
+	Mode         string
+	Version      int
+	reloaded     bool
+	configChange ConfigChange
+}
+
+var NewRollback = func(baseData BaseReconfigure, mode string, version int) Rollbackable {
+	return &Rollback{BaseReconfigure: baseData, Mode: mode, Version: version}
+}
+
+// Execute restores the version, reloads the proxy, and stores the restored services in Consul
+func (m *Rollback) Execute(args []string) error {
+	version, err := GetConfigVersion(m.Version)
+	if err != nil {
+		return err
+	}
+	previous, err := m.restore(version)
+	if err != nil {
+		return err
+	}
+	reloaded, err := haproxy.Instance.Reload()
+	if err != nil {
+		return err
+	}
+	m.reloaded = reloaded
+	if len(m.ConsulAddresses) > 0 || !isSwarm(m.Mode) {
+		if err := m.persist(version.Services, previous); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsReloaded returns whether the proxy was reloaded by the last execution
+func (m *Rollback) IsReloaded() bool {
+	return m.reloaded
+}
+
+// GetConfigChange returns the proxy configuration before and after the last execution
+func (m *Rollback) GetConfigChange() ConfigChange {
+	return m.configChange
+}
+
+// restore replaces the snippets and the services with those of the version, creates the proxy configuration,
+// and adds the restored state to the history as a new version.
+// The current snippets and services are restored if the configuration cannot be created.
+// It returns the services that were replaced.
+func (m *Rollback) restore(version ConfigVersion) ([]ServiceReconfigure, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	logPrintf("Rolling back to the version %d", version.Version)
+	current, err := readSnippets(m.TemplatesPath)
+	if err != nil {
+		return nil, err
+	}
+	previous := listServices()
+	if err := m.writeSnippets(current, version.Snippets); err != nil {
+		m.writeSnippets(version.Snippets, current)
+		return nil, err
+	}
+	replaceServices(version.Services)
+	if m.configChange, err = createConfig(); err != nil {
+		logPrintf("Restoring the configuration replaced by the version %d", version.Version)
+		m.writeSnippets(version.Snippets, current)
+		replaceServices(previous)
+		return nil, err
+	}
+	if err := addConfigVersion(m.TemplatesPath, ConfigVersion{Action: HistoryActionRollback, RollbackVersion: version.Version}); err != nil {
+		logPrintf("Could not add the version to the history\n%s", err.Error())
+	}
+	return previous, nil
+}
+
+// writeSnippets removes the snippets that are not in the new set and writes the new ones
+func (m *Rollback) writeSnippets(oldSnippets, newSnippets map[string]string) error {
+	for name := range oldSnippets {
+		if _, ok := newSnippets[name]; !ok {
+			if err := removeConfigFile(fmt.Sprintf("%s/%s", m.TemplatesPath, name)); err != nil {
+				return err
+			}
+		}
+	}
+	for name, content := range newSnippets {
+		if err := writeConfigFile(fmt.Sprintf("%s/%s", m.TemplatesPath, name), []byte(content), 0664); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persist stores the restored services in Consul and deletes those that do not exist in the restored version
+func (m *Rollback) persist(restored, previous []ServiceReconfigure) error {
+	recon := Reconfigure{BaseReconfigure: m.BaseReconfigure}
+	names := map[string]bool{}
+	for _, sr := range restored {
+		names[sr.ServiceName] = true
+		if err := recon.putToConsul(m.ConsulAddresses, sr, m.InstanceName); err != nil {
+			return err
+		}
+	}
+	for _, sr := range previous {
+		if !names[sr.ServiceName] {
+			if err := registryInstance.DeleteService(m.ConsulAddresses, sr.ServiceName, m.InstanceName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// +build !integration
+
+package actions
+
+import (
+	haproxy "../proxy"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+type RollbackTestSuite struct {
+	suite.Suite
+	dir           string
+	templatesPath string
+	proxyMock     *ProxyMock
+	proxyOrig     haproxy.Proxy
+	registryMock  *RegistrarableMock
+}
+
+func (s *RollbackTestSuite) SetupTest() {
+	s.dir, _ = ioutil.TempDir("", "rollback")
+	s.templatesPath = fmt.Sprintf("%s/tmpl", s.dir)
+	os.Mkdir(s.templatesPath, 0755)
+	SetHistory(fmt.Sprintf("%s/history.json", s.dir), 10)
+	services = map[string]ServiceReconfigure{}
+	s.proxyOrig = haproxy.Instance
+	s.proxyMock = getProxyMock("")
+	haproxy.Instance = s.proxyMock
+	s.registryMock = getRegistrarableMock("")
+	registryInstance = s.registryMock
+
+	// Version 1 routes to service-1
+	s.writeSnippet("service-1-fe.cfg", "service-1 front")
+	PutService(ServiceReconfigure{ServiceName: "service-1"})
+	AddConfigVersion(s.templatesPath, "service-1", HistoryActionReconfigure)
+	// Version 2 routes to service-1 and service-2
+	s.writeSnippet("service-1-fe.cfg", "service-1 front changed")
+	s.writeSnippet("service-2-fe.cfg", "service-2 front")
+	PutService(ServiceReconfigure{ServiceName: "service-2"})
+	AddConfigVersion(s.templatesPath, "service-2", HistoryActionReconfigure)
+}
+
+func (s *RollbackTestSuite) TearDownTest() {
+	haproxy.Instance = s.proxyOrig
+	SetHistory("", 0)
+	os.RemoveAll(s.dir)
+}
+
+// Execute
+
+func (s *RollbackTestSuite) Test_Execute_RestoresSnippetsAndServices() {
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 1)
+
+	err := rollback.Execute([]string{})
+
+	s.NoError(err)
+	s.Equal("service-1 front", s.readSnippet("service-1-fe.cfg"))
+	_, err = os.Stat(fmt.Sprintf("%s/service-2-fe.cfg", s.templatesPath))
+	s.True(os.IsNotExist(err))
+	s.Equal([]ServiceReconfigure{{ServiceName: "service-1"}}, GetServices())
+	s.proxyMock.AssertCalled(s.T(), "CreateConfigFromTemplates")
+	s.proxyMock.AssertCalled(s.T(), "Reload")
+}
+
+func (s *RollbackTestSuite) Test_Execute_AddsRollbackVersion() {
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 1)
+
+	rollback.Execute([]string{})
+
+	actual, _ := GetConfigVersion(3)
+	s.Equal(HistoryActionRollback, actual.Action)
+	s.Equal(1, actual.RollbackVersion)
+	s.Equal(map[string]string{"service-1-fe.cfg": "service-1 front"}, actual.Snippets)
+}
+
+func (s *RollbackTestSuite) Test_Execute_AddsRollbackVersionBeforeReload() {
+	added := false
+	mockObj := getProxyMock("Reload")
+	mockObj.On("Reload").Run(func(args mock.Arguments) {
+		_, err := GetConfigVersion(3)
+		added = err == nil
+	}).Return(false, nil)
+	haproxy.Instance = mockObj
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 1)
+
+	rollback.Execute([]string{})
+
+	s.True(added)
+}
+
+func (s *RollbackTestSuite) Test_Execute_SetsReloaded() {
+	mockObj := getProxyMock("Reload")
+	mockObj.On("Reload").Return(true, nil)
+	haproxy.Instance = mockObj
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 1)
+
+	rollback.Execute([]string{})
+
+	s.True(rollback.IsReloaded())
+}
+
+func (s *RollbackTestSuite) Test_Execute_ReturnsError_WhenVersionDoesNotExist() {
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 5)
+
+	err := rollback.Execute([]string{})
+
+	s.Error(err)
+	s.proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *RollbackTestSuite) Test_Execute_RestoresCurrentSnippetsAndServices_WhenConfigCannotBeCreated() {
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates").Return(fmt.Errorf("This is an error"))
+	haproxy.Instance = mockObj
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 1)
+
+	err := rollback.Execute([]string{})
+
+	s.Error(err)
+	s.Equal("service-1 front changed", s.readSnippet("service-1-fe.cfg"))
+	s.Equal("service-2 front", s.readSnippet("service-2-fe.cfg"))
+	s.Len(GetServices(), 2)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *RollbackTestSuite) Test_Execute_PersistsRestoredServicesInConsul() {
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath, ConsulAddresses: []string{"http://consul"}, InstanceName: "proxy"}, "", 1)
+
+	rollback.Execute([]string{})
+
+	s.registryMock.AssertCalled(s.T(), "PutService", []string{"http://consul"}, "proxy", mock.Anything)
+	s.registryMock.AssertCalled(s.T(), "DeleteService", []string{"http://consul"}, "service-2", "proxy")
+	s.registryMock.AssertNotCalled(s.T(), "DeleteService", mock.Anything, "service-1", mock.Anything)
+}
+
+func (s *RollbackTestSuite) Test_Execute_DoesNotPersistServices_WhenSwarmModeWithoutConsul() {
+	rollback := NewRollback(BaseReconfigure{TemplatesPath: s.templatesPath}, "swarm", 1)
+
+	rollback.Execute([]string{})
+
+	s.registryMock.AssertNotCalled(s.T(), "PutService", mock.Anything, mock.Anything, mock.Anything)
+	s.registryMock.AssertNotCalled(s.T(), "DeleteService", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Util
+
+func (s *RollbackTestSuite) writeSnippet(name, content string) {
+	ioutil.WriteFile(fmt.Sprintf("%s/%s", s.templatesPath, name), []byte(content), 0664)
+}
+
+func (s *RollbackTestSuite) readSnippet(name string) string {
+	content, _ := ioutil.ReadFile(fmt.Sprintf("%s/%s", s.templatesPath, name))
+	return string(content)
+}
+
+// Suite
+
+func TestRollbackUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	suite.Run(t, new(RollbackTestSuite))
+}
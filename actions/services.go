@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	haproxy "../proxy"
+)
+
+const redactedPassword = "*****"
+
+var servicesMu = &sync.RWMutex{}
+var services = map[string]ServiceReconfigure{}
+
+// PutService stores the configuration of a service the proxy is routing to
+func PutService(sr ServiceReconfigure) {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+	services[sr.ServiceName] = sr
+}
+
+// RemoveService removes the service from the list of services the proxy is routing to
+func RemoveService(serviceName string) {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+	delete(services, serviceName)
+	haproxy.RetainCachedHashes(serviceName, nil)
+}
+
+func getService(serviceName string) (ServiceReconfigure, bool) {
+	servicesMu.RLock()
+	defer servicesMu.RUnlock()
+	sr, ok := services[serviceName]
+	return sr, ok
+}
+
+// GetServices returns all the services the proxy is routing to sorted by their names.
+// Passwords of the service users and certificates are redacted.
+func GetServices() []ServiceReconfigure {
+	list := listServices()
+	for i := range list {
+		list[i] = redactService(list[i])
+	}
+	return list
+}
+
+// listServices returns all the services the proxy is routing to sorted by their names
+func listServices() []ServiceReconfigure {
+	servicesMu.RLock()
+	defer servicesMu.RUnlock()
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []ServiceReconfigure{}
+	for _, name := range names {
+		list = append(list, services[name])
+	}
+	return list
+}
+
+// replaceServices replaces all the services the proxy is routing to
+func replaceServices(list []ServiceReconfigure) {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+	services = map[string]ServiceReconfigure{}
+	for _, sr := range list {
+		services[sr.ServiceName] = sr
+	}
+}
+
+// FindRouteConflict returns the name of another service that is routed to by exactly the same paths, domains, and matches.
+// Only one of such services could receive requests.
+func FindRouteConflict(sr ServiceReconfigure) (string, bool) {
+	if len(sr.ServicePath) == 0 {
+		return "", false
+	}
+	key := getRouteKey(sr)
+	for _, other := range listServices() {
+		if other.ServiceName != sr.ServiceName && len(other.ServicePath) > 0 && getRouteKey(other) == key {
+			return other.ServiceName, true
+		}
+	}
+	return "", false
+}
+
+// getRouteKey returns the conditions of the routes of the service in a form that does not depend on their order
+func getRouteKey(sr ServiceReconfigure) string {
+	pathType := sr.PathType
+	if len(pathType) == 0 {
+		pathType = "path_beg"
+	}
+	domains := []string{}
+	for _, domain := range sr.ServiceDomain {
+		domains = append(domains, strings.ToLower(strings.Trim(domain, "*")))
+	}
+	return fmt.Sprintf(
+		"%s|%s|%s|%s|%s|%s|%s",
+		pathType,
+		sortedJoin(sr.ServicePath),
+		sortedJoin(domains),
+		formatRouteMatches(sr.HeaderMatches),
+		formatRouteMatches(sr.CookieMatches),
+		formatRouteMatches(sr.QueryMatches),
+		sortedJoin(sr.Methods),
+	)
+}
+
+func sortedJoin(values []string) string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func redactService(sr ServiceReconfigure) ServiceReconfigure {
+	sr.Users = RedactUsers(sr.Users)
+	sr.ServiceCert = RedactCert(sr.ServiceCert)
+	return sr
+}
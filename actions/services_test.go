@@ -0,0 +1,127 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ServicesTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServicesTestSuite) SetupTest() {
+	services = map[string]ServiceReconfigure{}
+}
+
+// Suite
+
+func TestServicesUnitTestSuite(t *testing.T) {
+	s := new(ServicesTestSuite)
+	suite.Run(t, s)
+}
+
+// GetServices
+
+func (s ServicesTestSuite) Test_GetServices_ReturnsEmptySlice_WhenThereAreNoServices() {
+	actual := GetServices()
+
+	s.Equal([]ServiceReconfigure{}, actual)
+}
+
+func (s ServicesTestSuite) Test_GetServices_ReturnsServicesSortedByName() {
+	PutService(ServiceReconfigure{ServiceName: "service-2", ServicePath: []string{"/path-2"}})
+	PutService(ServiceReconfigure{ServiceName: "service-1", ServicePath: []string{"/path-1"}})
+
+	actual := GetServices()
+
+	s.Equal(
+		[]ServiceReconfigure{
+			{ServiceName: "service-1", ServicePath: []string{"/path-1"}},
+			{ServiceName: "service-2", ServicePath: []string{"/path-2"}},
+		},
+		actual,
+	)
+}
+
+func (s ServicesTestSuite) Test_GetServices_RedactsPasswords() {
+	users := []User{{Username: "user-1", Password: "pass-1"}}
+	PutService(ServiceReconfigure{ServiceName: "my-service", Users: users})
+
+	actual := GetServices()
+
+	s.Equal([]User{{Username: "user-1", Password: redactedPassword}}, actual[0].Users)
+	s.Equal("pass-1", users[0].Password)
+}
+
+func (s ServicesTestSuite) Test_GetServices_RedactsCerts() {
+	PutService(ServiceReconfigure{ServiceName: "my-service", ServiceCert: "my-cert with a private key"})
+
+	actual := GetServices()
+
+	s.Equal(redactedPassword, actual[0].ServiceCert)
+}
+
+// PutService
+
+func (s ServicesTestSuite) Test_PutService_ReplacesExistingService() {
+	PutService(ServiceReconfigure{ServiceName: "my-service", Port: "1111"})
+	PutService(ServiceReconfigure{ServiceName: "my-service", Port: "2222"})
+
+	actual := GetServices()
+
+	s.Equal(1, len(actual))
+	s.Equal("2222", actual[0].Port)
+}
+
+// RemoveService
+
+func (s ServicesTestSuite) Test_RemoveService_RemovesService() {
+	PutService(ServiceReconfigure{ServiceName: "service-1"})
+	PutService(ServiceReconfigure{ServiceName: "service-2"})
+
+	RemoveService("service-1")
+
+	actual := GetServices()
+	s.Equal(1, len(actual))
+	s.Equal("service-2", actual[0].ServiceName)
+}
+
+// FindRouteConflict
+
+func (s ServicesTestSuite) Test_FindRouteConflict_ReturnsService_WhenPathsAndDomainsAreTheSame() {
+	PutService(ServiceReconfigure{
+		ServiceName:   "service-1",
+		ServicePath:   []string{"/path-2", "/path-1"},
+		ServiceDomain: []string{".my-domain.com"},
+		PathType:      "path_beg",
+	})
+
+	actual, ok := FindRouteConflict(ServiceReconfigure{
+		ServiceName:   "service-2",
+		ServicePath:   []string{"/path-1", "/path-2"},
+		ServiceDomain: []string{"*.My-Domain.com"},
+	})
+
+	s.True(ok)
+	s.Equal("service-1", actual)
+}
+
+func (s ServicesTestSuite) Test_FindRouteConflict_ReturnsFalse_WhenRoutesDiffer() {
+	PutService(ServiceReconfigure{ServiceName: "service-1", ServicePath: []string{"/path-1"}})
+
+	for _, sr := range []ServiceReconfigure{
+		{ServiceName: "service-1", ServicePath: []string{"/path-1"}},
+		{ServiceName: "service-2", ServicePath: []string{"/path-1", "/path-2"}},
+		{ServiceName: "service-2", ServicePath: []string{"/path-1"}, PathType: "path_reg"},
+		{ServiceName: "service-2", ServicePath: []string{"/path-1"}, ServiceDomain: []string{"my-domain.com"}},
+		{ServiceName: "service-2", ServicePath: []string{"/path-1"}, HeaderMatches: []RouteMatch{{Name: "X-Canary", Value: "true"}}},
+		{ServiceName: "service-2", ServicePath: []string{"/path-1"}, Methods: []string{"GET"}},
+		{ServiceName: "service-2"},
+	} {
+		_, ok := FindRouteConflict(sr)
+
+		s.False(ok, sr)
+	}
+}
@@ -0,0 +1,68 @@
+package actions
+
+import (
+	"fmt"
+
+	haproxy "../proxy"
+)
+
+// usersSecretsDir is the directory with the Docker secrets. The users of a service are read from the dfp_users_<usersSecret> file.
+var usersSecretsDir = "/run/secrets"
+
+// LoadUsers adds the users from the secret specified with usersSecret, marks the passwords as encrypted when
+// usersPassEncrypted is set, and hashes the plaintext passwords when the HASH_PASSWORDS env var is true.
+func (sr *ServiceReconfigure) LoadUsers() error {
+	if len(sr.UsersSecret) > 0 {
+		if !nameRegexp.MatchString(sr.UsersSecret) {
+			return fmt.Errorf("The users secret %s must contain only letters, digits, underscores, dots, and dashes", sr.UsersSecret)
+		}
+		path := fmt.Sprintf("%s/dfp_users_%s", usersSecretsDir, sr.UsersSecret)
+		content, err := readSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("Could not read the users secret %s\n%s", path, err.Error())
+		}
+		users, invalid := haproxy.ParseUsers(string(content))
+		if len(invalid) > 0 {
+			return fmt.Errorf("The users %v in the secret %s must have the format user:password", invalid, path)
+		}
+		for _, user := range users {
+			sr.Users = append(sr.Users, User{Username: user[0], Password: user[1]})
+		}
+	}
+	usernames := []string{}
+	for i := range sr.Users {
+		usernames = append(usernames, sr.Users[i].Username)
+		if sr.UsersPassEncrypted {
+			sr.Users[i].PassEncrypted = true
+		} else if !sr.Users[i].PassEncrypted && haproxy.IsHashPasswords() {
+			hash, err := haproxy.GetCachedHash(sr.ServiceName, sr.Users[i].Username, sr.Users[i].Password)
+			if err != nil {
+				return err
+			}
+			sr.Users[i].Password = hash
+			sr.Users[i].PassEncrypted = true
+		}
+	}
+	haproxy.RetainCachedHashes(sr.ServiceName, usernames)
+	return nil
+}
+
+// RedactCert returns the certificate redacted since it can contain the private key
+func RedactCert(cert string) string {
+	if len(cert) == 0 {
+		return cert
+	}
+	return redactedPassword
+}
+
+// RedactUsers returns the users with their passwords, plaintext or hashed, redacted
+func RedactUsers(users []User) []User {
+	if len(users) == 0 {
+		return users
+	}
+	redacted := []User{}
+	for _, user := range users {
+		redacted = append(redacted, User{Username: user.Username, Password: redactedPassword, PassEncrypted: user.PassEncrypted})
+	}
+	return redacted
+}
@@ -0,0 +1,152 @@
+// +build !integration
+
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UsersTestSuite struct {
+	suite.Suite
+	hashPasswordsOrig string
+}
+
+func (s *UsersTestSuite) SetupTest() {
+	s.hashPasswordsOrig = os.Getenv("HASH_PASSWORDS")
+	os.Unsetenv("HASH_PASSWORDS")
+	readSecretFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+}
+
+func (s *UsersTestSuite) TearDownTest() {
+	os.Setenv("HASH_PASSWORDS", s.hashPasswordsOrig)
+}
+
+// LoadUsers
+
+func (s *UsersTestSuite) Test_LoadUsers_AddsUsersFromSecret() {
+	actualFilename := ""
+	readSecretFile = func(filename string) ([]byte, error) {
+		actualFilename = filename
+		return []byte("user-2:pass-2\nuser-3:pass-3\n"), nil
+	}
+	sr := ServiceReconfigure{Users: []User{{Username: "user-1", Password: "pass-1"}}, UsersSecret: "my-service"}
+
+	err := sr.LoadUsers()
+
+	s.NoError(err)
+	s.Equal("/run/secrets/dfp_users_my-service", actualFilename)
+	s.Equal([]User{
+		{Username: "user-1", Password: "pass-1"},
+		{Username: "user-2", Password: "pass-2"},
+		{Username: "user-3", Password: "pass-3"},
+	}, sr.Users)
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_ReturnsError_WhenSecretCannotBeRead() {
+	sr := ServiceReconfigure{UsersSecret: "my-service"}
+
+	s.Error(sr.LoadUsers())
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_ReturnsError_WhenSecretNameIsNotValid() {
+	readSecretFile = func(filename string) ([]byte, error) {
+		return []byte("user-1:pass-1"), nil
+	}
+	sr := ServiceReconfigure{UsersSecret: "../dfp_auth_tokens"}
+
+	s.Error(sr.LoadUsers())
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_ReturnsError_WhenSecretContainsUserWithoutPassword() {
+	readSecretFile = func(filename string) ([]byte, error) {
+		return []byte("user-1"), nil
+	}
+	sr := ServiceReconfigure{UsersSecret: "my-service"}
+
+	s.Error(sr.LoadUsers())
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_MarksPasswordsAsEncrypted_WhenUsersPassEncryptedIsTrue() {
+	sr := ServiceReconfigure{Users: []User{{Username: "user-1", Password: "$6$salt$hash"}}, UsersPassEncrypted: true}
+
+	sr.LoadUsers()
+
+	s.Equal([]User{{Username: "user-1", Password: "$6$salt$hash", PassEncrypted: true}}, sr.Users)
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_HashesPlaintextPasswords_WhenHashPasswordsIsTrue() {
+	os.Setenv("HASH_PASSWORDS", "true")
+	sr := ServiceReconfigure{Users: []User{
+		{Username: "user-1", Password: "pass-1"},
+		{Username: "user-2", Password: "$6$salt$hash", PassEncrypted: true},
+	}}
+
+	err := sr.LoadUsers()
+
+	s.NoError(err)
+	s.True(sr.Users[0].PassEncrypted)
+	s.True(strings.HasPrefix(sr.Users[0].Password, "$6$"))
+	s.Equal(User{Username: "user-2", Password: "$6$salt$hash", PassEncrypted: true}, sr.Users[1])
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_ReusesHashes_WhenHashPasswordsIsTrue() {
+	os.Setenv("HASH_PASSWORDS", "true")
+	sr1 := ServiceReconfigure{ServiceName: "my-service", Users: []User{{Username: "user-1", Password: "pass-1"}}}
+	sr2 := ServiceReconfigure{ServiceName: "my-service", Users: []User{{Username: "user-1", Password: "pass-1"}}}
+
+	sr1.LoadUsers()
+	sr2.LoadUsers()
+
+	s.Equal(sr1.Users, sr2.Users)
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_ForgetsHashesOfUsersThatAreNotConfigured() {
+	os.Setenv("HASH_PASSWORDS", "true")
+	sr1 := ServiceReconfigure{ServiceName: "my-service", Users: []User{{Username: "user-1", Password: "pass-1"}, {Username: "user-2", Password: "pass-2"}}}
+	sr2 := ServiceReconfigure{ServiceName: "my-service", Users: []User{{Username: "user-1", Password: "pass-1"}}}
+	sr3 := ServiceReconfigure{ServiceName: "my-service", Users: []User{{Username: "user-2", Password: "pass-2"}}}
+
+	sr1.LoadUsers()
+	sr2.LoadUsers()
+	sr3.LoadUsers()
+
+	s.Equal(sr1.Users[0], sr2.Users[0])
+	s.NotEqual(sr1.Users[1].Password, sr3.Users[0].Password)
+}
+
+func (s *UsersTestSuite) Test_LoadUsers_DoesNotHashPasswords_WhenHashPasswordsIsNotSet() {
+	sr := ServiceReconfigure{Users: []User{{Username: "user-1", Password: "pass-1"}}}
+
+	sr.LoadUsers()
+
+	s.Equal([]User{{Username: "user-1", Password: "pass-1"}}, sr.Users)
+}
+
+// RedactUsers
+
+func (s *UsersTestSuite) Test_RedactUsers_RedactsPlaintextAndHashedPasswords() {
+	users := []User{{Username: "user-1", Password: "pass-1"}, {Username: "user-2", Password: "$6$salt$hash", PassEncrypted: true}}
+
+	actual := RedactUsers(users)
+
+	s.Equal([]User{
+		{Username: "user-1", Password: redactedPassword},
+		{Username: "user-2", Password: redactedPassword, PassEncrypted: true},
+	}, actual)
+	s.Equal("pass-1", users[0].Password)
+}
+
+// Suite
+
+func TestUsersUnitTestSuite(t *testing.T) {
+	readSecretFileOrig := readSecretFile
+	defer func() { readSecretFile = readSecretFileOrig }()
+	suite.Run(t, new(UsersTestSuite))
+}
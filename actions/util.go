@@ -2,11 +2,14 @@ package actions
 
 import (
 	"../registry"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 )
 
 type Executable interface {
@@ -18,9 +21,16 @@ func isSwarm(mode string) bool {
 }
 
 var lookupHost = net.LookupHost
+var sleep = time.Sleep
 var logPrintf = log.Printf
 var httpGet = http.Get
 var registryInstance registry.Registrarable = registry.Consul{}
 var writeFeTemplate = ioutil.WriteFile
 var writeBeTemplate = ioutil.WriteFile
 var readTemplateFile = ioutil.ReadFile
+var readConfigFile = ioutil.ReadFile
+var writeConfigFile = ioutil.WriteFile
+var removeConfigFile = os.Remove
+var readTemplatesDir = ioutil.ReadDir
+var readSecretFile = ioutil.ReadFile
+var stdout io.Writer = os.Stdout
@@ -0,0 +1,219 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes a field of the service configuration that is not valid
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+// PathTypes are the HAProxy path match types that can be used as the path type of a service
+var PathTypes = []string{"path", "path_beg", "path_dir", "path_dom", "path_end", "path_len", "path_reg", "path_sub"}
+
+// HealthCheckMethods are the HTTP methods that can be used by the health checks of a service
+var HealthCheckMethods = []string{"GET", "HEAD", "OPTIONS", "POST"}
+
+// BalanceAlgorithms are the HAProxy load-balancing algorithms that can be used by a service.
+// The hdr(<name>) algorithm is accepted as well.
+var BalanceAlgorithms = []string{"roundrobin", "static-rr", "leastconn", "first", "source", "uri"}
+
+var balanceHdrRegexp = regexp.MustCompile(`^hdr\([a-zA-Z0-9_-]+\)$`)
+
+// HttpMethods are the methods that can be used as a routing condition of a service
+var HttpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// nameRegexp matches the names that can be used in ACL and backend names
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+var domainRegexp = regexp.MustCompile(`^(\*\.?)?([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// Validate returns the fields of the service configuration that would produce an invalid proxy configuration.
+// Mandatory fields are not checked.
+func (sr ServiceReconfigure) Validate() []ValidationError {
+	errs := []ValidationError{}
+	add := func(field, format string, v ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Message: fmt.Sprintf(format, v...)})
+	}
+	if len(sr.ServiceName) > 0 && !nameRegexp.MatchString(sr.ServiceName) {
+		add("serviceName", "The service name %s must start with a letter or a digit and contain only letters, digits, underscores, dots, and dashes", sr.ServiceName)
+	}
+	if len(sr.AclName) > 0 && !nameRegexp.MatchString(sr.AclName) {
+		add("aclName", "The ACL name %s must start with a letter or a digit and contain only letters, digits, underscores, dots, and dashes", sr.AclName)
+	}
+	if len(sr.Port) > 0 {
+		if port, err := strconv.Atoi(sr.Port); err != nil || !isValidPort(port) {
+			add("port", "The port %s must be a number between 1 and 65535", sr.Port)
+		}
+	}
+	if sr.HttpsPort != 0 && !isValidPort(sr.HttpsPort) {
+		add("httpsPort", "The HTTPS port %d must be a number between 1 and 65535", sr.HttpsPort)
+	}
+	if len(sr.PathType) > 0 && !isPathType(sr.PathType) {
+		add("pathType", "The path type %s is not supported. Supported types are %s", sr.PathType, strings.Join(PathTypes, ", "))
+	}
+	for _, path := range sr.ServicePath {
+		if len(path) == 0 || strings.ContainsAny(path, " \t\r\n") || hasTemplateSyntax(path) {
+			add("servicePath", "The path %q must not be empty nor contain whitespace, <, {{, or }}", path)
+		}
+	}
+	for _, domain := range sr.ServiceDomain {
+		if !domainRegexp.MatchString(domain) {
+			add("serviceDomain", "The domain %s is not a valid host name", domain)
+		}
+	}
+	if len(sr.ReqRepSearch) > 0 {
+		if _, err := regexp.Compile(sr.ReqRepSearch); err != nil {
+			add("reqRepSearch", "The regular expression %s does not compile: %s", sr.ReqRepSearch, err.Error())
+		}
+	}
+	for _, text := range []struct {
+		field string
+		value string
+	}{
+		{"reqRepSearch", sr.ReqRepSearch},
+		{"reqRepReplace", sr.ReqRepReplace},
+		{"outboundHostname", sr.OutboundHostname},
+		{"serviceColor", sr.ServiceColor},
+	} {
+		if hasTemplateSyntax(text.value) {
+			add(text.field, "The %s %q must not contain <, {{, or }}", text.field, text.value)
+		}
+	}
+	for _, user := range sr.Users {
+		if len(user.Username) == 0 || len(user.Password) == 0 || strings.ContainsAny(user.Username+user.Password, " \t\r\n") {
+			add("users", "The user %s must have a name and a password without whitespace", user.Username)
+		}
+	}
+	if len(sr.HealthCheckPath) > 0 && (!strings.HasPrefix(sr.HealthCheckPath, "/") || strings.ContainsAny(sr.HealthCheckPath, " \t\r\n") || hasTemplateSyntax(sr.HealthCheckPath)) {
+		add("healthCheckPath", "The health check path %q must start with a slash and must not contain whitespace, <, {{, or }}", sr.HealthCheckPath)
+	}
+	if len(sr.HealthCheckMethod) > 0 && !contains(HealthCheckMethods, sr.HealthCheckMethod) {
+		add("healthCheckMethod", "The health check method %s is not supported. Supported methods are %s", sr.HealthCheckMethod, strings.Join(HealthCheckMethods, ", "))
+	}
+	if sr.HealthCheckStatus != 0 {
+		if sr.HealthCheckStatus < 100 || sr.HealthCheckStatus > 599 {
+			add("healthCheckStatus", "The health check status %d must be a number between 100 and 599", sr.HealthCheckStatus)
+		} else if len(sr.HealthCheckPath) == 0 {
+			add("healthCheckStatus", "The health check status requires the health check path")
+		}
+	}
+	for field, value := range map[string]int{
+		"healthCheckInterval": sr.HealthCheckInterval,
+		"healthCheckRise":     sr.HealthCheckRise,
+		"healthCheckFall":     sr.HealthCheckFall,
+		"timeoutServer":       sr.TimeoutServer,
+		"timeoutConnect":      sr.TimeoutConnect,
+		"timeoutQueue":        sr.TimeoutQueue,
+		"timeoutTunnel":       sr.TimeoutTunnel,
+		"maxConn":             sr.MaxConn,
+		"fullConn":            sr.FullConn,
+		"maxTasks":            sr.MaxTasks,
+	} {
+		if value < 0 {
+			add(field, "The %s %d must not be negative", field, value)
+		}
+	}
+	if len(sr.Balance) > 0 && !contains(BalanceAlgorithms, sr.Balance) && !balanceHdrRegexp.MatchString(sr.Balance) {
+		add("balance", "The balance algorithm %s is not supported. Supported algorithms are %s, and hdr(<name>)", sr.Balance, strings.Join(BalanceAlgorithms, ", "))
+	}
+	if sr.TaskRouting && len(sr.OutboundHostname) > 0 {
+		add("taskRouting", "The tasks of the outbound hostname %s cannot be resolved", sr.OutboundHostname)
+	}
+	errs = append(errs, validateColorWeights(sr.ColorWeights)...)
+	if len(sr.ColorWeights) > 0 && len(sr.OutboundHostname) > 0 {
+		add("colorWeights", "The colors of the outbound hostname %s cannot be resolved", sr.OutboundHostname)
+	}
+	for field, matches := range map[string][]RouteMatch{
+		"headerMatches": sr.HeaderMatches,
+		"cookieMatches": sr.CookieMatches,
+		"queryMatches":  sr.QueryMatches,
+	} {
+		errs = append(errs, validateRouteMatches(field, matches)...)
+	}
+	for _, method := range sr.Methods {
+		if !contains(HttpMethods, method) {
+			add("methods", "The method %s is not supported. Supported methods are %s", method, strings.Join(HttpMethods, ", "))
+		}
+	}
+	if sr.HealthCheckPort != 0 && !isValidPort(sr.HealthCheckPort) {
+		add("healthCheckPort", "The health check port %d must be a number between 1 and 65535", sr.HealthCheckPort)
+	}
+	return errs
+}
+
+// validateColorWeights returns the errors of the colors that cannot be used in server names and of the weights HAProxy does not accept
+func validateColorWeights(weights []ColorWeight) []ValidationError {
+	errs := []ValidationError{}
+	seen := map[string]bool{}
+	for _, cw := range weights {
+		if !nameRegexp.MatchString(cw.Color) || seen[cw.Color] {
+			errs = append(errs, ValidationError{
+				Field:   "colorWeights",
+				Message: fmt.Sprintf("The color %s must be unique, start with a letter or a digit, and contain only letters, digits, underscores, dots, and dashes", cw.Color),
+			})
+		}
+		if cw.Weight < 0 || cw.Weight > 256 {
+			errs = append(errs, ValidationError{
+				Field:   "colorWeights",
+				Message: fmt.Sprintf("The weight %d of the color %s must be a number between 0 and 256", cw.Weight, cw.Color),
+			})
+		}
+		seen[cw.Color] = true
+	}
+	return errs
+}
+
+// validateRouteMatches returns the errors of the matches that would break the ACL line or the template it is written to
+func validateRouteMatches(field string, matches []RouteMatch) []ValidationError {
+	errs := []ValidationError{}
+	for _, m := range matches {
+		if !nameRegexp.MatchString(m.Name) {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("The name %s must start with a letter or a digit and contain only letters, digits, underscores, dots, and dashes", m.Name),
+			})
+		}
+		if len(m.Value) == 0 || strings.ContainsAny(m.Value, " \t\r\n") || hasTemplateSyntax(m.Value) {
+			errs = append(errs, ValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("The value %q of %s must not be empty nor contain whitespace, <, {{, or }}", m.Value, m.Name),
+			})
+		} else if m.Regexp {
+			if _, err := regexp.Compile(m.Value); err != nil {
+				errs = append(errs, ValidationError{
+					Field:   field,
+					Message: fmt.Sprintf("The regular expression %s of %s does not compile: %s", m.Value, m.Name, err.Error()),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// hasTemplateSyntax returns whether the value contains the sequences that would be interpreted when the snippet
+// it is written to is rendered
+func hasTemplateSyntax(value string) bool {
+	return strings.Contains(value, "<") || strings.Contains(value, "{{") || strings.Contains(value, "}}")
+}
+
+func isValidPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
+func isPathType(pathType string) bool {
+	return contains(PathTypes, pathType)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
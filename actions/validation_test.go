@@ -0,0 +1,315 @@
+// +build !integration
+
+package actions
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ValidationTestSuite struct {
+	suite.Suite
+	sr ServiceReconfigure
+}
+
+func (s *ValidationTestSuite) SetupTest() {
+	s.sr = ServiceReconfigure{
+		ServiceName:   "my-service_1.0",
+		ServicePath:   []string{"/my/path", "/my/other/path"},
+		ServiceDomain: []string{"my-domain.com", "*.my-domain.com", "*my-other-domain.com"},
+		Port:          "8080",
+		HttpsPort:     8443,
+		PathType:      "path_reg",
+		ReqRepSearch:  "^([^\\ ]*)\\ /api/v1/(.*)",
+		Users:         []User{{Username: "my-user", Password: "my-pass"}},
+	}
+}
+
+// Validate
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsNoErrors_WhenServiceIsValid() {
+	s.Empty(s.sr.Validate())
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsNoErrors_WhenOptionalFieldsAreEmpty() {
+	sr := ServiceReconfigure{ServiceName: "my-service", ServicePath: []string{"/my/path"}}
+
+	s.Empty(sr.Validate())
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenServiceNameHasInvalidCharacters() {
+	for _, name := range []string{"my service", "-my-service", "my-service;", "my/service"} {
+		s.sr.ServiceName = name
+
+		s.Equal("serviceName", s.getOnlyField(s.sr.Validate()), name)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenAclNameHasInvalidCharacters() {
+	s.sr.AclName = "my acl"
+
+	s.Equal("aclName", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenPortIsNotValid() {
+	for _, port := range []string{"0", "65536", "http", "-1"} {
+		s.sr.Port = port
+
+		s.Equal("port", s.getOnlyField(s.sr.Validate()), port)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHttpsPortIsOutOfRange() {
+	for _, port := range []int{-1, 65536} {
+		s.sr.HttpsPort = port
+
+		s.Equal("httpsPort", s.getOnlyField(s.sr.Validate()), port)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenPathTypeIsNotSupported() {
+	s.sr.PathType = "path_beg if TRUE"
+
+	s.Equal("pathType", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenPathIsEmptyOrContainsWhitespace() {
+	for _, path := range []string{"", "/my path"} {
+		s.sr.ServicePath = []string{path}
+
+		s.Equal("servicePath", s.getOnlyField(s.sr.Validate()), path)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenPathContainsTemplateSyntax() {
+	for _, path := range []string{"/x{{.Foo}}", "/x}}", "/<x>"} {
+		s.sr.ServicePath = []string{path}
+
+		s.Equal("servicePath", s.getOnlyField(s.sr.Validate()), path)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenDomainIsNotValid() {
+	for _, domain := range []string{"my domain.com", "-my-domain.com", "my_domain.com", "my-domain..com", "my.*.com", "{{.Foo}}.com"} {
+		s.sr.ServiceDomain = []string{domain}
+
+		s.Equal("serviceDomain", s.getOnlyField(s.sr.Validate()), domain)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenReqRepSearchDoesNotCompile() {
+	s.sr.ReqRepSearch = "^(/api"
+
+	s.Equal("reqRepSearch", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenRenderedTextContainsTemplateSyntax() {
+	for field, set := range map[string]func(sr *ServiceReconfigure){
+		"reqRepSearch":     func(sr *ServiceReconfigure) { sr.ReqRepSearch = "^/{{.Foo}}" },
+		"reqRepReplace":    func(sr *ServiceReconfigure) { sr.ReqRepReplace = "/{{.Foo}}" },
+		"outboundHostname": func(sr *ServiceReconfigure) { sr.OutboundHostname = "host}}" },
+		"serviceColor":     func(sr *ServiceReconfigure) { sr.ServiceColor = "<blue>" },
+		"aclName":          func(sr *ServiceReconfigure) { sr.AclName = "acl{{.Foo}}" },
+		"port":             func(sr *ServiceReconfigure) { sr.Port = "{{.Port}}" },
+	} {
+		sr := s.sr
+		set(&sr)
+
+		s.Equal(field, s.getOnlyField(sr.Validate()), field)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenUserHasNoPassword() {
+	s.sr.Users = []User{{Username: "my-user"}}
+
+	s.Equal("users", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenPasswordContainsWhitespace() {
+	s.sr.Users = []User{{Username: "my-user", Password: "my pass"}}
+
+	s.Equal("users", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsNoErrors_WhenHealthCheckIsValid() {
+	s.sr.HealthCheckPath = "/health?full=true"
+	s.sr.HealthCheckMethod = "HEAD"
+	s.sr.HealthCheckStatus = 204
+	s.sr.HealthCheckInterval = 2000
+	s.sr.HealthCheckRise = 2
+	s.sr.HealthCheckFall = 3
+	s.sr.HealthCheckPort = 8081
+
+	s.Empty(s.sr.Validate())
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHealthCheckPathIsNotValid() {
+	for _, path := range []string{"health", "/my health", "/<health>", "/{{.ServiceName}}"} {
+		s.sr.HealthCheckPath = path
+
+		s.Equal("healthCheckPath", s.getOnlyField(s.sr.Validate()), path)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHealthCheckMethodIsNotSupported() {
+	s.sr.HealthCheckMethod = "DELETE"
+
+	s.Equal("healthCheckMethod", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHealthCheckStatusIsOutOfRange() {
+	s.sr.HealthCheckPath = "/health"
+	for _, status := range []int{99, 600} {
+		s.sr.HealthCheckStatus = status
+
+		s.Equal("healthCheckStatus", s.getOnlyField(s.sr.Validate()), status)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHealthCheckStatusIsSetWithoutPath() {
+	s.sr.HealthCheckStatus = 200
+
+	s.Equal("healthCheckStatus", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHealthCheckNumbersAreNegative() {
+	s.sr.HealthCheckInterval = -1
+	s.sr.HealthCheckRise = -1
+	s.sr.HealthCheckFall = -1
+
+	s.Len(s.sr.Validate(), 3)
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenTimeoutsAndConnectionLimitsAreNegative() {
+	s.sr.TimeoutServer = -1
+	s.sr.TimeoutConnect = -1
+	s.sr.TimeoutQueue = -1
+	s.sr.TimeoutTunnel = -1
+	s.sr.MaxConn = -1
+	s.sr.FullConn = -1
+
+	s.Len(s.sr.Validate(), 6)
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsNoErrors_WhenBalanceIsSupported() {
+	for _, balance := range []string{"roundrobin", "leastconn", "source", "uri", "hdr(X-Tenant)"} {
+		s.sr.Balance = balance
+
+		s.Empty(s.sr.Validate(), balance)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenBalanceIsNotSupported() {
+	for _, balance := range []string{"random", "hdr()", "hdr(X Tenant)", "leastconn if TRUE"} {
+		s.sr.Balance = balance
+
+		s.Equal("balance", s.getOnlyField(s.sr.Validate()), balance)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenTaskRoutingIsUsedWithOutboundHostname() {
+	s.sr.TaskRouting = true
+	s.sr.OutboundHostname = "machine123.internal.ecme.com"
+
+	s.Equal("taskRouting", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenMaxTasksIsNegative() {
+	s.sr.MaxTasks = -1
+
+	s.Equal("maxTasks", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsNoErrors_WhenColorWeightsAreValid() {
+	s.sr.ColorWeights = []ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}
+
+	s.Empty(s.sr.Validate())
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenColorWeightsAreNotValid() {
+	for _, weights := range [][]ColorWeight{
+		{{Color: "blue green", Weight: 10}},
+		{{Color: "blue", Weight: 10}, {Color: "blue", Weight: 20}},
+		{{Color: "blue", Weight: -1}},
+		{{Color: "blue", Weight: 257}},
+	} {
+		s.sr.ColorWeights = weights
+
+		s.Equal("colorWeights", s.getOnlyField(s.sr.Validate()), weights)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenColorWeightsAreUsedWithOutboundHostname() {
+	s.sr.ColorWeights = []ColorWeight{{Color: "blue", Weight: 90}}
+	s.sr.OutboundHostname = "machine123.internal.ecme.com"
+
+	s.Equal("colorWeights", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsNoErrors_WhenMatchesAreValid() {
+	s.sr.HeaderMatches = []RouteMatch{{Name: "X-Canary", Value: "true"}, {Name: "X-Api-Version", Value: "^2{1,2}$", Regexp: true}}
+	s.sr.CookieMatches = []RouteMatch{{Name: "tester", Value: "yes"}}
+	s.sr.QueryMatches = []RouteMatch{{Name: "beta", Value: "1"}}
+	s.sr.Methods = []string{"GET", "POST"}
+
+	s.Empty(s.sr.Validate())
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenMatchIsNotValid() {
+	for _, match := range []RouteMatch{
+		{Name: "X Canary", Value: "true"},
+		{Name: "X-Canary", Value: ""},
+		{Name: "X-Canary", Value: "true if TRUE"},
+		{Name: "X-Canary", Value: "<b>"},
+		{Name: "X-Canary", Value: "{{.ServiceName}}"},
+		{Name: "X-Canary", Value: "^(2", Regexp: true},
+	} {
+		s.sr.HeaderMatches = []RouteMatch{match}
+
+		s.Equal("headerMatches", s.getOnlyField(s.sr.Validate()), match)
+	}
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenCookieOrQueryMatchIsNotValid() {
+	s.sr.CookieMatches = []RouteMatch{{Name: "tester", Value: "a b"}}
+	s.sr.QueryMatches = []RouteMatch{{Name: "beta;", Value: "1"}}
+
+	s.Len(s.sr.Validate(), 2)
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenMethodIsNotSupported() {
+	s.sr.Methods = []string{"GET", "get", "FETCH"}
+
+	s.Len(s.sr.Validate(), 2)
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsError_WhenHealthCheckPortIsOutOfRange() {
+	s.sr.HealthCheckPort = 65536
+
+	s.Equal("healthCheckPort", s.getOnlyField(s.sr.Validate()))
+}
+
+func (s *ValidationTestSuite) Test_Validate_ReturnsAllErrors() {
+	s.sr.ServiceName = "my service"
+	s.sr.Port = "http"
+	s.sr.PathType = "unknown"
+
+	actual := s.sr.Validate()
+
+	s.Len(actual, 3)
+}
+
+// Util
+
+func (s *ValidationTestSuite) getOnlyField(errs []ValidationError) string {
+	if len(errs) != 1 {
+		return ""
+	}
+	return errs[0].Field
+}
+
+// Suite
+
+func TestValidationUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ValidationTestSuite))
+}
@@ -191,6 +191,19 @@ func (s ArgsTestSuite) Test_Parse_ReconfigureDefaultsToEnvVars() {
 	}
 }
 
+func (s ArgsTestSuite) Test_Parse_ParsesReconfigureDryRun() {
+	os.Args = []string{
+		"myProgram", "reconfigure",
+		"--service-name", "myService",
+		"--dry-run",
+	}
+	defer func() { actions.ReconfigureInstance.DryRun = false }()
+
+	Args{}.Parse()
+
+	s.True(actions.ReconfigureInstance.DryRun)
+}
+
 // Parse > Remove
 
 func (s ArgsTestSuite) Test_Parse_ParsesRemoveLongArgsStrings() {
@@ -257,6 +270,15 @@ func (s ArgsTestSuite) Test_Parse_RemoveDefaultsToEnvVars() {
 	}
 }
 
+func (s ArgsTestSuite) Test_Parse_ParsesRemoveDryRun() {
+	os.Args = []string{"myProgram", "remove", "--service-name", "myService", "--dry-run"}
+	defer func() { remove.DryRun = false }()
+
+	Args{}.Parse()
+
+	s.True(remove.DryRun)
+}
+
 // Parse > Server
 
 func (s ArgsTestSuite) Test_Parse_ParsesServerLongArgs() {
@@ -448,20 +470,54 @@ func (m *ProxyMock) ReadConfig() (string, error) {
 	return params.String(0), params.Error(1)
 }
 
-func (m *ProxyMock) Reload() error {
+func (m *ProxyMock) GetConfigDiff(changes proxy.ConfigChanges) (string, error) {
+	params := m.Called(changes)
+	return params.String(0), params.Error(1)
+}
+
+func (m *ProxyMock) Reload() (bool, error) {
 	params := m.Called()
-	return params.Error(0)
+	return params.Bool(0), params.Error(1)
 }
 
 func (m *ProxyMock) AddCert(certName string) {
 	m.Called(certName)
 }
 
+func (m *ProxyMock) RemoveCert(certName string) {
+	m.Called(certName)
+}
+
 func (m *ProxyMock) GetCerts() map[string]string {
 	params := m.Called()
 	return params.Get(0).(map[string]string)
 }
 
+func (m *ProxyMock) SetServerState(backend, server, state string) error {
+	params := m.Called(backend, server, state)
+	return params.Error(0)
+}
+
+func (m *ProxyMock) SetServerWeight(backend, server string, weight int) error {
+	params := m.Called(backend, server, weight)
+	return params.Error(0)
+}
+
+func (m *ProxyMock) GetServerState(backend, server string) (proxy.ServerState, error) {
+	params := m.Called(backend, server)
+	return params.Get(0).(proxy.ServerState), params.Error(1)
+}
+
+func (m *ProxyMock) GetProcessState() proxy.ProcessState {
+	params := m.Called()
+	return params.Get(0).(proxy.ProcessState)
+}
+
+func (m *ProxyMock) GetStats() ([]proxy.Stats, error) {
+	params := m.Called()
+	return params.Get(0).([]proxy.Stats), params.Error(1)
+}
+
 func getProxyMock(skipMethod string) *ProxyMock {
 	mockObj := new(ProxyMock)
 	if skipMethod != "RunCmd" {
@@ -473,14 +529,35 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "ReadConfig" {
 		mockObj.On("ReadConfig").Return("", nil)
 	}
+	if skipMethod != "GetConfigDiff" {
+		mockObj.On("GetConfigDiff", mock.Anything).Return("", nil)
+	}
 	if skipMethod != "Reload" {
-		mockObj.On("Reload").Return(nil)
+		mockObj.On("Reload").Return(false, nil)
 	}
 	if skipMethod != "AddCert" {
 		mockObj.On("AddCert", mock.Anything).Return(nil)
 	}
+	if skipMethod != "RemoveCert" {
+		mockObj.On("RemoveCert", mock.Anything).Return(nil)
+	}
 	if skipMethod != "GetCerts" {
 		mockObj.On("GetCerts").Return(map[string]string{})
 	}
+	if skipMethod != "SetServerState" {
+		mockObj.On("SetServerState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "SetServerWeight" {
+		mockObj.On("SetServerWeight", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "GetServerState" {
+		mockObj.On("GetServerState", mock.Anything, mock.Anything).Return(proxy.ServerState{}, nil)
+	}
+	if skipMethod != "GetProcessState" {
+		mockObj.On("GetProcessState").Return(proxy.ProcessState{Running: true})
+	}
+	if skipMethod != "GetStats" {
+		mockObj.On("GetStats").Return([]proxy.Stats{}, nil)
+	}
 	return mockObj
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redactedValue = "*****"
+
+// AuditRecord describes a request that changed, or tried to change, the configuration of the proxy
+type AuditRecord struct {
+	Timestamp   time.Time
+	RemoteAddr  string
+	Principal   string `json:",omitempty"`
+	Endpoint    string
+	ServiceName string `json:",omitempty"`
+	Distribute  bool
+	Query       map[string]string `json:",omitempty"`
+	Body        interface{}       `json:",omitempty"`
+	Diff        string            `json:",omitempty"`
+	Status      int
+	Success     bool
+}
+
+// AuditFilter limits the audit records to those of the service created within the time range.
+// Empty fields do not limit the records.
+type AuditFilter struct {
+	ServiceName string
+	From        time.Time
+	To          time.Time
+}
+
+var auditMu = &sync.Mutex{}
+
+// auditPath is the path of the append-only audit log. Audit is disabled when it is empty.
+var auditPath string
+var openAuditFile = os.OpenFile
+
+// newAuditRecord creates the record of the request. The secrets in the query and the body are redacted.
+// The body is read only if readBody is true and it is put back so that the request can still be served.
+func newAuditRecord(endpoint string, req *http.Request, readBody bool) AuditRecord {
+	record := AuditRecord{
+		Timestamp:   time.Now().UTC(),
+		RemoteAddr:  req.RemoteAddr,
+		Endpoint:    endpoint,
+		ServiceName: req.URL.Query().Get("serviceName"),
+		Query:       map[string]string{},
+	}
+	if endpoint == "cert" {
+		record.ServiceName = req.URL.Query().Get("certName")
+	}
+	if token := getAuthToken(req); token != nil {
+		record.Principal = token.Name
+	}
+	for key := range req.URL.Query() {
+		record.Query[key] = redactQueryValue(key, req.URL.Query().Get(key))
+	}
+	record.Distribute, _ = strconv.ParseBool(req.URL.Query().Get("distribute"))
+	if readBody && req.Body != nil {
+		content, _ := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(content))
+		var body interface{}
+		if len(content) > 0 && json.Unmarshal(content, &body) == nil {
+			record.Body = redactBody(body)
+			if fields, ok := body.(map[string]interface{}); ok {
+				for key, value := range fields {
+					if strings.EqualFold(key, "serviceName") {
+						record.ServiceName, _ = value.(string)
+					} else if strings.EqualFold(key, "distribute") {
+						record.Distribute, _ = value.(bool)
+					}
+				}
+			}
+		}
+	}
+	return record
+}
+
+// writeAuditRecord appends the record to the audit log
+func writeAuditRecord(record AuditRecord) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if len(auditPath) == 0 {
+		return
+	}
+	file, err := openAuditFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logPrintf("Could not open the audit log %s\n%s", auditPath, err.Error())
+		return
+	}
+	defer file.Close()
+	js, _ := json.Marshal(record)
+	if _, err := file.Write(append(js, '\n')); err != nil {
+		logPrintf("Could not write to the audit log %s\n%s", auditPath, err.Error())
+	}
+}
+
+// readAuditRecords returns the records from the audit log that match the filter, from the oldest to the newest
+func readAuditRecords(filter AuditFilter) ([]AuditRecord, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	records := []AuditRecord{}
+	if len(auditPath) == 0 {
+		return records, nil
+	}
+	file, err := openAuditFile(auditPath, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Could not open the audit log %s\n%s", auditPath, err.Error())
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for {
+		record := AuditRecord{}
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Could not parse the audit log %s\n%s", auditPath, err.Error())
+		}
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (f AuditFilter) matches(record AuditRecord) bool {
+	if len(f.ServiceName) > 0 && f.ServiceName != record.ServiceName {
+		return false
+	}
+	if !f.From.IsZero() && record.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && record.Timestamp.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// redactQueryValue hides the certificate and the passwords of the users sent through the query
+func redactQueryValue(key, value string) string {
+	switch {
+	case strings.EqualFold(key, "serviceCert") && len(value) > 0:
+		return redactedValue
+	case strings.EqualFold(key, "users"):
+		users := []string{}
+		for _, user := range strings.Split(value, ",") {
+			users = append(users, strings.Split(user, ":")[0]+":"+redactedValue)
+		}
+		return strings.Join(users, ",")
+	}
+	return value
+}
+
+// redactBody hides passwords and certificates in the JSON body. Keys are matched case-insensitively
+// the same way encoding/json matches them with the struct fields.
+func redactBody(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if strings.EqualFold(key, "password") || strings.EqualFold(key, "serviceCert") {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactBody(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactBody(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,162 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *AuditTestSuite) SetupTest() {
+	s.dir, _ = ioutil.TempDir("", "audit")
+	auditPath = fmt.Sprintf("%s/audit.jsonl", s.dir)
+}
+
+func (s *AuditTestSuite) TearDownTest() {
+	auditPath = ""
+	os.RemoveAll(s.dir)
+}
+
+// newAuditRecord
+
+func (s *AuditTestSuite) Test_NewAuditRecord_RedactsQuerySecrets() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/reconfigure?serviceName=my-service&users=user-1:pass-1,user-2:pass-2&serviceCert=my-cert&distribute=true", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+
+	actual := newAuditRecord("reconfigure", req, true)
+
+	s.Equal("reconfigure", actual.Endpoint)
+	s.Equal("10.0.0.1:12345", actual.RemoteAddr)
+	s.Equal("my-service", actual.ServiceName)
+	s.True(actual.Distribute)
+	s.Equal(map[string]string{
+		"serviceName": "my-service",
+		"users":       "user-1:*****,user-2:*****",
+		"serviceCert": "*****",
+		"distribute":  "true",
+	}, actual.Query)
+	s.False(actual.Timestamp.IsZero())
+}
+
+func (s *AuditTestSuite) Test_NewAuditRecord_RedactsBodySecretsAndKeepsBody() {
+	body := `{"serviceName": "my-service", "Distribute": true, "users": [{"username": "my-user", "password": "my-pass"}], "ServiceCert": "my-cert"}`
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/reconfigure", strings.NewReader(body))
+
+	actual := newAuditRecord("reconfigure", req, true)
+
+	s.Equal("my-service", actual.ServiceName)
+	s.True(actual.Distribute)
+	s.Equal(map[string]interface{}{
+		"serviceName": "my-service",
+		"Distribute":  true,
+		"users":       []interface{}{map[string]interface{}{"username": "my-user", "password": "*****"}},
+		"ServiceCert": "*****",
+	}, actual.Body)
+	remaining, _ := ioutil.ReadAll(req.Body)
+	s.Equal(body, string(remaining))
+}
+
+func (s *AuditTestSuite) Test_NewAuditRecord_DoesNotReadBody_WhenReadBodyIsFalse() {
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-cert.pem", strings.NewReader("my-cert"))
+
+	actual := newAuditRecord("cert", req, false)
+
+	s.Nil(actual.Body)
+	s.Equal(map[string]string{"certName": "my-cert.pem"}, actual.Query)
+}
+
+func (s *AuditTestSuite) Test_NewAuditRecord_SetsServiceNameToCertName_WhenEndpointIsCert() {
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-cert.pem", strings.NewReader("my-cert"))
+
+	actual := newAuditRecord("cert", req, false)
+
+	s.Equal("my-cert.pem", actual.ServiceName)
+}
+
+func (s *AuditTestSuite) Test_NewAuditRecord_SetsPrincipal_WhenRequestIsAuthenticated() {
+	defer func() { authTokens = nil }()
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}}}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/remove?serviceName=my-service", nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+	req, _ = authorize(httptest.NewRecorder(), req)
+
+	actual := newAuditRecord("remove", req, false)
+
+	s.Equal("deployer", actual.Principal)
+}
+
+// writeAuditRecord
+
+func (s *AuditTestSuite) Test_WriteAuditRecord_AppendsJSONLines() {
+	writeAuditRecord(AuditRecord{Endpoint: "reconfigure", ServiceName: "service-1"})
+	writeAuditRecord(AuditRecord{Endpoint: "remove", ServiceName: "service-2"})
+
+	content, _ := ioutil.ReadFile(auditPath)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	s.Len(lines, 2)
+	s.Contains(lines[0], `"ServiceName":"service-1"`)
+	s.Contains(lines[1], `"ServiceName":"service-2"`)
+}
+
+func (s *AuditTestSuite) Test_WriteAuditRecord_DoesNothing_WhenAuditIsDisabled() {
+	path := auditPath
+	auditPath = ""
+
+	writeAuditRecord(AuditRecord{Endpoint: "reconfigure"})
+
+	_, err := os.Stat(path)
+	s.True(os.IsNotExist(err))
+}
+
+// readAuditRecords
+
+func (s *AuditTestSuite) Test_ReadAuditRecords_FiltersByServiceAndTime() {
+	start := time.Date(2017, 1, 2, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		writeAuditRecord(AuditRecord{
+			Timestamp:   start.Add(time.Duration(i) * time.Hour),
+			ServiceName: fmt.Sprintf("service-%d", i%2),
+		})
+	}
+
+	actual, err := readAuditRecords(AuditFilter{ServiceName: "service-0", From: start.Add(time.Minute), To: start.Add(5 * time.Hour)})
+
+	s.NoError(err)
+	s.Len(actual, 1)
+	s.Equal(start.Add(2*time.Hour), actual[0].Timestamp)
+}
+
+func (s *AuditTestSuite) Test_ReadAuditRecords_ReturnsEmptySlice_WhenLogDoesNotExist() {
+	actual, err := readAuditRecords(AuditFilter{})
+
+	s.NoError(err)
+	s.Equal([]AuditRecord{}, actual)
+}
+
+func (s *AuditTestSuite) Test_ReadAuditRecords_ReturnsError_WhenLogIsNotValid() {
+	ioutil.WriteFile(auditPath, []byte("not json\n"), 0644)
+
+	_, err := readAuditRecords(AuditFilter{})
+
+	s.Error(err)
+}
+
+// Suite
+
+func TestAuditUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(AuditTestSuite))
+}
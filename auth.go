@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	SCOPE_READ        = "read"
+	SCOPE_RECONFIGURE = "reconfigure"
+	SCOPE_CERTS       = "certs"
+)
+
+// AuthToken is a credential allowed to call the management API.
+// It is either a bearer token or a basic auth username and password.
+type AuthToken struct {
+	Name            string   `json:"name"`
+	Token           string   `json:"token,omitempty"`
+	Username        string   `json:"username,omitempty"`
+	Password        string   `json:"password,omitempty"`
+	Scopes          []string `json:"scopes"`
+	ServicePrefixes []string `json:"servicePrefixes,omitempty"`
+}
+
+type authContextKey struct{}
+
+// authTokens are the credentials accepted by the management API. Authentication is disabled when there are none.
+var authTokens []AuthToken
+var authSecretPath = "/run/secrets/dfp_auth_tokens"
+
+// loadAuthTokens reads the JSON array of tokens stored in the file located in the path
+func loadAuthTokens(path string) ([]AuthToken, error) {
+	content, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the auth tokens from %s\n%s", path, err.Error())
+	}
+	tokens := []AuthToken{}
+	if err := json.Unmarshal(content, &tokens); err != nil {
+		return nil, fmt.Errorf("Could not parse the auth tokens from %s\n%s", path, err.Error())
+	}
+	for i, token := range tokens {
+		if len(token.Token) == 0 && (len(token.Username) == 0 || len(token.Password) == 0) {
+			return nil, fmt.Errorf("The auth token %d must have either the token or the username and the password", i)
+		}
+		for _, scope := range token.Scopes {
+			if scope != SCOPE_READ && scope != SCOPE_RECONFIGURE && scope != SCOPE_CERTS {
+				return nil, fmt.Errorf("The scope %s of the auth token %d is not valid", scope, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// getAuthTokensPath returns the path set through AUTH_TOKENS_PATH or, if not set, the Docker secret if it exists
+func getAuthTokensPath(path string) string {
+	if len(path) > 0 {
+		return path
+	}
+	if _, err := os.Stat(authSecretPath); err == nil {
+		return authSecretPath
+	}
+	return ""
+}
+
+// getRequiredScope returns the scope needed to call the endpoint. Health checks do not require any.
+func getRequiredScope(req *http.Request) string {
+	switch {
+	case req.URL.Path == "/v1/test" || req.URL.Path == "/v2/test":
+		return ""
+	case req.URL.Path == "/v1/docker-flow-proxy/reconfigure" || req.URL.Path == "/v1/docker-flow-proxy/remove" || req.URL.Path == "/v1/docker-flow-proxy/rollback" || req.URL.Path == "/v1/docker-flow-proxy/canary":
+		return SCOPE_RECONFIGURE
+	case strings.HasPrefix(req.URL.Path, SERVERS_PATH) && req.Method != "GET":
+		return SCOPE_RECONFIGURE
+	case req.URL.Path == "/v1/docker-flow-proxy/cert" || req.URL.Path == "/v1/docker-flow-proxy/certs":
+		return SCOPE_CERTS
+	}
+	return SCOPE_READ
+}
+
+// authenticate returns the token that matches the bearer token or the basic auth credentials of the request
+func authenticate(req *http.Request) *AuthToken {
+	username, password, isBasic := req.BasicAuth()
+	bearer := ""
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		bearer = strings.TrimPrefix(auth, "Bearer ")
+	}
+	for i := range authTokens {
+		token := &authTokens[i]
+		if len(bearer) > 0 && len(token.Token) > 0 && secureEqual(bearer, token.Token) {
+			return token
+		}
+		if isBasic && len(token.Username) > 0 && secureEqual(username, token.Username) && secureEqual(password, token.Password) {
+			return token
+		}
+	}
+	return nil
+}
+
+// authorize checks the credentials of the request against the scope required by the endpoint.
+// It writes 401 or 403 and returns false if the request is not allowed.
+// Otherwise, it returns the request with the matched token stored in its context.
+func authorize(w http.ResponseWriter, req *http.Request) (*http.Request, bool) {
+	scope := getRequiredScope(req)
+	if len(authTokens) == 0 || len(scope) == 0 {
+		return req, true
+	}
+	token := authenticate(req)
+	if token == nil {
+		w.Header().Add("WWW-Authenticate", `Bearer realm="docker-flow-proxy"`)
+		w.Header().Add("WWW-Authenticate", `Basic realm="docker-flow-proxy"`)
+		writeAuthError(w, http.StatusUnauthorized, "Valid credentials are required")
+		return req, false
+	}
+	if !token.hasScope(scope) {
+		writeAuthError(w, http.StatusForbidden, fmt.Sprintf("The %s scope is required", scope))
+		return req, false
+	}
+	return req.WithContext(context.WithValue(req.Context(), authContextKey{}, token)), true
+}
+
+// getAuthToken returns the token that authorized the request or nil if authentication is disabled
+func getAuthToken(req *http.Request) *AuthToken {
+	token, _ := req.Context().Value(authContextKey{}).(*AuthToken)
+	return token
+}
+
+// canAccessService returns true if the token that authorized the request is not limited to the prefixes of service names
+// or if the name starts with one of them
+func canAccessService(req *http.Request, name string) bool {
+	token := getAuthToken(req)
+	if token == nil || len(token.ServicePrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range token.ServicePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPeerAuthorization returns the Authorization header built from the first credential with the certs scope.
+// It is sent with the requests to the other instances of the proxy. It is empty when authentication is disabled.
+func getPeerAuthorization() string {
+	for _, token := range authTokens {
+		if !token.hasScope(SCOPE_CERTS) {
+			continue
+		}
+		if len(token.Token) > 0 {
+			return "Bearer " + token.Token
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(token.Username+":"+token.Password))
+	}
+	return ""
+}
+
+func (t *AuthToken) hasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func writeAuthError(w http.ResponseWriter, status int, msg string) {
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(status)
+	js, _ := json.Marshal(Response{Status: "NOK", Message: msg})
+	w.Write(js)
+}
+
+func secureEqual(actual, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
@@ -0,0 +1,247 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+	tokens []AuthToken
+}
+
+func (s *AuthTestSuite) SetupTest() {
+	s.tokens = []AuthToken{
+		{Name: "reader", Token: "read-token", Scopes: []string{SCOPE_READ}},
+		{Name: "deployer", Token: "deploy-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"team-a-"}},
+		{Name: "admin", Username: "admin", Password: "secret", Scopes: []string{SCOPE_READ, SCOPE_RECONFIGURE, SCOPE_CERTS}},
+	}
+	authTokens = s.tokens
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {}
+}
+
+func (s *AuthTestSuite) TearDownTest() {
+	authTokens = nil
+}
+
+// loadAuthTokens
+
+func (s *AuthTestSuite) Test_LoadAuthTokens_ReturnsTokens() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	actualPath := ""
+	readFile = func(path string) ([]byte, error) {
+		actualPath = path
+		return []byte(`[
+			{"name": "reader", "token": "read-token", "scopes": ["read"]},
+			{"name": "deployer", "token": "deploy-token", "scopes": ["reconfigure"], "servicePrefixes": ["team-a-"]},
+			{"name": "admin", "username": "admin", "password": "secret", "scopes": ["read", "reconfigure", "certs"]}
+		]`), nil
+	}
+
+	actual, err := loadAuthTokens("/run/secrets/my-tokens")
+
+	s.NoError(err)
+	s.Equal("/run/secrets/my-tokens", actualPath)
+	s.Equal(s.tokens, actual)
+}
+
+func (s *AuthTestSuite) Test_LoadAuthTokens_ReturnsError_WhenFileCannotBeRead() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(path string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+
+	_, err := loadAuthTokens("/run/secrets/my-tokens")
+
+	s.Error(err)
+}
+
+func (s *AuthTestSuite) Test_LoadAuthTokens_ReturnsError_WhenTokensAreNotValid() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	for _, content := range []string{
+		`not json`,
+		`[{"name": "no-credentials", "scopes": ["read"]}]`,
+		`[{"name": "no-password", "username": "admin", "scopes": ["read"]}]`,
+		`[{"name": "unknown-scope", "token": "my-token", "scopes": ["everything"]}]`,
+	} {
+		readFile = func(path string) ([]byte, error) {
+			return []byte(content), nil
+		}
+
+		_, err := loadAuthTokens("/run/secrets/my-tokens")
+
+		s.Error(err, content)
+	}
+}
+
+// getAuthTokensPath
+
+func (s *AuthTestSuite) Test_GetAuthTokensPath_ReturnsPath_WhenSet() {
+	s.Equal("/my/tokens.json", getAuthTokensPath("/my/tokens.json"))
+}
+
+func (s *AuthTestSuite) Test_GetAuthTokensPath_ReturnsSecret_WhenPathIsNotSetAndSecretExists() {
+	authSecretPathOrig := authSecretPath
+	defer func() { authSecretPath = authSecretPathOrig }()
+	file, _ := ioutil.TempFile("", "auth-tokens")
+	defer os.Remove(file.Name())
+	authSecretPath = file.Name()
+
+	s.Equal(file.Name(), getAuthTokensPath(""))
+}
+
+func (s *AuthTestSuite) Test_GetAuthTokensPath_ReturnsEmptyString_WhenPathIsNotSetAndSecretDoesNotExist() {
+	authSecretPathOrig := authSecretPath
+	defer func() { authSecretPath = authSecretPathOrig }()
+	authSecretPath = "/this/secret/does/not/exist"
+
+	s.Equal("", getAuthTokensPath(""))
+}
+
+// authorize
+
+func (s *AuthTestSuite) Test_Authorize_AllowsRequests_WhenThereAreNoTokens() {
+	authTokens = nil
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/cert", nil)
+	rec := httptest.NewRecorder()
+
+	_, ok := authorize(rec, req)
+
+	s.True(ok)
+}
+
+func (s *AuthTestSuite) Test_Authorize_AllowsTestRequestsWithoutCredentials() {
+	for _, path := range []string{"/v1/test", "/v2/test"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+
+		_, ok := authorize(rec, req)
+
+		s.True(ok)
+	}
+}
+
+func (s *AuthTestSuite) Test_Authorize_Returns401_WhenCredentialsAreMissingOrWrong() {
+	for _, auth := range []string{"", "Bearer wrong-token", "Basic YWRtaW46d3Jvbmc="} {
+		req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/config", nil)
+		if len(auth) > 0 {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+
+		_, ok := authorize(rec, req)
+
+		s.False(ok)
+		s.Equal(http.StatusUnauthorized, rec.Code)
+		s.Len(rec.HeaderMap["Www-Authenticate"], 2)
+	}
+}
+
+func (s *AuthTestSuite) Test_Authorize_Returns403_WhenTokenDoesNotHaveScope() {
+	data := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/v1/docker-flow-proxy/reconfigure"},
+		{"GET", "/v1/docker-flow-proxy/remove"},
+		{"PUT", "/v1/docker-flow-proxy/servers/go-demo-be/go-demo"},
+		{"PUT", "/v1/docker-flow-proxy/cert"},
+		{"GET", "/v1/docker-flow-proxy/certs"},
+	}
+	for _, d := range data {
+		req, _ := http.NewRequest(d.method, d.path, nil)
+		req.Header.Set("Authorization", "Bearer read-token")
+		rec := httptest.NewRecorder()
+
+		_, ok := authorize(rec, req)
+
+		s.False(ok, d.path)
+		s.Equal(http.StatusForbidden, rec.Code, d.path)
+	}
+}
+
+func (s *AuthTestSuite) Test_Authorize_AllowsReadRequests_WhenTokenHasReadScope() {
+	for _, path := range []string{"/v1/docker-flow-proxy/config", "/v2/docker-flow-proxy/services", "/v1/docker-flow-proxy/servers/go-demo-be/go-demo", "/metrics"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer read-token")
+		rec := httptest.NewRecorder()
+
+		actual, ok := authorize(rec, req)
+
+		s.True(ok, path)
+		s.Equal("reader", getAuthToken(actual).Name)
+	}
+}
+
+func (s *AuthTestSuite) Test_Authorize_AllowsRequests_WhenBasicAuthCredentialsMatch() {
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/cert", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec := httptest.NewRecorder()
+
+	actual, ok := authorize(rec, req)
+
+	s.True(ok)
+	s.Equal("admin", getAuthToken(actual).Name)
+}
+
+// canAccessService
+
+func (s *AuthTestSuite) Test_CanAccessService_ReturnsTrue_WhenAuthenticationIsDisabled() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/reconfigure", nil)
+
+	s.True(canAccessService(req, "any-service"))
+}
+
+func (s *AuthTestSuite) Test_CanAccessService_ChecksServicePrefixes() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/reconfigure", nil)
+	req.Header.Set("Authorization", "Bearer deploy-token")
+	req, _ = authorize(httptest.NewRecorder(), req)
+
+	s.True(canAccessService(req, "team-a-service"))
+	s.False(canAccessService(req, "team-b-service"))
+}
+
+func (s *AuthTestSuite) Test_CanAccessService_ReturnsTrue_WhenTokenIsNotLimitedToPrefixes() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/reconfigure", nil)
+	req.SetBasicAuth("admin", "secret")
+	req, _ = authorize(httptest.NewRecorder(), req)
+
+	s.True(canAccessService(req, "team-b-service"))
+}
+
+// getPeerAuthorization
+
+func (s *AuthTestSuite) Test_GetPeerAuthorization_ReturnsCredentialWithCertsScope() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/certs", nil)
+	req.Header.Set("Authorization", getPeerAuthorization())
+	rec := httptest.NewRecorder()
+
+	actual, ok := authorize(rec, req)
+
+	s.True(ok)
+	s.Equal("admin", getAuthToken(actual).Name)
+}
+
+func (s *AuthTestSuite) Test_GetPeerAuthorization_ReturnsEmptyString_WhenAuthenticationIsDisabled() {
+	authTokens = nil
+
+	s.Empty(getPeerAuthorization())
+}
+
+// Suite
+
+func TestAuthUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(AuthTestSuite))
+}
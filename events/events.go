@@ -0,0 +1,101 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TypeReconfigure = "reconfigure"
+	TypeRemove      = "remove"
+	TypeRollback    = "rollback"
+	TypeCert        = "cert"
+	TypeReload      = "reload"
+	TypeDistribute  = "distribute"
+	TypeCanary      = "canary"
+)
+
+// Event describes a change of the proxy configuration or its outcome
+type Event struct {
+	ID          int64
+	Type        string
+	Timestamp   time.Time
+	ServiceName string `json:",omitempty"`
+	CertName    string `json:",omitempty"`
+	ConfigHash  string `json:",omitempty"`
+	Success     bool
+	Message     string `json:",omitempty"`
+}
+
+// HistorySize is the number of the latest events replayed to subscribers that reconnect with the ID of the last event they received
+const HistorySize = 100
+
+// subscriberBuffer is the number of events a subscriber can fall behind before it is disconnected
+const subscriberBuffer = 100
+
+var mu = &sync.Mutex{}
+var lastID int64
+var history = []Event{}
+var subscribers = map[chan Event]bool{}
+
+// Publish assigns the next ID to the event and sends it to all the subscribers.
+// Subscribers that do not keep up are disconnected so that publishing never blocks.
+func Publish(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	lastID++
+	e.ID = lastID
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	history = append(history, e)
+	if len(history) > HistorySize {
+		history = history[len(history)-HistorySize:]
+	}
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the channel the published events are sent to. If afterID is greater than zero,
+// the stored events published after the event with that ID are sent first.
+// The channel is closed when the subscriber is disconnected.
+func Subscribe(afterID int64) chan Event {
+	mu.Lock()
+	defer mu.Unlock()
+	ch := make(chan Event, subscriberBuffer+HistorySize)
+	if afterID > 0 {
+		for _, e := range history {
+			if e.ID > afterID {
+				ch <- e
+			}
+		}
+	}
+	subscribers[ch] = true
+	return ch
+}
+
+// Unsubscribe stops sending events to the channel and closes it
+func Unsubscribe(ch chan Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if subscribers[ch] {
+		delete(subscribers, ch)
+		close(ch)
+	}
+}
+
+// UnsubscribeAll disconnects all the subscribers
+func UnsubscribeAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	for ch := range subscribers {
+		delete(subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,122 @@
+// +build !integration
+
+package events
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type EventsTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventsTestSuite) SetupTest() {
+	UnsubscribeAll()
+	lastID = 0
+	history = []Event{}
+}
+
+// Suite
+
+func TestEventsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
+
+// Publish
+
+func (s *EventsTestSuite) Test_Publish_SendsEventToSubscribers() {
+	ch1 := Subscribe(0)
+	ch2 := Subscribe(0)
+
+	Publish(Event{Type: TypeReconfigure, ServiceName: "my-service", Success: true})
+
+	for _, ch := range []chan Event{ch1, ch2} {
+		actual := <-ch
+		s.Equal(int64(1), actual.ID)
+		s.Equal(TypeReconfigure, actual.Type)
+		s.Equal("my-service", actual.ServiceName)
+		s.False(actual.Timestamp.IsZero())
+	}
+}
+
+func (s *EventsTestSuite) Test_Publish_AssignsIncreasingIDs() {
+	ch := Subscribe(0)
+
+	Publish(Event{Type: TypeReload})
+	Publish(Event{Type: TypeReload})
+
+	s.Equal(int64(1), (<-ch).ID)
+	s.Equal(int64(2), (<-ch).ID)
+}
+
+func (s *EventsTestSuite) Test_Publish_DisconnectsSubscriber_WhenItDoesNotKeepUp() {
+	ch := Subscribe(0)
+
+	for i := 0; i < cap(ch)+1; i++ {
+		Publish(Event{Type: TypeReload})
+	}
+
+	received := 0
+	for range ch {
+		received++
+	}
+	s.Equal(cap(ch), received)
+	s.Len(subscribers, 0)
+}
+
+func (s *EventsTestSuite) Test_Publish_KeepsOnlyTheLatestEvents() {
+	for i := 0; i < HistorySize+5; i++ {
+		Publish(Event{Type: TypeReload})
+	}
+
+	s.Len(history, HistorySize)
+	s.Equal(int64(6), history[0].ID)
+}
+
+// Subscribe
+
+func (s *EventsTestSuite) Test_Subscribe_ReplaysEventsAfterID() {
+	for i := 0; i < 3; i++ {
+		Publish(Event{Type: TypeReload})
+	}
+
+	ch := Subscribe(1)
+
+	s.Len(ch, 2)
+	s.Equal(int64(2), (<-ch).ID)
+	s.Equal(int64(3), (<-ch).ID)
+}
+
+func (s *EventsTestSuite) Test_Subscribe_DoesNotReplayEvents_WhenAfterIDIsZero() {
+	Publish(Event{Type: TypeReload})
+
+	ch := Subscribe(0)
+
+	s.Len(ch, 0)
+}
+
+// Unsubscribe
+
+func (s *EventsTestSuite) Test_Unsubscribe_ClosesChannel() {
+	ch := Subscribe(0)
+
+	Unsubscribe(ch)
+	Unsubscribe(ch)
+
+	_, ok := <-ch
+	s.False(ok)
+	s.Len(subscribers, 0)
+}
+
+func (s *EventsTestSuite) Test_UnsubscribeAll_ClosesAllChannels() {
+	ch1 := Subscribe(0)
+	ch2 := Subscribe(0)
+
+	UnsubscribeAll()
+
+	_, ok1 := <-ch1
+	_, ok2 := <-ch2
+	s.False(ok1)
+	s.False(ok2)
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sample is a single value of a metric identified by its labels
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+type requestKey struct {
+	endpoint string
+	outcome  string
+}
+
+// ReloadBuckets are the upper bounds (in seconds) of the reload duration histogram buckets
+var ReloadBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var mu = &sync.Mutex{}
+var requests = map[requestKey]float64{}
+var reloadBucketCounts = make([]uint64, len(ReloadBuckets))
+var reloadCount uint64
+var reloadSum float64
+var lastReloadSuccess time.Time
+
+// IncRequest increments the number of requests received by the endpoint (e.g. reconfigure) with the outcome (e.g. success)
+func IncRequest(endpoint, outcome string) {
+	mu.Lock()
+	defer mu.Unlock()
+	requests[requestKey{endpoint, outcome}]++
+}
+
+// ObserveReload records the duration of a reload. The time of the last successful reload is updated if the reload succeeded.
+func ObserveReload(duration time.Duration, success bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	seconds := duration.Seconds()
+	for i, bound := range ReloadBuckets {
+		if seconds <= bound {
+			reloadBucketCounts[i]++
+		}
+	}
+	reloadCount++
+	reloadSum += seconds
+	if success {
+		lastReloadSuccess = time.Now()
+	}
+}
+
+// WriteControllerMetrics writes the request counters and the reload metrics in the Prometheus text format
+func WriteControllerMetrics(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	keys := []requestKey{}
+	for key := range requests {
+		keys = append(keys, key)
+	}
+	sort.Sort(requestKeys(keys))
+	samples := []Sample{}
+	for _, key := range keys {
+		samples = append(samples, Sample{
+			Labels: map[string]string{"endpoint": key.endpoint, "outcome": key.outcome},
+			Value:  requests[key],
+		})
+	}
+	Write(w, "docker_flow_proxy_requests_total", "The number of requests by endpoint and outcome.", "counter", samples)
+
+	fmt.Fprintf(w, "# HELP docker_flow_proxy_reload_duration_seconds The duration of HAProxy reloads.\n")
+	fmt.Fprintf(w, "# TYPE docker_flow_proxy_reload_duration_seconds histogram\n")
+	for i, bound := range ReloadBuckets {
+		fmt.Fprintf(w, "docker_flow_proxy_reload_duration_seconds_bucket{le=\"%s\"} %d\n", formatValue(bound), reloadBucketCounts[i])
+	}
+	fmt.Fprintf(w, "docker_flow_proxy_reload_duration_seconds_bucket{le=\"+Inf\"} %d\n", reloadCount)
+	fmt.Fprintf(w, "docker_flow_proxy_reload_duration_seconds_sum %s\n", formatValue(reloadSum))
+	fmt.Fprintf(w, "docker_flow_proxy_reload_duration_seconds_count %d\n", reloadCount)
+
+	lastReload := float64(0)
+	if !lastReloadSuccess.IsZero() {
+		lastReload = float64(lastReloadSuccess.Unix())
+	}
+	Write(w, "docker_flow_proxy_last_reload_success_timestamp_seconds", "The time of the last successful HAProxy reload.", "gauge", []Sample{{Value: lastReload}})
+}
+
+// Write writes the samples of the metric in the Prometheus text format
+func Write(w io.Writer, name, help, metricType string, samples []Sample) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(sample.Labels), formatValue(sample.Value))
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := []string{}
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := []string{}
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, labelValueReplacer.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+type requestKeys []requestKey
+
+func (k requestKeys) Len() int      { return len(k) }
+func (k requestKeys) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k requestKeys) Less(i, j int) bool {
+	if k[i].endpoint != k[j].endpoint {
+		return k[i].endpoint < k[j].endpoint
+	}
+	return k[i].outcome < k[j].outcome
+}
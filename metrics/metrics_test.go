@@ -0,0 +1,104 @@
+// +build !integration
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+}
+
+func (s *MetricsTestSuite) SetupTest() {
+	requests = map[requestKey]float64{}
+	reloadBucketCounts = make([]uint64, len(ReloadBuckets))
+	reloadCount = 0
+	reloadSum = 0
+	lastReloadSuccess = time.Time{}
+}
+
+// IncRequest
+
+func (s *MetricsTestSuite) Test_IncRequest_CountsRequestsByEndpointAndOutcome() {
+	IncRequest("remove", "success")
+	IncRequest("reconfigure", "success")
+	IncRequest("reconfigure", "error")
+	IncRequest("reconfigure", "success")
+	var buf bytes.Buffer
+
+	WriteControllerMetrics(&buf)
+
+	s.Contains(buf.String(), `# TYPE docker_flow_proxy_requests_total counter
+docker_flow_proxy_requests_total{endpoint="reconfigure",outcome="error"} 1
+docker_flow_proxy_requests_total{endpoint="reconfigure",outcome="success"} 2
+docker_flow_proxy_requests_total{endpoint="remove",outcome="success"} 1
+`)
+}
+
+// ObserveReload
+
+func (s *MetricsTestSuite) Test_ObserveReload_AddsDurationToHistogram() {
+	ObserveReload(20*time.Millisecond, true)
+	ObserveReload(2*time.Second, false)
+	var buf bytes.Buffer
+
+	WriteControllerMetrics(&buf)
+
+	s.Contains(buf.String(), `docker_flow_proxy_reload_duration_seconds_bucket{le="0.01"} 0
+docker_flow_proxy_reload_duration_seconds_bucket{le="0.025"} 1
+`)
+	s.Contains(buf.String(), `docker_flow_proxy_reload_duration_seconds_bucket{le="2.5"} 2
+`)
+	s.Contains(buf.String(), `docker_flow_proxy_reload_duration_seconds_bucket{le="+Inf"} 2
+docker_flow_proxy_reload_duration_seconds_sum 2.02
+docker_flow_proxy_reload_duration_seconds_count 2
+`)
+}
+
+func (s *MetricsTestSuite) Test_ObserveReload_SetsLastReloadSuccess_WhenReloadSucceeded() {
+	ObserveReload(time.Millisecond, true)
+	expected := fmt.Sprintf("docker_flow_proxy_last_reload_success_timestamp_seconds %d\n", lastReloadSuccess.Unix())
+	ObserveReload(time.Millisecond, false)
+	var buf bytes.Buffer
+
+	WriteControllerMetrics(&buf)
+
+	s.Contains(buf.String(), expected)
+}
+
+func (s *MetricsTestSuite) Test_WriteControllerMetrics_WritesZeroLastReloadSuccess_WhenThereWereNoReloads() {
+	var buf bytes.Buffer
+
+	WriteControllerMetrics(&buf)
+
+	s.Contains(buf.String(), "docker_flow_proxy_last_reload_success_timestamp_seconds 0\n")
+}
+
+// Write
+
+func (s *MetricsTestSuite) Test_Write_WritesSamplesWithSortedAndEscapedLabels() {
+	expected := `# HELP my_metric My help.
+# TYPE my_metric gauge
+my_metric{a="1",b="with \"quotes\" and \\ and \n"} 1.5
+my_metric 2
+`
+	var buf bytes.Buffer
+
+	Write(&buf, "my_metric", "My help.", "gauge", []Sample{
+		{Labels: map[string]string{"b": "with \"quotes\" and \\ and \n", "a": "1"}, Value: 1.5},
+		{Value: 2},
+	})
+
+	s.Equal(expected, buf.String())
+}
+
+// Suite
+
+func TestMetricsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
@@ -0,0 +1,82 @@
+// +build !integration
+
+package proxy
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type DiffTestSuite struct {
+	suite.Suite
+}
+
+// Suite
+
+func TestDiffUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
+
+// unifiedDiff
+
+func (s DiffTestSuite) Test_UnifiedDiff_ReturnsEmptyString_WhenTextsAreEqual() {
+	actual := unifiedDiff("a", "b", "line 1\nline 2", "line 1\nline 2")
+
+	s.Equal("", actual)
+}
+
+func (s DiffTestSuite) Test_UnifiedDiff_ReturnsAddedAndRemovedLines() {
+	from := "line 1\nline 2\nline 3\nline 4"
+	to := "line 1\nline 2\nline 3 changed\nline 4\nline 5"
+	expected := `--- a
++++ b
+@@ -1,4 +1,5 @@
+ line 1
+ line 2
+-line 3
++line 3 changed
+ line 4
++line 5
+`
+
+	actual := unifiedDiff("a", "b", from, to)
+
+	s.Equal(expected, actual)
+}
+
+func (s DiffTestSuite) Test_UnifiedDiff_SplitsDistantChangesIntoHunks() {
+	from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	to := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve"
+	expected := `--- a
++++ b
+@@ -1,4 +1,4 @@
+-1
++one
+ 2
+ 3
+ 4
+@@ -9,4 +9,4 @@
+ 9
+ 10
+ 11
+-12
++twelve
+`
+
+	actual := unifiedDiff("a", "b", from, to)
+
+	s.Equal(expected, actual)
+}
+
+func (s DiffTestSuite) Test_UnifiedDiff_ReturnsAllLinesAsAdded_WhenFromIsEmpty() {
+	expected := `--- a
++++ b
+@@ -0,0 +1,2 @@
++line 1
++line 2
+`
+
+	actual := unifiedDiff("a", "b", "", "line 1\nline 2")
+
+	s.Equal(expected, actual)
+}
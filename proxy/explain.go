@@ -0,0 +1,310 @@
+package proxy
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// routingFrontend is the frontend the routes of the services are added to
+const routingFrontend = "services"
+
+var sectionKeywords = []string{"global", "defaults", "frontend", "backend", "listen", "userlist", "resolvers", "peers"}
+var fetchArgRegexp = regexp.MustCompile(`^([a-z_.]+)\(([^)]*)\)$`)
+
+// ExplainRequest is the request whose route is explained
+type ExplainRequest struct {
+	Host    string
+	Path    string
+	Port    int
+	Method  string
+	Headers http.Header
+}
+
+// ExplainCondition is an ACL of a routing rule and whether the request meets it
+type ExplainCondition struct {
+	Acl         string
+	Definitions []string
+	Matched     bool
+	Message     string `json:",omitempty"`
+}
+
+// ExplainRule is a use_backend line of the routing frontend and whether the request meets its conditions
+type ExplainRule struct {
+	Rule       string
+	Backend    string
+	Conditions []ExplainCondition
+	Matched    bool
+}
+
+// Explanation lists the rules that were evaluated for a request, in the order HAProxy evaluates them,
+// and the backend and the servers the request is sent to
+type Explanation struct {
+	Rules   []ExplainRule
+	Backend string
+	Servers []string
+}
+
+type aclDefinition struct {
+	line     string
+	fetch    string
+	flags    []string
+	patterns []string
+}
+
+// Explain evaluates the ACLs and the use_backend lines of the routing frontend of the configuration
+// against the request. Rules are evaluated until the first one that matches. If none does, the default backend is used.
+func Explain(config string, req ExplainRequest) Explanation {
+	explanation := Explanation{Rules: []ExplainRule{}, Servers: []string{}}
+	acls := map[string][]aclDefinition{}
+	servers := map[string][]string{}
+	defaultBackend := ""
+	section, name := "", ""
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if isSectionKeyword(fields[0]) {
+			section, name = fields[0], ""
+			if len(fields) > 1 {
+				name = fields[1]
+			}
+			continue
+		}
+		if section == "backend" && (fields[0] == "server" || fields[0] == "server-template") {
+			servers[name] = append(servers[name], line)
+		}
+		if section != "frontend" || name != routingFrontend || len(explanation.Backend) > 0 {
+			continue
+		}
+		switch {
+		case fields[0] == "acl" && len(fields) > 2:
+			acls[fields[1]] = append(acls[fields[1]], parseAclDefinition(line, fields[2:]))
+		case fields[0] == "default_backend" && len(fields) > 1:
+			defaultBackend = fields[1]
+		case fields[0] == "use_backend" && len(fields) > 1:
+			rule := evaluateRule(line, fields, acls, req)
+			explanation.Rules = append(explanation.Rules, rule)
+			if rule.Matched {
+				explanation.Backend = rule.Backend
+			}
+		}
+	}
+	if len(explanation.Backend) == 0 {
+		explanation.Backend = defaultBackend
+	}
+	if list, ok := servers[explanation.Backend]; ok {
+		explanation.Servers = list
+	}
+	return explanation
+}
+
+func isSectionKeyword(value string) bool {
+	for _, keyword := range sectionKeywords {
+		if value == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAclDefinition(line string, fields []string) aclDefinition {
+	def := aclDefinition{line: line, fetch: fields[0]}
+	i := 1
+	for ; i < len(fields) && strings.HasPrefix(fields[i], "-"); i++ {
+		if fields[i] == "--" {
+			i++
+			break
+		}
+		def.flags = append(def.flags, fields[i])
+		if fields[i] == "-m" && i+1 < len(fields) {
+			i++
+			def.flags = append(def.flags, fields[i])
+		}
+	}
+	def.patterns = fields[i:]
+	return def
+}
+
+// evaluateRule evaluates the condition of the use_backend line. Conditions joined with "or" or "||" are alternatives,
+// the others must all be met. Negated ACLs start with "!".
+func evaluateRule(line string, fields []string, acls map[string][]aclDefinition, req ExplainRequest) ExplainRule {
+	rule := ExplainRule{Rule: line, Backend: fields[1], Conditions: []ExplainCondition{}}
+	if len(fields) < 4 {
+		rule.Matched = len(fields) == 2
+		return rule
+	}
+	unless := fields[2] == "unless"
+	matched, groupMatched := false, true
+	for _, term := range fields[3:] {
+		if term == "or" || term == "||" {
+			matched = matched || groupMatched
+			groupMatched = true
+			continue
+		}
+		condition := evaluateCondition(term, acls, req)
+		rule.Conditions = append(rule.Conditions, condition)
+		groupMatched = groupMatched && condition.Matched
+	}
+	rule.Matched = (matched || groupMatched) != unless
+	return rule
+}
+
+func evaluateCondition(term string, acls map[string][]aclDefinition, req ExplainRequest) ExplainCondition {
+	condition := ExplainCondition{Acl: term, Definitions: []string{}}
+	negated := strings.HasPrefix(term, "!")
+	name := strings.TrimPrefix(term, "!")
+	defs, ok := acls[name]
+	if !ok {
+		condition.Message = "The ACL is not defined or not supported"
+		return condition
+	}
+	matched := false
+	for _, def := range defs {
+		condition.Definitions = append(condition.Definitions, def.line)
+		result, supported := def.match(req)
+		if !supported {
+			condition.Message = "The ACL criterion " + def.fetch + " is not supported"
+			return condition
+		}
+		matched = matched || result
+	}
+	condition.Matched = matched != negated
+	return condition
+}
+
+// match returns whether one of the samples of the request matches one of the patterns and whether the fetch is supported
+func (def aclDefinition) match(req ExplainRequest) (bool, bool) {
+	samples, method, ok := getSamples(def.fetch, req)
+	if !ok {
+		return false, false
+	}
+	ignoreCase := false
+	for i, flag := range def.flags {
+		switch {
+		case flag == "-i":
+			ignoreCase = true
+		case flag == "-m" && i+1 < len(def.flags):
+			method = def.flags[i+1]
+		}
+	}
+	for _, sample := range samples {
+		for _, pattern := range def.patterns {
+			if matchPattern(method, sample, pattern, ignoreCase) {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+// getSamples returns the values of the request the fetch extracts and the match method implied by its suffix
+func getSamples(fetch string, req ExplainRequest) (samples []string, method string, ok bool) {
+	path, query := req.Path, ""
+	if index := strings.Index(path, "?"); index >= 0 {
+		path, query = path[:index], path[index+1:]
+	}
+	switch {
+	case fetch == "dst_port":
+		return []string{strconv.Itoa(req.Port)}, "int", true
+	case fetch == "method":
+		return []string{req.Method}, "str", true
+	case fetch == "path" || strings.HasPrefix(fetch, "path_"):
+		return []string{path}, getMatchMethod(fetch, "path"), true
+	}
+	match := fetchArgRegexp.FindStringSubmatch(fetch)
+	if match == nil {
+		return nil, "", false
+	}
+	base, arg := strings.TrimPrefix(match[1], "req."), match[2]
+	switch {
+	case base == "hdr" || strings.HasPrefix(base, "hdr_"):
+		values := req.Headers[http.CanonicalHeaderKey(arg)]
+		if strings.EqualFold(arg, "host") && len(req.Host) > 0 {
+			values = []string{req.Host}
+		}
+		for _, value := range values {
+			for _, v := range strings.Split(value, ",") {
+				samples = append(samples, strings.TrimSpace(v))
+			}
+		}
+		return samples, getMatchMethod(base, "hdr"), true
+	case base == "cook" || strings.HasPrefix(base, "cook_"):
+		cookieReq := http.Request{Header: req.Headers}
+		for _, cookie := range cookieReq.Cookies() {
+			if cookie.Name == arg {
+				samples = append(samples, cookie.Value)
+			}
+		}
+		return samples, getMatchMethod(base, "cook"), true
+	case base == "urlp" || strings.HasPrefix(base, "urlp_"):
+		for _, param := range strings.Split(query, "&") {
+			keyValue := strings.SplitN(param, "=", 2)
+			if len(keyValue) == 2 && keyValue[0] == arg {
+				samples = append(samples, keyValue[1])
+			}
+		}
+		return samples, getMatchMethod(base, "urlp"), true
+	}
+	return nil, "", false
+}
+
+func getMatchMethod(fetch, base string) string {
+	if suffix := strings.TrimPrefix(fetch, base+"_"); suffix != fetch {
+		return suffix
+	}
+	return "str"
+}
+
+func matchPattern(method, sample, pattern string, ignoreCase bool) bool {
+	if ignoreCase && method != "reg" {
+		sample, pattern = strings.ToLower(sample), strings.ToLower(pattern)
+	}
+	switch method {
+	case "str":
+		return sample == pattern
+	case "beg":
+		return strings.HasPrefix(sample, pattern)
+	case "end":
+		return strings.HasSuffix(sample, pattern)
+	case "sub":
+		return strings.Contains(sample, pattern)
+	case "dom":
+		return isDelimited(sample, pattern, "/?.:")
+	case "dir":
+		return isDelimited(sample, pattern, "/")
+	case "len":
+		length, err := strconv.Atoi(pattern)
+		return err == nil && len(sample) == length
+	case "int":
+		return sample == pattern
+	case "reg":
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		return err == nil && re.MatchString(sample)
+	}
+	return false
+}
+
+// isDelimited returns whether the pattern is found in the sample with a delimiter or the end of the sample on both sides
+func isDelimited(sample, pattern, delimiters string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	for start := 0; start+len(pattern) <= len(sample); start++ {
+		end := start + len(pattern)
+		if sample[start:end] != pattern {
+			continue
+		}
+		if (start == 0 || strings.ContainsRune(delimiters, rune(sample[start-1]))) &&
+			(end == len(sample) || strings.ContainsRune(delimiters, rune(sample[end]))) {
+			return true
+		}
+	}
+	return false
+}
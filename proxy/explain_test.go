@@ -0,0 +1,207 @@
+// +build !integration
+
+package proxy
+
+import (
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"testing"
+)
+
+type ExplainTestSuite struct {
+	suite.Suite
+	config string
+}
+
+func (s *ExplainTestSuite) SetupTest() {
+	s.config = `global
+    pidfile /var/run/haproxy.pid
+
+defaults
+    mode    http
+
+frontend services
+    bind *:80
+    bind *:443
+    mode http
+
+    acl url_api-canary path_beg /api
+    acl header_api-canary_1 req.hdr(X-Canary) -m str true
+    use_backend api-canary-be if url_api-canary header_api-canary_1
+
+    acl url_users path_beg /api/users
+    acl domain_users hdr_dom(host) -i my-domain.com
+    acl http_users dst_port 80
+    acl https_users dst_port 443
+    use_backend users-be if url_users domain_users http_users
+    use_backend https-users-be if url_users domain_users https_users
+
+    acl url_api path_beg /api
+    use_backend api-be if url_api
+
+    acl url_static path_end .css .js
+    acl url_report path_reg ^/reports/[0-9]+$
+    use_backend static-be if url_static or url_report
+
+backend api-canary-be
+    mode http
+    server api-canary api-canary:8080
+
+backend users-be
+    mode http
+    server users users:8080 resolvers docker init-addr last,libc,none
+
+backend https-users-be
+    mode http
+    server users users:8443
+
+backend api-be
+    mode http
+    server-template api 10 tasks.api:8080 resolvers docker init-addr none check
+
+backend static-be
+    mode http
+    server static static:80`
+}
+
+// Explain
+
+func (s *ExplainTestSuite) Test_Explain_ReturnsFirstMatchingRule() {
+	actual := Explain(s.config, ExplainRequest{Host: "www.my-domain.com", Path: "/api/users/1", Port: 80})
+
+	s.Equal("users-be", actual.Backend)
+	s.Equal([]string{"server users users:8080 resolvers docker init-addr last,libc,none"}, actual.Servers)
+	s.Len(actual.Rules, 2)
+	s.False(actual.Rules[0].Matched)
+	s.Equal("api-canary-be", actual.Rules[0].Backend)
+	s.True(actual.Rules[0].Conditions[0].Matched)
+	s.False(actual.Rules[0].Conditions[1].Matched)
+	s.True(actual.Rules[1].Matched)
+	s.Equal([]ExplainCondition{
+		{Acl: "url_users", Definitions: []string{"acl url_users path_beg /api/users"}, Matched: true},
+		{Acl: "domain_users", Definitions: []string{"acl domain_users hdr_dom(host) -i my-domain.com"}, Matched: true},
+		{Acl: "http_users", Definitions: []string{"acl http_users dst_port 80"}, Matched: true},
+	}, actual.Rules[1].Conditions)
+}
+
+func (s *ExplainTestSuite) Test_Explain_UsesPort() {
+	actual := Explain(s.config, ExplainRequest{Host: "my-domain.com", Path: "/api/users", Port: 443})
+
+	s.Equal("https-users-be", actual.Backend)
+	s.Equal([]string{"server users users:8443"}, actual.Servers)
+}
+
+func (s *ExplainTestSuite) Test_Explain_UsesHeaders() {
+	headers := http.Header{}
+	headers.Add("X-Canary", "true")
+
+	actual := Explain(s.config, ExplainRequest{Path: "/api/users", Port: 80, Headers: headers})
+
+	s.Equal("api-canary-be", actual.Backend)
+	s.Len(actual.Rules, 1)
+}
+
+func (s *ExplainTestSuite) Test_Explain_MatchesDomainsCaseInsensitively() {
+	for host, expected := range map[string]string{
+		"MY-DOMAIN.COM":      "users-be",
+		"my-domain.com:8080": "users-be",
+		"not-my-domain.com":  "api-be",
+	} {
+		actual := Explain(s.config, ExplainRequest{Host: host, Path: "/api/users", Port: 80})
+
+		s.Equal(expected, actual.Backend, host)
+	}
+}
+
+func (s *ExplainTestSuite) Test_Explain_MatchesPathEndAndRegexp() {
+	for path, expected := range map[string]string{
+		"/css/main.css":   "static-be",
+		"/reports/123":    "static-be",
+		"/reports/latest": "",
+		"/js/app.js?v=2":  "static-be",
+	} {
+		actual := Explain(s.config, ExplainRequest{Path: path, Port: 80})
+
+		s.Equal(expected, actual.Backend, path)
+	}
+}
+
+func (s *ExplainTestSuite) Test_Explain_MatchesCookiesQueryParametersAndMethods() {
+	config := `frontend services
+    acl url_beta path_beg /
+    acl cookie_beta_1 req.cook(tester) -m str yes
+    acl query_beta_1 urlp(beta) -m reg ^[0-9]+$
+    acl method_beta method GET HEAD
+    use_backend beta-be if url_beta cookie_beta_1 query_beta_1 method_beta`
+	headers := http.Header{}
+	headers.Add("Cookie", "session=abc; tester=yes")
+
+	actual := Explain(config, ExplainRequest{Path: "/?beta=1", Method: "GET", Headers: headers})
+	s.Equal("beta-be", actual.Backend)
+
+	actual = Explain(config, ExplainRequest{Path: "/?beta=1", Method: "POST", Headers: headers})
+	s.Empty(actual.Backend)
+
+	actual = Explain(config, ExplainRequest{Path: "/?beta=x", Method: "GET", Headers: headers})
+	s.Empty(actual.Backend)
+}
+
+func (s *ExplainTestSuite) Test_Explain_ReturnsDefaultBackend_WhenNoRuleMatches() {
+	config := `frontend services
+    acl url_api path_beg /api
+    use_backend api-be if url_api
+    default_backend fallback-be
+
+backend fallback-be
+    server fallback fallback:80`
+
+	actual := Explain(config, ExplainRequest{Path: "/other", Port: 80})
+
+	s.Equal("fallback-be", actual.Backend)
+	s.Equal([]string{"server fallback fallback:80"}, actual.Servers)
+	s.Len(actual.Rules, 1)
+}
+
+func (s *ExplainTestSuite) Test_Explain_EvaluatesNegationAndUnless() {
+	config := `frontend services
+    acl url_api path_beg /api
+    acl url_internal path_beg /api/internal
+    use_backend internal-be unless !url_internal
+    use_backend api-be if url_api !url_internal`
+
+	s.Equal("internal-be", Explain(config, ExplainRequest{Path: "/api/internal/1"}).Backend)
+	s.Equal("api-be", Explain(config, ExplainRequest{Path: "/api/users"}).Backend)
+}
+
+func (s *ExplainTestSuite) Test_Explain_ReportsUnsupportedAcls() {
+	config := `frontend services
+    acl from_office src 10.0.0.0/8
+    use_backend office-be if from_office missing_acl`
+
+	actual := Explain(config, ExplainRequest{Path: "/"})
+
+	s.Empty(actual.Backend)
+	s.NotEmpty(actual.Rules[0].Conditions[0].Message)
+	s.NotEmpty(actual.Rules[0].Conditions[1].Message)
+}
+
+func (s *ExplainTestSuite) Test_Explain_IgnoresOtherFrontends() {
+	config := `frontend other
+    acl url_api path_beg /
+    use_backend other-be if url_api
+
+frontend services
+    acl url_api path_beg /api
+    use_backend api-be if url_api`
+
+	actual := Explain(config, ExplainRequest{Path: "/other"})
+
+	s.Empty(actual.Backend)
+	s.Len(actual.Rules, 1)
+}
+
+// Suite
+
+func TestExplainUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ExplainTestSuite))
+}
@@ -2,11 +2,18 @@ package proxy
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"html/template"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
+
+	"../events"
+	"../metrics"
 )
 
 type HaProxy struct {
@@ -17,6 +24,12 @@ type HaProxy struct {
 // TODO: Change to pointer
 var Instance Proxy
 
+// defaultConfigsPath is the configurations directory used when ConfigsPath is not set
+const defaultConfigsPath = "/cfg"
+
+// reloadedConfigHash is the hash of the configuration and the certificates HAProxy was last reloaded with
+var reloadedConfigHash string
+
 type ConfigData struct {
 	CertsString          string
 	TimeoutConnect       string
@@ -28,6 +41,7 @@ type ConfigData struct {
 	StatsUser            string
 	StatsPass            string
 	UserList             string
+	Resolvers            string
 	ExtraGlobal          string
 	ExtraDefaults        string
 	ExtraFrontend        string
@@ -48,6 +62,11 @@ func (m HaProxy) AddCert(certName string) {
 	data.Certs[certName] = true
 }
 
+// RemoveCert removes the certificate from the list of certificates the configuration references
+func (m HaProxy) RemoveCert(certName string) {
+	delete(data.Certs, certName)
+}
+
 func (m HaProxy) GetCerts() map[string]string {
 	certs := map[string]string{}
 	for cert, _ := range data.Certs {
@@ -57,98 +76,234 @@ func (m HaProxy) GetCerts() map[string]string {
 	return certs
 }
 
+// RunCmd starts HAProxy in the master-worker mode as a supervised child process.
+// The process is restarted with backoff if it exits.
 func (m HaProxy) RunCmd(extraArgs []string) error {
+	configPath := m.getConfigPath()
 	args := []string{
+		"-W",
 		"-f",
-		"/cfg/haproxy.cfg",
-		"-D",
+		configPath,
 		"-p",
 		"/var/run/haproxy.pid",
 	}
 	args = append(args, extraArgs...)
-	cmd := exec.Command("haproxy", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmdRunHa(cmd); err != nil {
-		configData, _ := readConfigsFile("/cfg/haproxy.cfg")
-		return fmt.Errorf("Command %s\n%s\n%s", strings.Join(cmd.Args, " "), err.Error(), string(configData))
+	if err := haSupervisor.start(args); err != nil {
+		configData, _ := readConfigsFile(configPath)
+		return fmt.Errorf("Command haproxy %s\n%s\n%s", strings.Join(args, " "), err.Error(), string(configData))
 	}
 	return nil
 }
 
+// GetProcessState returns the state of the supervised HAProxy process
+func (m HaProxy) GetProcessState() ProcessState {
+	return haSupervisor.getState()
+}
+
 func (m HaProxy) CreateConfigFromTemplates() error {
-	configsContent, err := m.getConfigs()
+	configsContent, err := m.getConfigs(ConfigChanges{})
 	if err != nil {
 		return err
 	}
-	configPath := fmt.Sprintf("%s/haproxy.cfg", m.ConfigsPath)
-	return writeFile(configPath, []byte(configsContent), 0664)
+	configPath := m.getConfigPath()
+	tmpPath := configPath + ".tmp"
+	if err := writeFile(tmpPath, []byte(configsContent), 0664); err != nil {
+		return err
+	}
+	if err := m.validateConfig(tmpPath); err != nil {
+		removeFile(tmpPath)
+		return err
+	}
+	return renameFile(tmpPath, configPath)
+}
+
+func (m HaProxy) GetConfigDiff(changes ConfigChanges) (string, error) {
+	current, err := m.ReadConfig()
+	if err != nil {
+		current = ""
+	}
+	proposed, err := m.getConfigs(changes)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff("haproxy.cfg", "haproxy.cfg", current, proposed), nil
 }
 
 func (m HaProxy) ReadConfig() (string, error) {
-	configPath := fmt.Sprintf("%s/haproxy.cfg", m.ConfigsPath)
-	out, err := ReadFile(configPath)
+	out, err := ReadFile(m.getConfigPath())
 	if err != nil {
 		return "", err
 	}
 	return string(out[:]), nil
 }
 
-func (m HaProxy) Reload() error {
+// Reload reloads HAProxy. Requests received within the reload window are coalesced into a single reload.
+// HAProxy is not reloaded if neither the configuration nor the certificates changed since the last reload.
+func (m HaProxy) Reload() (reloaded bool, err error) {
+	return scheduler.schedule(m.reload)
+}
+
+func (m HaProxy) reload() (bool, error) {
+	hash, hashErr := m.getConfigHash()
+	if hashErr == nil && hash == reloadedConfigHash {
+		logPrintf("The configuration did not change. The proxy will not be reloaded")
+		return false, nil
+	}
 	logPrintf("Reloading the proxy")
-	pidPath := "/var/run/haproxy.pid"
-	pid, err := readPidFile(pidPath)
+	start := time.Now()
+	err := haSupervisor.reload()
+	metrics.ObserveReload(time.Now().Sub(start), err == nil)
 	if err != nil {
-		return fmt.Errorf("Could not read the %s file\n%s", pidPath, err.Error())
+		events.Publish(events.Event{Type: events.TypeReload, ConfigHash: hash, Message: err.Error()})
+		return false, fmt.Errorf("Could not reload the proxy\n%s", err.Error())
+	}
+	if hashErr == nil {
+		reloadedConfigHash = hash
+	}
+	events.Publish(events.Event{Type: events.TypeReload, ConfigHash: hash, Success: true})
+	return true, nil
+}
+
+// GetConfigHash returns the hash of the configuration HAProxy runs with and the certificates it references.
+// An empty string is returned if the configuration cannot be read.
+func GetConfigHash() string {
+	proxy, _ := Instance.(HaProxy)
+	hash, _ := proxy.getConfigHash()
+	return hash
+}
+
+// getConfigHash returns the hash of the configuration HAProxy runs with and the certificates it references
+func (m HaProxy) getConfigHash() (string, error) {
+	h := sha256.New()
+	config, err := readConfigsFile(m.getConfigPath())
+	if err != nil {
+		return "", err
+	}
+	h.Write(config)
+	for _, cert := range m.getCertNames() {
+		content, err := ReadFile(fmt.Sprintf("/certs/%s", cert))
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(cert))
+		h.Write(content)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// getConfigPath returns the path of the configuration HAProxy runs with
+func (m HaProxy) getConfigPath() string {
+	configsPath := m.ConfigsPath
+	if len(configsPath) == 0 {
+		configsPath = defaultConfigsPath
+	}
+	return fmt.Sprintf("%s/haproxy.cfg", configsPath)
+}
+
+func (m HaProxy) getCertNames() []string {
+	names := []string{}
+	for cert := range data.Certs {
+		names = append(names, cert)
 	}
-	cmdArgs := []string{"-sf", string(pid)}
-	return HaProxy{}.RunCmd(cmdArgs)
+	sort.Strings(names)
+	return names
 }
 
-func (m HaProxy) getConfigs() (string, error) {
-	contentArr := []string{}
-	configsFiles := []string{"haproxy.tmpl"}
+func (m HaProxy) validateConfig(configPath string) error {
+	var out bytes.Buffer
+	cmd := exec.Command("haproxy", "-c", "-f", configPath)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmdRunHa(cmd); err != nil {
+		return fmt.Errorf("The configuration is not valid\n%s\n%s", err.Error(), out.String())
+	}
+	return nil
+}
+
+func (m HaProxy) getConfigs(changes ConfigChanges) (string, error) {
 	configs, err := readConfigsDir(m.TemplatesPath)
 	if err != nil {
 		return "", fmt.Errorf("Could not read the directory %s\n%s", m.TemplatesPath, err.Error())
 	}
+	names := []string{}
+	existing := map[string]bool{}
 	for _, fi := range configs {
-		if strings.HasSuffix(fi.Name(), "-fe.cfg") {
-			configsFiles = append(configsFiles, fi.Name())
+		existing[fi.Name()] = true
+		if !changes.isRemoved(fi.Name()) {
+			names = append(names, fi.Name())
 		}
 	}
-	for _, fi := range configs {
-		if strings.HasSuffix(fi.Name(), "-be.cfg") {
-			configsFiles = append(configsFiles, fi.Name())
+	for name := range changes.Put {
+		if !existing[name] {
+			names = append(names, name)
 		}
 	}
-	for _, file := range configsFiles {
-		templateBytes, err := readConfigsFile(fmt.Sprintf("%s/%s", m.TemplatesPath, file))
-		if err != nil {
-			return "", fmt.Errorf("Could not read the file %s\n%s", file, err.Error())
+	sort.Strings(names)
+	frontends := []frontendSnippet{}
+	for _, name := range names {
+		if strings.HasSuffix(name, "-fe.cfg") {
+			content, err := m.readConfigFile(name, changes)
+			if err != nil {
+				return "", err
+			}
+			frontends = append(frontends, newFrontendSnippet(name, content))
 		}
-		contentArr = append(contentArr, string(templateBytes))
 	}
-	if len(configsFiles) == 1 {
+	sortFrontendSnippets(frontends)
+	mainContent, err := m.readConfigFile("haproxy.tmpl", changes)
+	if err != nil {
+		return "", err
+	}
+	contentArr := []string{mainContent}
+	for _, frontend := range frontends {
+		contentArr = append(contentArr, frontend.content)
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "-be.cfg") {
+			content, err := m.readConfigFile(name, changes)
+			if err != nil {
+				return "", err
+			}
+			contentArr = append(contentArr, content)
+		}
+	}
+	if len(contentArr) == 1 {
 		contentArr = append(contentArr, `    acl url_dummy path_beg /dummy
     use_backend dummy-be if url_dummy
 
 backend dummy-be
     server dummy 1.1.1.1:1111 check`)
 	}
-	tmpl, _ := template.New("contentTemplate").Parse(
+	tmpl, err := template.New("contentTemplate").Parse(
 		strings.Join(contentArr, "\n\n"),
 	)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the configuration template\n%s", err.Error())
+	}
 	var content bytes.Buffer
-	tmpl.Execute(&content, m.getConfigData())
+	if err := tmpl.Execute(&content, m.getConfigData()); err != nil {
+		return "", fmt.Errorf("Could not render the configuration template\n%s", err.Error())
+	}
 	return content.String(), nil
 }
 
+// readConfigFile returns the content of the file from the templates directory or, if it is one of the changes, the changed content
+func (m HaProxy) readConfigFile(file string, changes ConfigChanges) (string, error) {
+	if content, ok := changes.Put[file]; ok {
+		return content, nil
+	}
+	templateBytes, err := readConfigsFile(fmt.Sprintf("%s/%s", m.TemplatesPath, file))
+	if err != nil {
+		return "", fmt.Errorf("Could not read the file %s\n%s", file, err.Error())
+	}
+	return string(templateBytes), nil
+}
+
 func (m HaProxy) getConfigData() ConfigData {
 	certs := []string{}
 	if len(data.Certs) > 0 {
 		certs = append(certs, " ssl")
-		for cert, _ := range data.Certs {
+		for _, cert := range m.getCertNames() {
 			certs = append(certs, fmt.Sprintf("crt /certs/%s", cert))
 		}
 	}
@@ -187,14 +342,8 @@ func (m HaProxy) getConfigData() ConfigData {
 	if len(os.Getenv("STATS_PASS")) > 0 {
 		d.StatsPass = os.Getenv("STATS_PASS")
 	}
-	if len(os.Getenv("USERS")) > 0 {
-		d.UserList = "\nuserlist defaultUsers\n"
-		users := strings.Split(os.Getenv("USERS"), ",")
-		for _, user := range users {
-			userPass := strings.Split(user, ":")
-			d.UserList = fmt.Sprintf("%s    user %s insecure-password %s\n", d.UserList, userPass[0], userPass[1])
-		}
-	}
+	d.UserList = getUserList()
+	d.Resolvers = getResolvers()
 	if strings.EqualFold(os.Getenv("DEBUG"), "true") {
 		d.ExtraGlobal += `
     debug`
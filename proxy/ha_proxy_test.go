@@ -3,11 +3,14 @@
 package proxy
 
 import (
+	"../events"
 	"fmt"
 	"github.com/stretchr/testify/suite"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -89,8 +92,20 @@ func (s *HaProxyTestSuite) SetupTest() {
 	writeFile = func(filename string, data []byte, perm os.FileMode) error {
 		return nil
 	}
-	readPidFile = func(fileName string) ([]byte, error) {
-		return []byte(s.Pid), nil
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		return nil
+	}
+	renameFile = func(oldpath, newpath string) error {
+		return nil
+	}
+	removeFile = func(name string) error {
+		return nil
+	}
+	reloadedConfigHash = ""
+	pid, _ := strconv.Atoi(s.Pid)
+	haSupervisor = &supervisor{running: true, pid: pid}
+	signalHa = func(pid int, sig syscall.Signal) error {
+		return nil
 	}
 }
 
@@ -124,6 +139,18 @@ func (s HaProxyTestSuite) Test_AddCert_DoesNotStoreDuplicates() {
 	s.Equal(expected, data.Certs)
 }
 
+// RemoveCert
+
+func (s HaProxyTestSuite) Test_RemoveCert_RemovesCertificateName() {
+	dataOrig := data
+	defer func() { data = dataOrig }()
+	data.Certs = map[string]bool{"cert-1": true, "cert-2": true}
+
+	HaProxy{}.RemoveCert("cert-2")
+
+	s.Equal(map[string]bool{"cert-1": true}, data.Certs)
+}
+
 // GetCerts
 
 func (s HaProxyTestSuite) Test_GetCerts_ReturnsAllCerts() {
@@ -227,7 +254,7 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsExtraFrontEnd() {
 
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCert() {
 	var actualFilename string
-	expectedFilename := fmt.Sprintf("%s/haproxy.cfg", s.ConfigsPath)
+	expectedFilename := fmt.Sprintf("%s/haproxy.cfg.tmp", s.ConfigsPath)
 	var actualData string
 	expectedData := fmt.Sprintf(
 		"%s%s",
@@ -246,6 +273,76 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCert() {
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsCertsSortedByName() {
+	var actualData string
+	expectedData := fmt.Sprintf(
+		"%s%s",
+		strings.Replace(s.TemplateContent, "bind *:443", "bind *:443 ssl crt /certs/a.pem crt /certs/b.pem crt /certs/c.pem", -1),
+		s.ServicesContent,
+	)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		actualData = string(data)
+		return nil
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{"c.pem": true, "a.pem": true, "b.pem": true}).CreateConfigFromTemplates()
+
+	s.Equal(expectedData, actualData)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ValidatesTmpConfig() {
+	actual := s.mockHaExecCmd()
+	expected := []string{
+		"haproxy",
+		"-c",
+		"-f",
+		fmt.Sprintf("%s/haproxy.cfg.tmp", s.ConfigsPath),
+	}
+
+	NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Equal(expected, *actual)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_RenamesTmpConfig() {
+	var actualOld, actualNew string
+	renameFile = func(oldpath, newpath string) error {
+		actualOld = oldpath
+		actualNew = newpath
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.NoError(err)
+	s.Equal(fmt.Sprintf("%s/haproxy.cfg.tmp", s.ConfigsPath), actualOld)
+	s.Equal(fmt.Sprintf("%s/haproxy.cfg", s.ConfigsPath), actualNew)
+}
+
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenValidationFails() {
+	renamed := false
+	var actualRemoved string
+	cmdRunHa = func(cmd *exec.Cmd) error {
+		cmd.Stdout.Write([]byte("[ALERT] parsing [haproxy.cfg:10]: unknown keyword"))
+		return fmt.Errorf("exit status 1")
+	}
+	renameFile = func(oldpath, newpath string) error {
+		renamed = true
+		return nil
+	}
+	removeFile = func(name string) error {
+		actualRemoved = name
+		return nil
+	}
+
+	err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+	s.Error(err)
+	s.Contains(err.Error(), "unknown keyword")
+	s.False(renamed)
+	s.Equal(fmt.Sprintf("%s/haproxy.cfg.tmp", s.ConfigsPath), actualRemoved)
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_AddsUserList() {
 	var actualData string
 	usersOrig := os.Getenv("USERS")
@@ -346,6 +443,48 @@ backend dummy-be
 	s.Equal(expectedData, actualData)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_OrdersFrontendsByPriorityAndSpecificity() {
+	readConfigsDirOrig := readConfigsDir
+	defer func() {
+		readConfigsDir = readConfigsDirOrig
+	}()
+	readConfigsDir = func(dirname string) ([]os.FileInfo, error) {
+		return []os.FileInfo{}, nil
+	}
+	changes := ConfigChanges{
+		Put: map[string]string{
+			"api-fe.cfg": `
+    acl url_api path_beg /api
+    use_backend api-be if url_api`,
+			"users-fe.cfg": `
+    acl url_users path_beg /api/users
+    use_backend users-be if url_users`,
+			"legacy-fe.cfg": `
+    # priority 10
+    acl url_legacy path_beg /api/users/legacy
+    use_backend legacy-be if url_legacy`,
+			"api-canary-fe.cfg": `
+    acl url_api-canary path_beg /api
+    acl header_api-canary_1 req.hdr(X-Canary) -m str true
+    use_backend api-canary-be if url_api-canary header_api-canary_1`,
+			"api-be.cfg": "api be content",
+		},
+	}
+
+	actual, _ := HaProxy{TemplatesPath: s.TemplatesPath}.getConfigs(changes)
+
+	order := []int{
+		strings.Index(actual, "use_backend legacy-be"),
+		strings.Index(actual, "use_backend users-be"),
+		strings.Index(actual, "use_backend api-canary-be"),
+		strings.Index(actual, "use_backend api-be"),
+		strings.Index(actual, "api be content"),
+	}
+	for i := 1; i < len(order); i++ {
+		s.True(order[i-1] >= 0 && order[i-1] < order[i], actual)
+	}
+}
+
 func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenReadConfigsFileFails() {
 	readConfigsFileOrig := readConfigsFile
 	defer func() {
@@ -360,6 +499,88 @@ func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenReadCo
 	s.Error(err)
 }
 
+func (s HaProxyTestSuite) Test_CreateConfigFromTemplates_ReturnsError_WhenSnippetIsNotValidTemplate() {
+	readConfigsFileOrig := readConfigsFile
+	defer func() {
+		readConfigsFile = readConfigsFileOrig
+	}()
+	for _, snippet := range []string{"{{.ServiceName", "{{.ServiceName}}"} {
+		readConfigsFile = func(filename string) ([]byte, error) {
+			if strings.HasSuffix(filename, "-fe.cfg") {
+				return []byte(snippet), nil
+			}
+			return []byte(s.TemplateContent), nil
+		}
+		written := false
+		writeFile = func(filename string, data []byte, perm os.FileMode) error {
+			written = true
+			return nil
+		}
+
+		err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).CreateConfigFromTemplates()
+
+		s.Error(err, snippet)
+		s.False(written, snippet)
+	}
+}
+
+// GetConfigDiff
+
+func (s HaProxyTestSuite) Test_GetConfigDiff_ReturnsDiffWithAddedConfig() {
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	ReadFile = func(filename string) ([]byte, error) {
+		return []byte(s.TemplateContent + s.ServicesContent), nil
+	}
+	written := false
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		written = true
+		return nil
+	}
+	changes := ConfigChanges{
+		Put: map[string]string{
+			"config3-fe.cfg": "config3 fe content",
+			"config3-be.cfg": "config3 be content",
+		},
+	}
+
+	actual, err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).GetConfigDiff(changes)
+
+	s.NoError(err)
+	s.Contains(actual, "+config3 fe content")
+	s.Contains(actual, "+config3 be content")
+	s.NotContains(actual, "-config")
+	s.False(written)
+}
+
+func (s HaProxyTestSuite) Test_GetConfigDiff_ReturnsDiffWithRemovedConfig() {
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	ReadFile = func(filename string) ([]byte, error) {
+		return []byte(s.TemplateContent + s.ServicesContent), nil
+	}
+	changes := ConfigChanges{Remove: []string{"config1-fe.cfg", "config1-be.cfg"}}
+
+	actual, err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).GetConfigDiff(changes)
+
+	s.NoError(err)
+	s.Contains(actual, "-config1 fe content")
+	s.Contains(actual, "-config1 be content")
+	s.NotContains(actual, "-config2")
+}
+
+func (s HaProxyTestSuite) Test_GetConfigDiff_ReturnsError_WhenReadDirFails() {
+	readConfigsDirOrig := readConfigsDir
+	defer func() { readConfigsDir = readConfigsDirOrig }()
+	readConfigsDir = func(dirname string) ([]os.FileInfo, error) {
+		return nil, fmt.Errorf("Could not read the directory")
+	}
+
+	_, err := NewHaProxy(s.TemplatesPath, s.ConfigsPath, map[string]bool{}).GetConfigDiff(ConfigChanges{})
+
+	s.Error(err)
+}
+
 // ReadConfig
 
 func (s *HaProxyTestSuite) Test_ReadConfig_ReturnsConfig() {
@@ -397,54 +618,250 @@ func (s *HaProxyTestSuite) Test_ReadConfig_ReturnsError_WhenReadFileFails() {
 
 // Reload
 
-func (s *HaProxyTestSuite) Test_Reload_ReadsPidFile() {
-	var actual string
-	readPidFile = func(fileName string) ([]byte, error) {
-		actual = fileName
-		return []byte("12345"), nil
+func (s *HaProxyTestSuite) Test_Reload_SendsSIGUSR2ToMaster() {
+	actualPid := 0
+	var actualSig syscall.Signal
+	signalHa = func(pid int, sig syscall.Signal) error {
+		actualPid = pid
+		actualSig = sig
+		return nil
 	}
 
 	HaProxy{}.Reload()
 
-	s.Equal("/var/run/haproxy.pid", actual)
+	s.Equal(s.Pid, strconv.Itoa(actualPid))
+	s.Equal(syscall.SIGUSR2, actualSig)
 }
 
-func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenHaCommandFails() {
-	cmdRunHa = func(cmd *exec.Cmd) error {
+func (s *HaProxyTestSuite) Test_Reload_ReturnsTrue() {
+	reloaded, _ := HaProxy{}.Reload()
+
+	s.True(reloaded)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_DoesNotReload_WhenConfigDidNotChange() {
+	dataOrig := data
+	defer func() { data = dataOrig }()
+	data.Certs = map[string]bool{}
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("my-config"), nil
+	}
+	runs := 0
+	signalHa = func(pid int, sig syscall.Signal) error {
+		runs++
+		return nil
+	}
+
+	HaProxy{}.Reload()
+	reloaded, err := HaProxy{}.Reload()
+
+	s.NoError(err)
+	s.False(reloaded)
+	s.Equal(1, runs)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_Reloads_WhenConfigChanged() {
+	dataOrig := data
+	defer func() { data = dataOrig }()
+	data.Certs = map[string]bool{}
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	config := "my-config"
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte(config), nil
+	}
+
+	HaProxy{}.Reload()
+	config = "my-new-config"
+	reloaded, _ := HaProxy{}.Reload()
+
+	s.True(reloaded)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_Reloads_WhenCertChanged() {
+	dataOrig := data
+	defer func() { data = dataOrig }()
+	data.Certs = map[string]bool{"my-cert.pem": true}
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("my-config"), nil
+	}
+	readFileOrig := ReadFile
+	defer func() { ReadFile = readFileOrig }()
+	cert := "my-cert"
+	ReadFile = func(filename string) ([]byte, error) {
+		return []byte(cert), nil
+	}
+
+	HaProxy{}.Reload()
+	cert = "my-new-cert"
+	reloaded, _ := HaProxy{}.Reload()
+
+	s.True(reloaded)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_Reloads_WhenPreviousReloadFailed() {
+	dataOrig := data
+	defer func() { data = dataOrig }()
+	data.Certs = map[string]bool{}
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("my-config"), nil
+	}
+	signalHa = func(pid int, sig syscall.Signal) error {
 		return fmt.Errorf("This is an error")
 	}
+	HaProxy{}.Reload()
+	signalHa = func(pid int, sig syscall.Signal) error {
+		return nil
+	}
+
+	reloaded, _ := HaProxy{}.Reload()
+
+	s.True(reloaded)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_HashesConfigFromConfigsPath() {
+	dataOrig := data
+	defer func() { data = dataOrig }()
+	data.Certs = map[string]bool{}
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	actual := []string{}
+	readConfigsFile = func(filename string) ([]byte, error) {
+		actual = append(actual, filename)
+		return []byte("my-config"), nil
+	}
 
-	err := HaProxy{}.Reload()
+	HaProxy{ConfigsPath: "/my/configs"}.Reload()
+
+	s.Equal([]string{"/my/configs/haproxy.cfg"}, actual)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenSignalFails() {
+	signalHa = func(pid int, sig syscall.Signal) error {
+		return fmt.Errorf("This is an error")
+	}
+
+	_, err := HaProxy{}.Reload()
 
 	s.Error(err)
 }
 
-func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenReadPidFails() {
-	readPidFile = func(fileName string) ([]byte, error) {
-		return []byte(""), fmt.Errorf("This is an error")
+func (s *HaProxyTestSuite) Test_Reload_PublishesEventWithConfigHash() {
+	readConfigsFileOrig := readConfigsFile
+	defer func() { readConfigsFile = readConfigsFileOrig }()
+	readConfigsFile = func(filename string) ([]byte, error) {
+		return []byte("my-config"), nil
 	}
+	ch := events.Subscribe(0)
+	defer events.Unsubscribe(ch)
 
-	err := HaProxy{}.Reload()
+	HaProxy{}.Reload()
+
+	actual := <-ch
+	s.Equal(events.TypeReload, actual.Type)
+	s.True(actual.Success)
+	s.Equal(GetConfigHash(), actual.ConfigHash)
+	s.NotEmpty(actual.ConfigHash)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_PublishesFailureEvent_WhenSignalFails() {
+	signalHa = func(pid int, sig syscall.Signal) error {
+		return fmt.Errorf("This is an error")
+	}
+	ch := events.Subscribe(0)
+	defer events.Unsubscribe(ch)
+
+	HaProxy{}.Reload()
+
+	actual := <-ch
+	s.Equal(events.TypeReload, actual.Type)
+	s.False(actual.Success)
+	s.Equal("This is an error", actual.Message)
+}
+
+func (s *HaProxyTestSuite) Test_Reload_ReturnsError_WhenHaProxyIsNotRunning() {
+	haSupervisor = &supervisor{}
+
+	_, err := HaProxy{}.Reload()
 
 	s.Error(err)
 }
 
-func (s *HaProxyTestSuite) Test_Reload_RunsRunCmd() {
-	actual := HaProxyTestSuite{}.mockHaExecCmd()
+// RunCmd
+
+func (s *HaProxyTestSuite) Test_RunCmd_StartsHaProxyInMasterWorkerMode() {
+	haSupervisor = &supervisor{}
+	cmdStartHaOrig := cmdStartHa
+	cmdWaitHaOrig := cmdWaitHa
+	defer func() {
+		cmdStartHa = cmdStartHaOrig
+		cmdWaitHa = cmdWaitHaOrig
+	}()
+	var actual []string
+	cmdStartHa = func(cmd *exec.Cmd) (int, error) {
+		actual = cmd.Args
+		return 123, nil
+	}
+	cmdWaitHa = func(cmd *exec.Cmd) error {
+		select {}
+	}
 	expected := []string{
 		"haproxy",
+		"-W",
 		"-f",
 		"/cfg/haproxy.cfg",
-		"-D",
 		"-p",
 		"/var/run/haproxy.pid",
-		"-sf",
-		s.Pid,
 	}
 
-	HaProxy{}.Reload()
+	err := HaProxy{}.RunCmd([]string{})
 
-	s.Equal(expected, *actual)
+	s.NoError(err)
+	s.Equal(expected, actual)
+	s.True(HaProxy{}.GetProcessState().Running)
+	s.Equal(123, HaProxy{}.GetProcessState().Pid)
+}
+
+func (s *HaProxyTestSuite) Test_RunCmd_UsesConfigFromConfigsPath() {
+	haSupervisor = &supervisor{}
+	cmdStartHaOrig := cmdStartHa
+	cmdWaitHaOrig := cmdWaitHa
+	defer func() {
+		cmdStartHa = cmdStartHaOrig
+		cmdWaitHa = cmdWaitHaOrig
+	}()
+	var actual []string
+	cmdStartHa = func(cmd *exec.Cmd) (int, error) {
+		actual = cmd.Args
+		return 123, nil
+	}
+	cmdWaitHa = func(cmd *exec.Cmd) error {
+		select {}
+	}
+
+	HaProxy{ConfigsPath: "/my/configs"}.RunCmd([]string{})
+
+	s.Equal("/my/configs/haproxy.cfg", actual[3])
+}
+
+func (s *HaProxyTestSuite) Test_RunCmd_ReturnsError_WhenStartFails() {
+	haSupervisor = &supervisor{}
+	cmdStartHaOrig := cmdStartHa
+	defer func() { cmdStartHa = cmdStartHaOrig }()
+	cmdStartHa = func(cmd *exec.Cmd) (int, error) {
+		return 0, fmt.Errorf("This is an error")
+	}
+
+	err := HaProxy{}.RunCmd([]string{})
+
+	s.Error(err)
+	s.False(HaProxy{}.GetProcessState().Running)
 }
 
 // Mocks
@@ -8,13 +8,36 @@ type Data struct {
 
 var data = Data{}
 
+// ConfigChanges describes changes of the configuration snippets that are not stored in the templates directory
+type ConfigChanges struct {
+	// Put maps the names of the snippets (e.g. my-service-fe.cfg) to their new content
+	Put    map[string]string
+	Remove []string
+}
+
+func (c ConfigChanges) isRemoved(name string) bool {
+	for _, r := range c.Remove {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
 type Proxy interface {
 	RunCmd(extraArgs []string) error
 	CreateConfigFromTemplates() error
 	ReadConfig() (string, error)
-	Reload() error
+	GetConfigDiff(changes ConfigChanges) (string, error)
+	Reload() (reloaded bool, err error)
 	AddCert(certName string)
+	RemoveCert(certName string)
 	GetCerts() map[string]string
+	SetServerState(backend, server, state string) error
+	SetServerWeight(backend, server string, weight int) error
+	GetServerState(backend, server string) (ServerState, error)
+	GetProcessState() ProcessState
+	GetStats() ([]Stats, error)
 }
 
 // Mock
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// reloadScheduler coalesces reload requests received within a window into a single reload
+// and limits the number of reloads per second. Each request waits for the reload that
+// includes it and receives its result.
+type reloadScheduler struct {
+	mu          sync.Mutex
+	reloadMu    sync.Mutex
+	window      time.Duration
+	minInterval time.Duration
+	lastReload  time.Time
+	pending     []chan reloadResult
+	scheduled   bool
+}
+
+type reloadResult struct {
+	reloaded bool
+	err      error
+}
+
+var scheduler = &reloadScheduler{}
+
+// SetReloadSchedule sets the window within which reload requests are coalesced
+// and the maximum number of reloads per second. Zero maxPerSecond means no limit.
+func SetReloadSchedule(window time.Duration, maxPerSecond int) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.window = window
+	scheduler.minInterval = 0
+	if maxPerSecond > 0 {
+		scheduler.minInterval = time.Second / time.Duration(maxPerSecond)
+	}
+}
+
+func (s *reloadScheduler) schedule(reload func() (bool, error)) (bool, error) {
+	c := make(chan reloadResult, 1)
+	s.mu.Lock()
+	s.pending = append(s.pending, c)
+	if !s.scheduled {
+		s.scheduled = true
+		delay := s.window
+		if wait := s.lastReload.Add(s.minInterval).Sub(time.Now()); wait > delay {
+			delay = wait
+		}
+		time.AfterFunc(delay, func() { s.run(reload) })
+	}
+	s.mu.Unlock()
+	result := <-c
+	return result.reloaded, result.err
+}
+
+func (s *reloadScheduler) run(reload func() (bool, error)) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.mu.Lock()
+	wait := s.lastReload.Add(s.minInterval).Sub(time.Now())
+	s.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.scheduled = false
+	s.mu.Unlock()
+	if len(pending) > 1 {
+		logPrintf("Coalescing %d reload requests", len(pending))
+	}
+	reloaded, err := reload()
+	if reloaded {
+		s.mu.Lock()
+		s.lastReload = time.Now()
+		s.mu.Unlock()
+	}
+	for _, c := range pending {
+		c <- reloadResult{reloaded: reloaded, err: err}
+	}
+}
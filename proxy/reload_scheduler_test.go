@@ -0,0 +1,128 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+	"time"
+)
+
+type ReloadSchedulerTestSuite struct {
+	suite.Suite
+}
+
+// Suite
+
+func TestReloadSchedulerUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ReloadSchedulerTestSuite))
+}
+
+// schedule
+
+func (s ReloadSchedulerTestSuite) Test_Schedule_CoalescesRequestsWithinWindow() {
+	rs := &reloadScheduler{window: 50 * time.Millisecond}
+	reloads := 0
+	reload := func() (bool, error) {
+		reloads++
+		return true, nil
+	}
+	errs := make(chan error, 5)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rs.schedule(reload)
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+
+	s.Equal(1, reloads)
+	for i := 0; i < 5; i++ {
+		s.NoError(<-errs)
+	}
+}
+
+func (s ReloadSchedulerTestSuite) Test_Schedule_ReturnsReloadError_ToAllRequests() {
+	rs := &reloadScheduler{window: 50 * time.Millisecond}
+	reload := func() (bool, error) {
+		return false, fmt.Errorf("This is an error")
+	}
+	errs := make(chan error, 3)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rs.schedule(reload)
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 3; i++ {
+		s.Error(<-errs)
+	}
+}
+
+func (s ReloadSchedulerTestSuite) Test_Schedule_ReloadsAgain_WhenRequestedAfterPreviousReload() {
+	rs := &reloadScheduler{}
+	reloads := 0
+	reload := func() (bool, error) {
+		reloads++
+		return true, nil
+	}
+
+	rs.schedule(reload)
+	rs.schedule(reload)
+
+	s.Equal(2, reloads)
+}
+
+func (s ReloadSchedulerTestSuite) Test_Schedule_LimitsReloadsPerSecond() {
+	rs := &reloadScheduler{minInterval: 100 * time.Millisecond}
+	reloadTimes := []time.Time{}
+	reload := func() (bool, error) {
+		reloadTimes = append(reloadTimes, time.Now())
+		return true, nil
+	}
+
+	rs.schedule(reload)
+	rs.schedule(reload)
+
+	s.Equal(2, len(reloadTimes))
+	s.True(reloadTimes[1].Sub(reloadTimes[0]) >= 100*time.Millisecond)
+}
+
+func (s ReloadSchedulerTestSuite) Test_Schedule_ReturnsReloaded() {
+	rs := &reloadScheduler{}
+	reloaded := false
+	reload := func() (bool, error) {
+		return reloaded, nil
+	}
+
+	actual, _ := rs.schedule(reload)
+	s.False(actual)
+
+	reloaded = true
+	actual, _ = rs.schedule(reload)
+	s.True(actual)
+}
+
+// SetReloadSchedule
+
+func (s ReloadSchedulerTestSuite) Test_SetReloadSchedule_SetsWindowAndMinInterval() {
+	defer SetReloadSchedule(0, 0)
+
+	SetReloadSchedule(time.Second, 4)
+
+	s.Equal(time.Second, scheduler.window)
+	s.Equal(250*time.Millisecond, scheduler.minInterval)
+}
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolversName is the name of the resolvers section used by the servers that resolve the addresses of Swarm services at runtime
+const ResolversName = "docker"
+
+// dockerNameserver is the address of the DNS server embedded in Docker
+const dockerNameserver = "127.0.0.11:53"
+
+// getResolvers returns the resolvers section. The nameservers are read from the comma separated RESOLVERS env var.
+// Docker's embedded DNS server is used when the variable is not set.
+func getResolvers() string {
+	nameservers := dockerNameserver
+	if len(os.Getenv("RESOLVERS")) > 0 {
+		nameservers = os.Getenv("RESOLVERS")
+	}
+	retries := "3"
+	if len(os.Getenv("RESOLVERS_RETRIES")) > 0 {
+		retries = os.Getenv("RESOLVERS_RETRIES")
+	}
+	holdValid := "10"
+	if len(os.Getenv("RESOLVERS_HOLD_VALID")) > 0 {
+		holdValid = os.Getenv("RESOLVERS_HOLD_VALID")
+	}
+	resolvers := fmt.Sprintf("\nresolvers %s\n", ResolversName)
+	for i, nameserver := range strings.Split(nameservers, ",") {
+		resolvers += fmt.Sprintf("    nameserver dns%d %s\n", i+1, strings.TrimSpace(nameserver))
+	}
+	return fmt.Sprintf("%s    resolve_retries %s\n    timeout retry 1s\n    hold valid %ss\n", resolvers, retries, holdValid)
+}
@@ -0,0 +1,61 @@
+// +build !integration
+
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolversTestSuite struct {
+	suite.Suite
+}
+
+// getResolvers
+
+func (s ResolversTestSuite) Test_GetResolvers_UsesDockerDNS_WhenEnvVarsAreNotSet() {
+	expected := `
+resolvers docker
+    nameserver dns1 127.0.0.11:53
+    resolve_retries 3
+    timeout retry 1s
+    hold valid 10s
+`
+
+	s.Equal(expected, getResolvers())
+}
+
+func (s ResolversTestSuite) Test_GetResolvers_UsesEnvVars() {
+	for _, key := range []string{"RESOLVERS", "RESOLVERS_RETRIES", "RESOLVERS_HOLD_VALID"} {
+		defer func(key, value string) { os.Setenv(key, value) }(key, os.Getenv(key))
+	}
+	os.Setenv("RESOLVERS", "10.0.0.2:53, 10.0.0.3:53")
+	os.Setenv("RESOLVERS_RETRIES", "5")
+	os.Setenv("RESOLVERS_HOLD_VALID", "30")
+	expected := `
+resolvers docker
+    nameserver dns1 10.0.0.2:53
+    nameserver dns2 10.0.0.3:53
+    resolve_retries 5
+    timeout retry 1s
+    hold valid 30s
+`
+
+	s.Equal(expected, getResolvers())
+}
+
+// getConfigData
+
+func (s ResolversTestSuite) Test_GetConfigData_SetsResolvers() {
+	actual := HaProxy{}.getConfigData()
+
+	s.Equal(getResolvers(), actual.Resolvers)
+}
+
+// Suite
+
+func TestResolversUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ResolversTestSuite))
+}
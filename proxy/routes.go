@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var priorityRegexp = regexp.MustCompile(`(?m)^\s*#\s*priority\s+(-?[0-9]+)\s*$`)
+var urlAclRegexp = regexp.MustCompile(`(?m)^\s*acl\s+url_\S+\s+(.+)$`)
+var useBackendRegexp = regexp.MustCompile(`(?m)^\s*use_backend\s+\S+\s+if\s+(.+)$`)
+
+// frontendSnippet is the content of a frontend configuration file and the keys it is ordered by
+type frontendSnippet struct {
+	name       string
+	content    string
+	priority   int
+	pathLength int
+	conditions int
+}
+
+type frontendSnippets []frontendSnippet
+
+func (s frontendSnippets) Len() int      { return len(s) }
+func (s frontendSnippets) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s frontendSnippets) Less(i, j int) bool {
+	if s[i].priority != s[j].priority {
+		return s[i].priority > s[j].priority
+	}
+	if s[i].pathLength != s[j].pathLength {
+		return s[i].pathLength > s[j].pathLength
+	}
+	return s[i].conditions > s[j].conditions
+}
+
+// newFrontendSnippet reads the ordering keys from the content. The priority is set through the "# priority <number>" comment.
+// The specificity is the length of the longest path of the url ACL and the number of conditions of the use_backend lines.
+// The conditions that only select the port are not counted.
+func newFrontendSnippet(name, content string) frontendSnippet {
+	snippet := frontendSnippet{name: name, content: content}
+	if match := priorityRegexp.FindStringSubmatch(content); match != nil {
+		snippet.priority, _ = strconv.Atoi(match[1])
+	}
+	for _, match := range urlAclRegexp.FindAllStringSubmatch(content, -1) {
+		fields := strings.Fields(match[1])
+		for i := 1; i < len(fields); i += 2 {
+			if len(fields[i]) > snippet.pathLength {
+				snippet.pathLength = len(fields[i])
+			}
+		}
+	}
+	for _, match := range useBackendRegexp.FindAllStringSubmatch(content, -1) {
+		conditions := 0
+		for _, condition := range strings.Fields(match[1]) {
+			if !strings.HasPrefix(condition, "http_") && !strings.HasPrefix(condition, "https_") {
+				conditions++
+			}
+		}
+		if conditions > snippet.conditions {
+			snippet.conditions = conditions
+		}
+	}
+	return snippet
+}
+
+// sortFrontendSnippets orders the snippets by their priorities and, when they are equal, by their specificity,
+// so that the use_backend lines of the more specific routes are evaluated first. The order of the snippets
+// with the same priority and specificity does not change.
+func sortFrontendSnippets(snippets []frontendSnippet) {
+	sort.Stable(frontendSnippets(snippets))
+}
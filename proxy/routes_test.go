@@ -0,0 +1,69 @@
+// +build !integration
+
+package proxy
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type RoutesTestSuite struct {
+	suite.Suite
+}
+
+// newFrontendSnippet
+
+func (s *RoutesTestSuite) Test_NewFrontendSnippet_ReadsPriorityAndSpecificity() {
+	actual := newFrontendSnippet("my-service-fe.cfg", `
+    # priority -5
+    acl url_my-service path_beg /api path_beg /api/v1/users
+    acl domain_my-service hdr_dom(host) -i my-domain.com
+    acl http_my-service dst_port 80
+    acl https_my-service dst_port 443
+    use_backend my-service-be if url_my-service domain_my-service http_my-service
+    use_backend https-my-service-be if url_my-service domain_my-service https_my-service`)
+
+	s.Equal(-5, actual.priority)
+	s.Equal(len("/api/v1/users"), actual.pathLength)
+	s.Equal(2, actual.conditions)
+}
+
+func (s *RoutesTestSuite) Test_NewFrontendSnippet_ReturnsZeroKeys_WhenContentIsNotGenerated() {
+	actual := newFrontendSnippet("my-service-fe.cfg", "my custom content")
+
+	s.Equal(frontendSnippet{name: "my-service-fe.cfg", content: "my custom content"}, actual)
+}
+
+// sortFrontendSnippets
+
+func (s *RoutesTestSuite) Test_SortFrontendSnippets_OrdersByPriorityPathLengthAndConditions() {
+	snippets := []frontendSnippet{
+		{name: "a", pathLength: 4, conditions: 1},
+		{name: "b", pathLength: 10, conditions: 1},
+		{name: "c", pathLength: 4, conditions: 2},
+		{name: "d", priority: 1, pathLength: 1, conditions: 1},
+		{name: "e", priority: -1, pathLength: 20, conditions: 1},
+	}
+
+	sortFrontendSnippets(snippets)
+
+	actual := []string{}
+	for _, snippet := range snippets {
+		actual = append(actual, snippet.name)
+	}
+	s.Equal([]string{"d", "b", "c", "a", "e"}, actual)
+}
+
+func (s *RoutesTestSuite) Test_SortFrontendSnippets_KeepsOrder_WhenKeysAreEqual() {
+	snippets := []frontendSnippet{{name: "a"}, {name: "b"}, {name: "c"}}
+
+	sortFrontendSnippets(snippets)
+
+	s.Equal([]frontendSnippet{{name: "a"}, {name: "b"}, {name: "c"}}, snippets)
+}
+
+// Suite
+
+func TestRoutesUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RoutesTestSuite))
+}
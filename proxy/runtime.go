@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuntimeSocket is the path of the HAProxy admin socket defined in the global section of haproxy.tmpl
+var RuntimeSocket = "/var/run/haproxy.sock"
+
+var runtimeTimeout = 5 * time.Second
+
+const (
+	ServerStateReady = "ready"
+	ServerStateDrain = "drain"
+	ServerStateMaint = "maint"
+)
+
+// Admin state flags of the "show servers state" output
+const (
+	adminStateForcedMaint    = 0x01
+	adminStateInheritedMaint = 0x02
+	adminStateConfigMaint    = 0x04
+	adminStateForcedDrain    = 0x08
+	adminStateInheritedDrain = 0x10
+	adminStateResolverMaint  = 0x20
+)
+
+var operationalStates = map[string]string{
+	"0": "stopped",
+	"1": "starting",
+	"2": "running",
+	"3": "stopping",
+}
+
+// ServerState is the state of a backend server as reported by the HAProxy runtime API
+type ServerState struct {
+	Backend          string
+	Server           string
+	Address          string
+	State            string
+	OperationalState string
+	Weight           int
+}
+
+// SetServerState sets the administrative state of the server to ready, drain or maint without reloading the proxy
+func (m HaProxy) SetServerState(backend, server, state string) error {
+	if err := validateRuntimeNames(backend, server); err != nil {
+		return err
+	}
+	if state != ServerStateReady && state != ServerStateDrain && state != ServerStateMaint {
+		return fmt.Errorf("The state %s is not valid. Allowed states are %s, %s, and %s", state, ServerStateReady, ServerStateDrain, ServerStateMaint)
+	}
+	return m.runRuntimeSetCmd(fmt.Sprintf("set server %s/%s state %s", backend, server, state))
+}
+
+// SetServerWeight sets the weight of the server without reloading the proxy
+func (m HaProxy) SetServerWeight(backend, server string, weight int) error {
+	if err := validateRuntimeNames(backend, server); err != nil {
+		return err
+	}
+	if weight < 0 || weight > 256 {
+		return fmt.Errorf("The weight %d is not valid. It must be between 0 and 256", weight)
+	}
+	return m.runRuntimeSetCmd(fmt.Sprintf("set server %s/%s weight %d", backend, server, weight))
+}
+
+// GetServerState returns the state of the server
+func (m HaProxy) GetServerState(backend, server string) (ServerState, error) {
+	if err := validateRuntimeNames(backend, server); err != nil {
+		return ServerState{}, err
+	}
+	out, err := runRuntimeCmd(fmt.Sprintf("show servers state %s", backend))
+	if err != nil {
+		return ServerState{}, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight ...
+		if len(fields) < 8 || fields[1] != backend || fields[3] != server {
+			continue
+		}
+		adminState, err := strconv.Atoi(fields[6])
+		if err != nil {
+			return ServerState{}, fmt.Errorf("Could not parse the state of the server %s/%s\n%s", backend, server, err.Error())
+		}
+		weight, err := strconv.Atoi(fields[7])
+		if err != nil {
+			return ServerState{}, fmt.Errorf("Could not parse the weight of the server %s/%s\n%s", backend, server, err.Error())
+		}
+		return ServerState{
+			Backend:          backend,
+			Server:           server,
+			Address:          fields[4],
+			State:            getAdminState(adminState),
+			OperationalState: operationalStates[fields[5]],
+			Weight:           weight,
+		}, nil
+	}
+	if len(strings.TrimSpace(out)) > 0 && !strings.HasPrefix(strings.TrimSpace(out), "1") {
+		return ServerState{}, fmt.Errorf("Could not get the state of the server %s/%s\n%s", backend, server, strings.TrimSpace(out))
+	}
+	return ServerState{}, fmt.Errorf("The server %s/%s was not found", backend, server)
+}
+
+func (m HaProxy) runRuntimeSetCmd(cmd string) error {
+	out, err := runRuntimeCmd(cmd)
+	if err != nil {
+		return err
+	}
+	// HAProxy responds to successful set commands with an empty line
+	if msg := strings.TrimSpace(out); len(msg) > 0 {
+		return fmt.Errorf("The command \"%s\" failed\n%s", cmd, msg)
+	}
+	logPrintf("Executed the runtime command \"%s\"", cmd)
+	return nil
+}
+
+func runRuntimeCmd(cmd string) (string, error) {
+	conn, err := net.DialTimeout("unix", RuntimeSocket, runtimeTimeout)
+	if err != nil {
+		return "", fmt.Errorf("Could not connect to the HAProxy socket %s\n%s", RuntimeSocket, err.Error())
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(runtimeTimeout))
+	if _, err := conn.Write([]byte(cmd + "\n")); err != nil {
+		return "", fmt.Errorf("Could not send the command \"%s\" to the HAProxy socket\n%s", cmd, err.Error())
+	}
+	out, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("Could not read the response to the command \"%s\" from the HAProxy socket\n%s", cmd, err.Error())
+	}
+	return string(out), nil
+}
+
+func getAdminState(adminState int) string {
+	if adminState&(adminStateForcedMaint|adminStateInheritedMaint|adminStateConfigMaint|adminStateResolverMaint) > 0 {
+		return ServerStateMaint
+	}
+	if adminState&(adminStateForcedDrain|adminStateInheritedDrain) > 0 {
+		return ServerStateDrain
+	}
+	return ServerStateReady
+}
+
+// validateRuntimeNames prevents names from injecting additional runtime API commands
+func validateRuntimeNames(names ...string) error {
+	for _, name := range names {
+		if len(name) == 0 || strings.ContainsAny(name, " \t\r\n;/") {
+			return fmt.Errorf("The name \"%s\" is not a valid backend or server name", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// +build !integration
+
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+type RuntimeTestSuite struct {
+	suite.Suite
+	listener  net.Listener
+	socketDir string
+	commands  chan string
+	response  string
+}
+
+// Suite
+
+func TestRuntimeUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(RuntimeTestSuite))
+}
+
+func (s *RuntimeTestSuite) SetupTest() {
+	s.socketDir, _ = ioutil.TempDir("", "runtime")
+	RuntimeSocket = fmt.Sprintf("%s/haproxy.sock", s.socketDir)
+	s.listener, _ = net.Listen("unix", RuntimeSocket)
+	s.commands = make(chan string, 10)
+	s.response = ""
+	go s.serve(s.listener)
+}
+
+func (s *RuntimeTestSuite) TearDownTest() {
+	s.listener.Close()
+	os.RemoveAll(s.socketDir)
+}
+
+// SetServerState
+
+func (s *RuntimeTestSuite) Test_SetServerState_SendsCommand() {
+	for _, state := range []string{"ready", "drain", "maint"} {
+		err := HaProxy{}.SetServerState("my-service-be", "my-service", state)
+
+		s.NoError(err)
+		s.Equal(fmt.Sprintf("set server my-service-be/my-service state %s", state), <-s.commands)
+	}
+}
+
+func (s *RuntimeTestSuite) Test_SetServerState_ReturnsError_WhenStateIsNotValid() {
+	err := HaProxy{}.SetServerState("my-service-be", "my-service", "sleeping")
+
+	s.Error(err)
+	s.Len(s.commands, 0)
+}
+
+func (s *RuntimeTestSuite) Test_SetServerState_ReturnsError_WhenNamesContainCommandSeparators() {
+	err := HaProxy{}.SetServerState("my-service-be;shutdown", "my-service", "ready")
+
+	s.Error(err)
+	s.Len(s.commands, 0)
+}
+
+func (s *RuntimeTestSuite) Test_SetServerState_ReturnsError_WhenHaProxyRespondsWithMessage() {
+	s.response = "No such server.\n"
+
+	err := HaProxy{}.SetServerState("my-service-be", "other-service", "ready")
+
+	s.Error(err)
+	s.Contains(err.Error(), "No such server.")
+}
+
+func (s *RuntimeTestSuite) Test_SetServerState_ReturnsError_WhenSocketDoesNotExist() {
+	RuntimeSocket = "/this/socket/does/not/exist.sock"
+
+	err := HaProxy{}.SetServerState("my-service-be", "my-service", "ready")
+
+	s.Error(err)
+}
+
+// SetServerWeight
+
+func (s *RuntimeTestSuite) Test_SetServerWeight_SendsCommand() {
+	err := HaProxy{}.SetServerWeight("my-service-be", "my-service", 50)
+
+	s.NoError(err)
+	s.Equal("set server my-service-be/my-service weight 50", <-s.commands)
+}
+
+func (s *RuntimeTestSuite) Test_SetServerWeight_ReturnsError_WhenWeightIsOutOfRange() {
+	for _, weight := range []int{-1, 257} {
+		err := HaProxy{}.SetServerWeight("my-service-be", "my-service", weight)
+
+		s.Error(err)
+	}
+	s.Len(s.commands, 0)
+}
+
+// GetServerState
+
+func (s *RuntimeTestSuite) Test_GetServerState_ReturnsState() {
+	s.response = `1
+# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight srv_iweight srv_time_since_last_change srv_check_status srv_check_result srv_check_health srv_check_state srv_agent_state bk_f_forced_id srv_f_forced_id
+3 my-service-be 1 other-service 10.0.0.4 2 0 1 1 100 1 0 3 0 0 0 0
+3 my-service-be 2 my-service 10.0.0.5 2 8 25 1 100 1 0 3 0 0 0 0
+
+`
+	expected := ServerState{
+		Backend:          "my-service-be",
+		Server:           "my-service",
+		Address:          "10.0.0.5",
+		State:            "drain",
+		OperationalState: "running",
+		Weight:           25,
+	}
+
+	actual, err := HaProxy{}.GetServerState("my-service-be", "my-service")
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+	s.Equal("show servers state my-service-be", <-s.commands)
+}
+
+func (s *RuntimeTestSuite) Test_GetServerState_ReturnsMaint_WhenAdminStateHasMaintFlags() {
+	for _, adminState := range []int{1, 2, 4, 9, 32} {
+		s.response = fmt.Sprintf("1\n3 my-service-be 1 my-service 10.0.0.5 0 %d 1 1 100 1 0 3 0 0 0 0\n", adminState)
+
+		actual, _ := HaProxy{}.GetServerState("my-service-be", "my-service")
+
+		s.Equal("maint", actual.State)
+		s.Equal("stopped", actual.OperationalState)
+	}
+}
+
+func (s *RuntimeTestSuite) Test_GetServerState_ReturnsError_WhenServerIsNotFound() {
+	s.response = "1\n3 my-service-be 1 other-service 10.0.0.4 2 0 1 1 100 1 0 3 0 0 0 0\n"
+
+	_, err := HaProxy{}.GetServerState("my-service-be", "my-service")
+
+	s.Error(err)
+}
+
+func (s *RuntimeTestSuite) Test_GetServerState_ReturnsError_WhenBackendIsNotFound() {
+	s.response = "Can't find backend.\n"
+
+	_, err := HaProxy{}.GetServerState("my-service-be", "my-service")
+
+	s.Error(err)
+	s.Contains(err.Error(), "Can't find backend.")
+}
+
+// GetStats
+
+func (s *RuntimeTestSuite) Test_GetStats_SendsShowStat() {
+	s.response = "# pxname,svname,scur,type\nservices,FRONTEND,3,0\n"
+
+	actual, err := HaProxy{}.GetStats()
+
+	s.NoError(err)
+	s.Equal([]Stats{{Proxy: "services", Service: "FRONTEND", Type: "frontend", CurrentSessions: 3}}, actual)
+	s.Equal("show stat", <-s.commands)
+}
+
+// Util
+
+// serve mimics the HAProxy admin socket in the non-interactive mode.
+// It reads a single command, responds, and closes the connection.
+func (s *RuntimeTestSuite) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		cmd, _ := bufio.NewReader(conn).ReadString('\n')
+		s.commands <- cmd[:len(cmd)-1]
+		conn.Write([]byte(s.response + "\n"))
+		conn.Close()
+	}
+}
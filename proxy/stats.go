@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"../metrics"
+)
+
+// Stats are the statistics of a frontend, a backend, or a server as reported by the HAProxy "show stat" command
+type Stats struct {
+	Proxy           string
+	Service         string
+	Type            string
+	Status          string
+	CurrentSessions int64
+	TotalSessions   int64
+	CurrentQueue    int64
+	Responses2xx    int64
+	Responses4xx    int64
+	Responses5xx    int64
+}
+
+const (
+	StatsTypeFrontend = "frontend"
+	StatsTypeBackend  = "backend"
+	StatsTypeServer   = "server"
+)
+
+var statsTypes = map[string]string{
+	"0": StatsTypeFrontend,
+	"1": StatsTypeBackend,
+	"2": StatsTypeServer,
+}
+
+// GetStats returns the statistics of all frontends, backends, and servers
+func (m HaProxy) GetStats() ([]Stats, error) {
+	out, err := runRuntimeCmd("show stat")
+	if err != nil {
+		return nil, err
+	}
+	return parseStats(out)
+}
+
+func parseStats(out string) ([]Stats, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "# ")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse the HAProxy stats\n%s", err.Error())
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("The HAProxy stats are empty")
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{"pxname", "svname", "type"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("The HAProxy stats do not contain the %s column", name)
+		}
+	}
+	stats := []Stats{}
+	for _, record := range records[1:] {
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		getInt := func(name string) int64 {
+			value, _ := strconv.ParseInt(get(name), 10, 64)
+			return value
+		}
+		stats = append(stats, Stats{
+			Proxy:           get("pxname"),
+			Service:         get("svname"),
+			Type:            statsTypes[get("type")],
+			Status:          get("status"),
+			CurrentSessions: getInt("scur"),
+			TotalSessions:   getInt("stot"),
+			CurrentQueue:    getInt("qcur"),
+			Responses2xx:    getInt("hrsp_2xx"),
+			Responses4xx:    getInt("hrsp_4xx"),
+			Responses5xx:    getInt("hrsp_5xx"),
+		})
+	}
+	return stats, nil
+}
+
+// WriteStatsMetrics writes the statistics of frontends, backends, and servers in the Prometheus text format
+func WriteStatsMetrics(w io.Writer, stats []Stats) {
+	sessions := map[string][]metrics.Sample{}
+	sessionsTotal := map[string][]metrics.Sample{}
+	responses := map[string][]metrics.Sample{}
+	queue := []metrics.Sample{}
+	up := map[string][]metrics.Sample{}
+	for _, stat := range stats {
+		var labels map[string]string
+		switch stat.Type {
+		case StatsTypeFrontend:
+			labels = map[string]string{"frontend": stat.Proxy}
+		case StatsTypeBackend:
+			labels = map[string]string{"backend": stat.Proxy}
+			queue = append(queue, metrics.Sample{Labels: labels, Value: float64(stat.CurrentQueue)})
+		case StatsTypeServer:
+			labels = map[string]string{"backend": stat.Proxy, "server": stat.Service}
+		default:
+			continue
+		}
+		sessions[stat.Type] = append(sessions[stat.Type], metrics.Sample{Labels: labels, Value: float64(stat.CurrentSessions)})
+		sessionsTotal[stat.Type] = append(sessionsTotal[stat.Type], metrics.Sample{Labels: labels, Value: float64(stat.TotalSessions)})
+		if stat.Type != StatsTypeServer {
+			values := []int64{stat.Responses2xx, stat.Responses4xx, stat.Responses5xx}
+			for i, code := range []string{"2xx", "4xx", "5xx"} {
+				responseLabels := map[string]string{"code": code}
+				for k, v := range labels {
+					responseLabels[k] = v
+				}
+				responses[stat.Type] = append(responses[stat.Type], metrics.Sample{Labels: responseLabels, Value: float64(values[i])})
+			}
+		}
+		if stat.Type != StatsTypeFrontend {
+			up[stat.Type] = append(up[stat.Type], metrics.Sample{Labels: labels, Value: isUp(stat.Status)})
+		}
+	}
+	for _, statsType := range []string{StatsTypeFrontend, StatsTypeBackend, StatsTypeServer} {
+		metrics.Write(w, fmt.Sprintf("haproxy_%s_current_sessions", statsType), fmt.Sprintf("The number of current sessions of the %s.", statsType), "gauge", sessions[statsType])
+		metrics.Write(w, fmt.Sprintf("haproxy_%s_sessions_total", statsType), fmt.Sprintf("The total number of sessions of the %s.", statsType), "counter", sessionsTotal[statsType])
+	}
+	for _, statsType := range []string{StatsTypeFrontend, StatsTypeBackend} {
+		metrics.Write(w, fmt.Sprintf("haproxy_%s_http_responses_total", statsType), fmt.Sprintf("The number of HTTP responses of the %s by code.", statsType), "counter", responses[statsType])
+	}
+	metrics.Write(w, "haproxy_backend_current_queue", "The number of requests queued in the backend.", "gauge", queue)
+	metrics.Write(w, "haproxy_backend_up", "Whether the backend is up.", "gauge", up[StatsTypeBackend])
+	metrics.Write(w, "haproxy_server_up", "Whether the server is up.", "gauge", up[StatsTypeServer])
+}
+
+func isUp(status string) float64 {
+	if strings.HasPrefix(status, "UP") || status == "no check" {
+		return 1
+	}
+	return 0
+}
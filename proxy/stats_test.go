@@ -0,0 +1,80 @@
+// +build !integration
+
+package proxy
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type StatsTestSuite struct {
+	suite.Suite
+	StatsContent string
+}
+
+func (s *StatsTestSuite) SetupTest() {
+	s.StatsContent = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,
+services,FRONTEND,,,3,10,5000,120,0,0,0,0,0,,,,,OPEN,,,,,,,,,1,2,0,,,,0,1,0,5,,,,0,100,2,15,3,0,
+go-demo-be8080,go-demo,1,2,2,5,,60,0,0,,0,,0,0,0,0,UP,1,1,0,0,0,10,0,,1,3,1,,60,,2,0,,4,L4OK,,0,0,50,1,7,2,0,
+go-demo-be8080,other,0,0,0,1,,10,0,0,,0,,0,0,0,0,DOWN,1,1,0,1,1,10,5,,1,3,2,,10,,2,0,,1,L4CON,,0,0,5,0,1,0,0,
+go-demo-be8080,BACKEND,1,2,2,5,500,70,0,0,0,0,,0,0,0,0,UP,2,2,0,,0,10,0,,1,3,0,,70,,1,0,,4,,,,0,55,1,8,2,0,
+
+`
+}
+
+// parseStats
+
+func (s *StatsTestSuite) Test_ParseStats_ReturnsStats() {
+	expected := []Stats{
+		{Proxy: "services", Service: "FRONTEND", Type: "frontend", Status: "OPEN", CurrentSessions: 3, TotalSessions: 120, Responses2xx: 100, Responses4xx: 15, Responses5xx: 3},
+		{Proxy: "go-demo-be8080", Service: "go-demo", Type: "server", Status: "UP", CurrentSessions: 2, TotalSessions: 60, CurrentQueue: 1, Responses2xx: 50, Responses4xx: 7, Responses5xx: 2},
+		{Proxy: "go-demo-be8080", Service: "other", Type: "server", Status: "DOWN", TotalSessions: 10, Responses2xx: 5, Responses4xx: 1},
+		{Proxy: "go-demo-be8080", Service: "BACKEND", Type: "backend", Status: "UP", CurrentSessions: 2, TotalSessions: 70, CurrentQueue: 1, Responses2xx: 55, Responses4xx: 8, Responses5xx: 2},
+	}
+
+	actual, err := parseStats(s.StatsContent)
+
+	s.NoError(err)
+	s.Equal(expected, actual)
+}
+
+func (s *StatsTestSuite) Test_ParseStats_ReturnsError_WhenColumnsAreMissing() {
+	_, err := parseStats("Unknown command.\n")
+
+	s.Error(err)
+}
+
+// WriteStatsMetrics
+
+func (s *StatsTestSuite) Test_WriteStatsMetrics_WritesFrontendBackendAndServerMetrics() {
+	stats, _ := parseStats(s.StatsContent)
+	var buf bytes.Buffer
+
+	WriteStatsMetrics(&buf, stats)
+
+	actual := buf.String()
+	s.Contains(actual, `haproxy_frontend_current_sessions{frontend="services"} 3
+`)
+	s.Contains(actual, `haproxy_frontend_http_responses_total{code="2xx",frontend="services"} 100
+haproxy_frontend_http_responses_total{code="4xx",frontend="services"} 15
+haproxy_frontend_http_responses_total{code="5xx",frontend="services"} 3
+`)
+	s.Contains(actual, `haproxy_backend_sessions_total{backend="go-demo-be8080"} 70
+`)
+	s.Contains(actual, `haproxy_backend_http_responses_total{backend="go-demo-be8080",code="5xx"} 2
+`)
+	s.Contains(actual, `haproxy_backend_current_queue{backend="go-demo-be8080"} 1
+`)
+	s.Contains(actual, `haproxy_backend_up{backend="go-demo-be8080"} 1
+`)
+	s.Contains(actual, `haproxy_server_up{backend="go-demo-be8080",server="go-demo"} 1
+haproxy_server_up{backend="go-demo-be8080",server="other"} 0
+`)
+}
+
+// Suite
+
+func TestStatsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StatsTestSuite))
+}
@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var cmdStartHa = func(cmd *exec.Cmd) (pid int, err error) {
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	return cmd.Process.Pid, nil
+}
+var cmdWaitHa = func(cmd *exec.Cmd) error {
+	return cmd.Wait()
+}
+var signalHa = func(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// ProcessState describes the HAProxy master process supervised by the proxy
+type ProcessState struct {
+	Running         bool
+	Pid             int
+	Uptime          int64
+	Restarts        int
+	LastExitStatus  string
+	LastReload      time.Time
+	LastReloadError string
+}
+
+// supervisor runs HAProxy in the master-worker mode as a child process and restarts it with backoff when it exits.
+// An exit after backoffReset of uptime is not considered part of a crash loop and restarts with the minimum backoff.
+// HAProxy is not restarted once it is stopped.
+type supervisor struct {
+	mu              sync.Mutex
+	backoffMin      time.Duration
+	backoffMax      time.Duration
+	backoffReset    time.Duration
+	args            []string
+	pid             int
+	running         bool
+	startedAt       time.Time
+	restarts        int
+	backoff         time.Duration
+	lastExitStatus  string
+	lastReload      time.Time
+	lastReloadError string
+	stopping        bool
+	exited          chan struct{}
+}
+
+var haSupervisor = &supervisor{
+	backoffMin:   time.Second,
+	backoffMax:   30 * time.Second,
+	backoffReset: time.Minute,
+}
+
+func (s *supervisor) start(args []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return fmt.Errorf("HAProxy is already running with the pid %d", s.pid)
+	}
+	if s.stopping {
+		return fmt.Errorf("HAProxy is stopping")
+	}
+	cmd := exec.Command("haproxy", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	pid, err := cmdStartHa(cmd)
+	if err != nil {
+		return err
+	}
+	s.args = args
+	s.pid = pid
+	s.running = true
+	s.startedAt = time.Now()
+	s.exited = make(chan struct{})
+	go s.wait(cmd, cmdWaitHa)
+	return nil
+}
+
+func (s *supervisor) wait(cmd *exec.Cmd, waitHa func(cmd *exec.Cmd) error) {
+	err := waitHa(cmd)
+	exitStatus := "exit status 0"
+	if err != nil {
+		exitStatus = err.Error()
+	}
+	s.mu.Lock()
+	s.running = false
+	s.pid = 0
+	s.lastExitStatus = exitStatus
+	if time.Now().Sub(s.startedAt) > s.backoffReset || s.backoff == 0 {
+		s.backoff = s.backoffMin
+	}
+	stopping := s.stopping
+	if s.exited != nil {
+		close(s.exited)
+	}
+	s.mu.Unlock()
+	logPrintf("HAProxy exited with %s", exitStatus)
+	if !stopping {
+		s.restart()
+	}
+}
+
+func (s *supervisor) restart() {
+	for {
+		s.mu.Lock()
+		backoff := s.backoff
+		s.backoff *= 2
+		if s.backoff > s.backoffMax {
+			s.backoff = s.backoffMax
+		}
+		args := s.args
+		s.mu.Unlock()
+		logPrintf("Restarting HAProxy in %s", backoff)
+		time.Sleep(backoff)
+		s.mu.Lock()
+		stopping := s.stopping
+		s.mu.Unlock()
+		if stopping {
+			return
+		}
+		err := s.start(args)
+		s.mu.Lock()
+		s.restarts++
+		s.mu.Unlock()
+		if err == nil {
+			return
+		}
+		logPrintf("Could not restart HAProxy\n%s", err.Error())
+	}
+}
+
+// reload asks the HAProxy master to reload the workers with the current configuration
+func (s *supervisor) reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.running {
+		err = signalHa(s.pid, syscall.SIGUSR2)
+	} else {
+		err = fmt.Errorf("HAProxy is not running")
+	}
+	s.lastReload = time.Now()
+	s.lastReloadError = ""
+	if err != nil {
+		s.lastReloadError = err.Error()
+	}
+	return err
+}
+
+// stop asks the HAProxy master to soft-stop. The workers stop listening and exit once their connections are closed.
+// If HAProxy does not exit within the grace period, it is terminated together with the remaining connections.
+func (s *supervisor) stop(grace time.Duration) error {
+	s.mu.Lock()
+	s.stopping = true
+	running, pid, exited := s.running, s.pid, s.exited
+	s.mu.Unlock()
+	if !running {
+		return nil
+	}
+	logPrintf("Stopping HAProxy. Open connections have %s to finish", grace)
+	if err := signalHa(pid, syscall.SIGUSR1); err != nil {
+		return err
+	}
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(grace):
+	}
+	logPrintf("HAProxy did not stop within %s. Terminating it", grace)
+	return signalHa(pid, syscall.SIGTERM)
+}
+
+func (s *supervisor) getState() ProcessState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := ProcessState{
+		Running:         s.running,
+		Pid:             s.pid,
+		Restarts:        s.restarts,
+		LastExitStatus:  s.lastExitStatus,
+		LastReload:      s.lastReload,
+		LastReloadError: s.lastReloadError,
+	}
+	if s.running {
+		state.Uptime = int64(time.Now().Sub(s.startedAt).Seconds())
+	}
+	return state
+}
+
+// Stop soft-stops the supervised HAProxy and waits up to the grace period for its connections to drain.
+// HAProxy is not restarted afterwards.
+func Stop(grace time.Duration) error {
+	return haSupervisor.stop(grace)
+}
@@ -0,0 +1,219 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type SupervisorTestSuite struct {
+	suite.Suite
+}
+
+// Suite
+
+func TestSupervisorUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(SupervisorTestSuite))
+}
+
+// start
+
+func (s *SupervisorTestSuite) Test_Start_ReturnsError_WhenAlreadyRunning() {
+	sv := &supervisor{running: true, pid: 123}
+
+	err := sv.start([]string{})
+
+	s.Error(err)
+}
+
+// wait
+
+func (s *SupervisorTestSuite) Test_Wait_RestartsHaProxy_WhenItExits() {
+	defer s.restoreCmds()()
+	exits := make(chan error)
+	starts := make(chan []string, 10)
+	cmdStartHa = func(cmd *exec.Cmd) (int, error) {
+		starts <- cmd.Args
+		return 123, nil
+	}
+	cmdWaitHa = func(cmd *exec.Cmd) error {
+		return <-exits
+	}
+	sv := s.newSupervisor()
+	sv.start([]string{"-W"})
+	<-starts
+
+	exits <- fmt.Errorf("exit status 1")
+
+	s.Equal([]string{"haproxy", "-W"}, <-starts)
+	s.waitForRestarts(sv, 1)
+	state := sv.getState()
+	s.True(state.Running)
+	s.Equal(1, state.Restarts)
+	s.Equal("exit status 1", state.LastExitStatus)
+}
+
+func (s *SupervisorTestSuite) Test_Restart_RetriesWithBackoff_WhenStartFails() {
+	defer s.restoreCmds()()
+	attempts := []time.Time{}
+	started := make(chan bool)
+	cmdStartHa = func(cmd *exec.Cmd) (int, error) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) < 4 {
+			return 0, fmt.Errorf("This is an error")
+		}
+		started <- true
+		return 123, nil
+	}
+	cmdWaitHa = func(cmd *exec.Cmd) error {
+		select {}
+	}
+	sv := s.newSupervisor()
+	sv.backoff = sv.backoffMin
+
+	go sv.restart()
+	<-started
+
+	s.waitForRestarts(sv, 4)
+	s.Equal(4, len(attempts))
+	s.True(attempts[2].Sub(attempts[1]) >= 2*time.Millisecond)
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	s.Equal(sv.backoffMax, sv.backoff)
+}
+
+// reload
+
+func (s *SupervisorTestSuite) Test_Reload_RecordsResult() {
+	signalHaOrig := signalHa
+	defer func() { signalHa = signalHaOrig }()
+	signalHa = func(pid int, sig syscall.Signal) error {
+		return fmt.Errorf("This is an error")
+	}
+	sv := &supervisor{running: true, pid: 123}
+
+	sv.reload()
+
+	state := sv.getState()
+	s.False(state.LastReload.IsZero())
+	s.Equal("This is an error", state.LastReloadError)
+}
+
+func (s *SupervisorTestSuite) Test_Reload_ReturnsError_WhenNotRunning() {
+	sv := &supervisor{}
+
+	err := sv.reload()
+
+	s.Error(err)
+	s.NotEmpty(sv.getState().LastReloadError)
+}
+
+// stop
+
+func (s *SupervisorTestSuite) Test_Stop_SoftStopsHaProxyAndDoesNotRestartIt() {
+	defer s.restoreCmds()()
+	defer s.restoreSignal()()
+	exits := make(chan error)
+	starts := make(chan bool, 10)
+	cmdStartHa = func(cmd *exec.Cmd) (int, error) {
+		starts <- true
+		return 123, nil
+	}
+	cmdWaitHa = func(cmd *exec.Cmd) error {
+		return <-exits
+	}
+	signals := []syscall.Signal{}
+	signalHa = func(pid int, sig syscall.Signal) error {
+		signals = append(signals, sig)
+		if sig == syscall.SIGUSR1 {
+			go func() { exits <- nil }()
+		}
+		return nil
+	}
+	sv := s.newSupervisor()
+	sv.start([]string{"-W"})
+	<-starts
+
+	err := sv.stop(time.Second)
+
+	s.NoError(err)
+	s.Equal([]syscall.Signal{syscall.SIGUSR1}, signals)
+	time.Sleep(10 * time.Millisecond)
+	s.Len(starts, 0)
+	s.False(sv.getState().Running)
+}
+
+func (s *SupervisorTestSuite) Test_Stop_TerminatesHaProxy_WhenGracePeriodExpires() {
+	defer s.restoreSignal()()
+	signals := []syscall.Signal{}
+	signalHa = func(pid int, sig syscall.Signal) error {
+		signals = append(signals, sig)
+		return nil
+	}
+	sv := &supervisor{running: true, pid: 123, exited: make(chan struct{})}
+
+	err := sv.stop(time.Millisecond)
+
+	s.NoError(err)
+	s.Equal([]syscall.Signal{syscall.SIGUSR1, syscall.SIGTERM}, signals)
+}
+
+func (s *SupervisorTestSuite) Test_Stop_ReturnsNil_WhenNotRunning() {
+	defer s.restoreSignal()()
+	signalHa = func(pid int, sig syscall.Signal) error {
+		return fmt.Errorf("This is an error")
+	}
+	sv := &supervisor{}
+
+	err := sv.stop(time.Second)
+
+	s.NoError(err)
+	s.Error(sv.start([]string{}))
+}
+
+// getState
+
+func (s *SupervisorTestSuite) Test_GetState_ReturnsUptime() {
+	sv := &supervisor{running: true, pid: 123, startedAt: time.Now().Add(-time.Minute)}
+
+	state := sv.getState()
+
+	s.Equal(123, state.Pid)
+	s.True(state.Uptime >= 60)
+}
+
+// Util
+
+func (s *SupervisorTestSuite) newSupervisor() *supervisor {
+	return &supervisor{
+		backoffMin:   time.Millisecond,
+		backoffMax:   4 * time.Millisecond,
+		backoffReset: time.Minute,
+	}
+}
+
+func (s *SupervisorTestSuite) waitForRestarts(sv *supervisor, restarts int) {
+	for i := 0; i < 100 && sv.getState().Restarts < restarts; i++ {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *SupervisorTestSuite) restoreSignal() func() {
+	signalHaOrig := signalHa
+	return func() { signalHa = signalHaOrig }
+}
+
+func (s *SupervisorTestSuite) restoreCmds() func() {
+	cmdStartHaOrig := cmdStartHa
+	cmdWaitHaOrig := cmdWaitHa
+	return func() {
+		cmdStartHa = cmdStartHaOrig
+		cmdWaitHa = cmdWaitHaOrig
+	}
+}
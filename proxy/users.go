@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+const sha512CryptRounds = 5000
+
+// usersSecretPath is the Docker secret with the users of the defaultUsers list. It is used together with the USERS env var.
+var usersSecretPath = "/run/secrets/dfp_users"
+var userPasswordRegexp = regexp.MustCompile(`(?m)^([-+ ]?\s*user\s+\S+\s+(?:insecure-)?password\s+)\S+`)
+
+// hashedPasswords caches the hashes of the users so that the configuration does not change with each render.
+// The entries are stored by the owner of the users and the username. Only a keyed digest of the password is kept
+// to detect that the password changed.
+var hashedPasswordsMu = &sync.Mutex{}
+var hashedPasswords = map[string]cachedHash{}
+var passwordDigestKey = newPasswordDigestKey()
+
+type cachedHash struct {
+	digest string
+	hash   string
+}
+
+// HashPassword returns the SHA-512 crypt hash of the password with a random salt
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("Could not generate the salt\n%s", err.Error())
+	}
+	for i := range salt {
+		salt[i] = cryptAlphabet[int(salt[i])%len(cryptAlphabet)]
+	}
+	return sha512Crypt(password, string(salt)), nil
+}
+
+// IsHashPasswords returns whether plaintext passwords should be hashed before they are written to the configuration
+func IsHashPasswords() bool {
+	return strings.EqualFold(os.Getenv("HASH_PASSWORDS"), "true")
+}
+
+// HasGlobalUsers returns whether the defaultUsers list is defined through the USERS env var or the dfp_users secret
+func HasGlobalUsers() bool {
+	return len(getGlobalUsers()) > 0
+}
+
+// RedactConfig hides the passwords of the users in the configuration or in a diff of configurations
+func RedactConfig(config string) string {
+	return userPasswordRegexp.ReplaceAllString(config, "${1}*****")
+}
+
+// ParseUsers parses comma or new line separated user:password pairs. The names of the entries without a password are returned as invalid.
+func ParseUsers(value string) (users [][2]string, invalid []string) {
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		userPass := strings.SplitN(entry, ":", 2)
+		if len(userPass) != 2 || len(userPass[0]) == 0 || len(userPass[1]) == 0 {
+			invalid = append(invalid, userPass[0])
+			continue
+		}
+		users = append(users, [2]string{userPass[0], userPass[1]})
+	}
+	return users, invalid
+}
+
+func getGlobalUsers() [][2]string {
+	value := os.Getenv("USERS")
+	if content, err := readSecretFile(usersSecretPath); err == nil {
+		value += "\n" + string(content)
+	}
+	users, invalid := ParseUsers(value)
+	for _, user := range invalid {
+		logPrintf("The user %s does not have the format user:password and is ignored", user)
+	}
+	return users
+}
+
+func getUserList() string {
+	users := getGlobalUsers()
+	if len(users) == 0 {
+		return ""
+	}
+	passEncrypted := strings.EqualFold(os.Getenv("USERS_PASS_ENCRYPTED"), "true")
+	userList := "\nuserlist defaultUsers\n"
+	usernames := []string{}
+	for _, user := range users {
+		usernames = append(usernames, user[0])
+		keyword, password := "insecure-password", user[1]
+		if passEncrypted {
+			keyword = "password"
+		} else if IsHashPasswords() {
+			keyword, password = "password", getCachedHash(user[0], user[1])
+		}
+		userList = fmt.Sprintf("%s    user %s %s %s\n", userList, user[0], keyword, password)
+	}
+	RetainCachedHashes("", usernames)
+	return userList
+}
+
+func getCachedHash(username, password string) string {
+	hash, err := GetCachedHash("", username, password)
+	if err != nil {
+		logPrintf(err.Error())
+		return ""
+	}
+	return hash
+}
+
+// GetCachedHash returns the hash of the password of the user of the owner (a service or, when empty, the global users).
+// The hash is created once and reused until the password of the user changes.
+func GetCachedHash(owner, username, password string) (string, error) {
+	hashedPasswordsMu.Lock()
+	defer hashedPasswordsMu.Unlock()
+	key := getCachedHashKey(owner, username)
+	digest := getPasswordDigest(username, password)
+	if cached, ok := hashedPasswords[key]; ok && hmac.Equal([]byte(cached.digest), []byte(digest)) {
+		return cached.hash, nil
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	hashedPasswords[key] = cachedHash{digest: digest, hash: hash}
+	return hash, nil
+}
+
+// RetainCachedHashes removes the cached hashes of the users of the owner that are not in the usernames
+func RetainCachedHashes(owner string, usernames []string) {
+	hashedPasswordsMu.Lock()
+	defer hashedPasswordsMu.Unlock()
+	retained := map[string]bool{}
+	for _, username := range usernames {
+		retained[getCachedHashKey(owner, username)] = true
+	}
+	prefix := owner + "/"
+	for key := range hashedPasswords {
+		if strings.HasPrefix(key, prefix) && !retained[key] {
+			delete(hashedPasswords, key)
+		}
+	}
+}
+
+// getCachedHashKey returns the key of the hash. Owners are service names, so they cannot contain a slash.
+func getCachedHashKey(owner, username string) string {
+	return owner + "/" + username
+}
+
+func getPasswordDigest(username, password string) string {
+	mac := hmac.New(sha256.New, passwordDigestKey)
+	mac.Write([]byte(username + ":" + password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newPasswordDigestKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		logPrintf("Could not generate the key of the password digests\n%s", err.Error())
+	}
+	return key
+}
+
+// sha512Crypt implements the SHA-512 based crypt(3) scheme ($6$) with the default number of rounds
+func sha512Crypt(password, salt string) string {
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	p, s := []byte(password), []byte(salt)
+
+	alternate := sha512.New()
+	alternate.Write(p)
+	alternate.Write(s)
+	alternate.Write(p)
+	altSum := alternate.Sum(nil)
+
+	digest := sha512.New()
+	digest.Write(p)
+	digest.Write(s)
+	i := len(p)
+	for ; i > 64; i -= 64 {
+		digest.Write(altSum)
+	}
+	digest.Write(altSum[:i])
+	for i = len(p); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			digest.Write(altSum)
+		} else {
+			digest.Write(p)
+		}
+	}
+	sum := digest.Sum(nil)
+
+	dp := sha512.New()
+	for i = 0; i < len(p); i++ {
+		dp.Write(p)
+	}
+	pSeq := repeatBytes(dp.Sum(nil), len(p))
+
+	ds := sha512.New()
+	for i = 0; i < 16+int(sum[0]); i++ {
+		ds.Write(s)
+	}
+	sSeq := repeatBytes(ds.Sum(nil), len(s))
+
+	for i = 0; i < sha512CryptRounds; i++ {
+		round := sha512.New()
+		if i&1 != 0 {
+			round.Write(pSeq)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(sSeq)
+		}
+		if i%7 != 0 {
+			round.Write(pSeq)
+		}
+		if i&1 != 0 {
+			round.Write(sum)
+		} else {
+			round.Write(pSeq)
+		}
+		sum = round.Sum(nil)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("$6$" + salt + "$")
+	order := [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+		{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+		{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+	}
+	for _, o := range order {
+		encodeCrypt64(&buf, uint(sum[o[0]])<<16|uint(sum[o[1]])<<8|uint(sum[o[2]]), 4)
+	}
+	encodeCrypt64(&buf, uint(sum[63]), 2)
+	return buf.String()
+}
+
+func repeatBytes(sum []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	for len(out)+len(sum) <= length {
+		out = append(out, sum...)
+	}
+	return append(out, sum[:length-len(out)]...)
+}
+
+func encodeCrypt64(buf *bytes.Buffer, value uint, n int) {
+	for ; n > 0; n-- {
+		buf.WriteByte(cryptAlphabet[value&0x3f])
+		value >>= 6
+	}
+}
@@ -0,0 +1,193 @@
+// +build !integration
+
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UsersTestSuite struct {
+	suite.Suite
+}
+
+func (s *UsersTestSuite) SetupTest() {
+	readSecretFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	hashedPasswords = map[string]cachedHash{}
+}
+
+// sha512Crypt
+
+func (s UsersTestSuite) Test_Sha512Crypt_ReturnsCryptHash() {
+	actual := sha512Crypt("Hello world!", "saltstring")
+
+	s.Equal("$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1", actual)
+}
+
+func (s UsersTestSuite) Test_Sha512Crypt_TruncatesSaltTo16Characters() {
+	actual := sha512Crypt("we have a short salt string but not a short password", "toolongsaltstringislong")
+
+	s.True(strings.HasPrefix(actual, "$6$toolongsaltstrin$"))
+}
+
+// HashPassword
+
+func (s UsersTestSuite) Test_HashPassword_ReturnsHashWithRandomSalt() {
+	hash1, err := HashPassword("my-pass")
+	hash2, _ := HashPassword("my-pass")
+
+	s.NoError(err)
+	s.True(strings.HasPrefix(hash1, "$6$"))
+	s.NotEqual(hash1, hash2)
+	salt := strings.Split(hash1, "$")[2]
+	s.Equal(hash1, sha512Crypt("my-pass", salt))
+}
+
+// RedactConfig
+
+func (s UsersTestSuite) Test_RedactConfig_HidesPasswords() {
+	config := `userlist defaultUsers
+    user my-user-1 insecure-password my-pass-1
+    user my-user-2 password $6$salt$hash
+
+backend my-service-be
+    server my-service my-service:1234`
+	expected := `userlist defaultUsers
+    user my-user-1 insecure-password *****
+    user my-user-2 password *****
+
+backend my-service-be
+    server my-service my-service:1234`
+
+	s.Equal(expected, RedactConfig(config))
+}
+
+func (s UsersTestSuite) Test_RedactConfig_HidesPasswordsInDiff() {
+	diff := "@@ -1,2 +1,2 @@\n userlist myServiceUsers\n-    user my-user insecure-password old-pass\n+    user my-user insecure-password new-pass\n"
+
+	actual := RedactConfig(diff)
+
+	s.Equal("@@ -1,2 +1,2 @@\n userlist myServiceUsers\n-    user my-user insecure-password *****\n+    user my-user insecure-password *****\n", actual)
+}
+
+// ParseUsers
+
+func (s UsersTestSuite) Test_ParseUsers_ReturnsUsersAndInvalidEntries() {
+	users, invalid := ParseUsers("user-1:pass-1,user-2\nuser-3:pass:3\r\n\nuser-4:")
+
+	s.Equal([][2]string{{"user-1", "pass-1"}, {"user-3", "pass:3"}}, users)
+	s.Equal([]string{"user-2", "user-4"}, invalid)
+}
+
+// getUserList
+
+func (s UsersTestSuite) Test_GetUserList_ReturnsEmptyString_WhenThereAreNoUsers() {
+	defer s.setEnv("USERS", "")()
+
+	s.Equal("", getUserList())
+	s.False(HasGlobalUsers())
+}
+
+func (s UsersTestSuite) Test_GetUserList_AddsUsersFromSecret() {
+	defer s.setEnv("USERS", "user-1:pass-1")()
+	readSecretFile = func(filename string) ([]byte, error) {
+		s.Equal("/run/secrets/dfp_users", filename)
+		return []byte("user-2:pass-2\n"), nil
+	}
+
+	actual := getUserList()
+
+	s.Equal("\nuserlist defaultUsers\n    user user-1 insecure-password pass-1\n    user user-2 insecure-password pass-2\n", actual)
+	s.True(HasGlobalUsers())
+}
+
+func (s UsersTestSuite) Test_GetUserList_UsesPasswordKeyword_WhenPasswordsAreEncrypted() {
+	defer s.setEnv("USERS", "user-1:$6$salt$hash")()
+	defer s.setEnv("USERS_PASS_ENCRYPTED", "true")()
+
+	actual := getUserList()
+
+	s.Equal("\nuserlist defaultUsers\n    user user-1 password $6$salt$hash\n", actual)
+}
+
+func (s UsersTestSuite) Test_GetUserList_HashesPasswordsOnce_WhenHashPasswordsIsTrue() {
+	defer s.setEnv("USERS", "user-1:pass-1")()
+	defer s.setEnv("HASH_PASSWORDS", "true")()
+
+	actual := getUserList()
+
+	s.Contains(actual, "    user user-1 password $6$")
+	s.NotContains(actual, "pass-1")
+	s.Equal(actual, getUserList())
+}
+
+// GetCachedHash
+
+func (s UsersTestSuite) Test_GetCachedHash_ReturnsSameHash_WhenPasswordDoesNotChange() {
+	hash, err := GetCachedHash("my-service", "user-1", "pass-1")
+
+	s.NoError(err)
+	s.True(strings.HasPrefix(hash, "$6$"))
+	s.Equal(hash, getCachedHashOrEmpty("my-service", "user-1", "pass-1"))
+}
+
+func (s UsersTestSuite) Test_GetCachedHash_ReplacesHash_WhenPasswordChanges() {
+	hash, _ := GetCachedHash("my-service", "user-1", "pass-1")
+
+	actual, _ := GetCachedHash("my-service", "user-1", "pass-2")
+
+	s.NotEqual(hash, actual)
+	s.Len(hashedPasswords, 1)
+}
+
+func (s UsersTestSuite) Test_GetCachedHash_DoesNotStorePlaintextPasswords() {
+	GetCachedHash("my-service", "user-1", "pass-1")
+
+	for key, cached := range hashedPasswords {
+		s.NotContains(key, "pass-1")
+		s.NotContains(cached.digest, "pass-1")
+	}
+}
+
+// RetainCachedHashes
+
+func (s UsersTestSuite) Test_RetainCachedHashes_RemovesHashesOfUsersThatAreNotConfigured() {
+	GetCachedHash("my-service", "user-1", "pass-1")
+	GetCachedHash("my-service", "user-2", "pass-2")
+	GetCachedHash("other-service", "user-2", "pass-2")
+
+	RetainCachedHashes("my-service", []string{"user-1"})
+
+	s.Len(hashedPasswords, 2)
+	s.Contains(hashedPasswords, getCachedHashKey("my-service", "user-1"))
+	s.Contains(hashedPasswords, getCachedHashKey("other-service", "user-2"))
+}
+
+// Util
+
+func getCachedHashOrEmpty(owner, username, password string) string {
+	hash, _ := GetCachedHash(owner, username, password)
+	return hash
+}
+
+
+func (s UsersTestSuite) setEnv(key, value string) func() {
+	orig := os.Getenv(key)
+	os.Setenv(key, value)
+	return func() { os.Setenv(key, orig) }
+}
+
+// Suite
+
+func TestUsersUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	readSecretFileOrig := readSecretFile
+	defer func() { readSecretFile = readSecretFileOrig }()
+	suite.Run(t, new(UsersTestSuite))
+}
@@ -3,6 +3,7 @@ package proxy
 import (
 	"io/ioutil"
 	"log"
+	"os"
 	"os/exec"
 )
 
@@ -11,7 +12,9 @@ var cmdRunHa = func(cmd *exec.Cmd) error {
 }
 var readConfigsFile = ioutil.ReadFile
 var writeFile = ioutil.WriteFile
+var renameFile = os.Rename
+var removeFile = os.Remove
 var ReadFile = ioutil.ReadFile
 var logPrintf = log.Printf
-var readPidFile = ioutil.ReadFile
 var readConfigsDir = ioutil.ReadDir
+var readSecretFile = ioutil.ReadFile
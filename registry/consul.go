@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -41,6 +42,29 @@ func (m Consul) PutService(addresses []string, instanceName string, r Registry)
 		data{CONSUL_TEMPLATE_FE_PATH_KEY, r.ConsulTemplateFePath},
 		data{CONSUL_TEMPLATE_BE_PATH_KEY, r.ConsulTemplateBePath},
 		data{PORT, r.Port},
+		data{HEALTH_CHECK_PATH_KEY, r.HealthCheckPath},
+		data{HEALTH_CHECK_METHOD_KEY, r.HealthCheckMethod},
+		data{HEALTH_CHECK_STATUS_KEY, strconv.Itoa(r.HealthCheckStatus)},
+		data{HEALTH_CHECK_INTERVAL_KEY, strconv.Itoa(r.HealthCheckInterval)},
+		data{HEALTH_CHECK_RISE_KEY, strconv.Itoa(r.HealthCheckRise)},
+		data{HEALTH_CHECK_FALL_KEY, strconv.Itoa(r.HealthCheckFall)},
+		data{HEALTH_CHECK_PORT_KEY, strconv.Itoa(r.HealthCheckPort)},
+		data{TIMEOUT_SERVER_KEY, strconv.Itoa(r.TimeoutServer)},
+		data{TIMEOUT_CONNECT_KEY, strconv.Itoa(r.TimeoutConnect)},
+		data{TIMEOUT_QUEUE_KEY, strconv.Itoa(r.TimeoutQueue)},
+		data{TIMEOUT_TUNNEL_KEY, strconv.Itoa(r.TimeoutTunnel)},
+		data{MAX_CONN_KEY, strconv.Itoa(r.MaxConn)},
+		data{FULL_CONN_KEY, strconv.Itoa(r.FullConn)},
+		data{BALANCE_KEY, r.Balance},
+		data{STICKY_SESSIONS_KEY, fmt.Sprintf("%t", r.StickySessions)},
+		data{TASK_ROUTING_KEY, fmt.Sprintf("%t", r.TaskRouting)},
+		data{MAX_TASKS_KEY, strconv.Itoa(r.MaxTasks)},
+		data{COLOR_WEIGHTS_KEY, r.ColorWeights},
+		data{HEADER_MATCHES_KEY, r.HeaderMatches},
+		data{COOKIE_MATCHES_KEY, r.CookieMatches},
+		data{QUERY_MATCHES_KEY, r.QueryMatches},
+		data{METHODS_KEY, r.Methods},
+		data{PRIORITY_KEY, strconv.Itoa(r.Priority)},
 	}
 	for _, e := range d {
 		go m.SendPutRequest(addresses, r.ServiceName, e.key, e.value, instanceName, consulChannel)
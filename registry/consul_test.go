@@ -87,6 +87,29 @@ func (s *ConsulTestSuite) Test_PutService_PutsDataToConsul() {
 		data{"consultemplatefepath", s.registry.ConsulTemplateFePath},
 		data{"consultemplatebepath", s.registry.ConsulTemplateBePath},
 		data{"port", string(s.registry.Port)},
+		data{"healthcheckpath", s.registry.HealthCheckPath},
+		data{"healthcheckmethod", s.registry.HealthCheckMethod},
+		data{"healthcheckstatus", fmt.Sprintf("%d", s.registry.HealthCheckStatus)},
+		data{"healthcheckinterval", fmt.Sprintf("%d", s.registry.HealthCheckInterval)},
+		data{"healthcheckrise", fmt.Sprintf("%d", s.registry.HealthCheckRise)},
+		data{"healthcheckfall", fmt.Sprintf("%d", s.registry.HealthCheckFall)},
+		data{"healthcheckport", fmt.Sprintf("%d", s.registry.HealthCheckPort)},
+		data{"timeoutserver", fmt.Sprintf("%d", s.registry.TimeoutServer)},
+		data{"timeoutconnect", fmt.Sprintf("%d", s.registry.TimeoutConnect)},
+		data{"timeoutqueue", fmt.Sprintf("%d", s.registry.TimeoutQueue)},
+		data{"timeouttunnel", fmt.Sprintf("%d", s.registry.TimeoutTunnel)},
+		data{"maxconn", fmt.Sprintf("%d", s.registry.MaxConn)},
+		data{"fullconn", fmt.Sprintf("%d", s.registry.FullConn)},
+		data{"balance", s.registry.Balance},
+		data{"stickysessions", fmt.Sprintf("%t", s.registry.StickySessions)},
+		data{"taskrouting", fmt.Sprintf("%t", s.registry.TaskRouting)},
+		data{"maxtasks", fmt.Sprintf("%d", s.registry.MaxTasks)},
+		data{"colorweights", s.registry.ColorWeights},
+		data{"headermatches", s.registry.HeaderMatches},
+		data{"cookiematches", s.registry.CookieMatches},
+		data{"querymatches", s.registry.QueryMatches},
+		data{"methods", s.registry.Methods},
+		data{"priority", fmt.Sprintf("%d", s.registry.Priority)},
 	}
 	for _, e := range d {
 		s.Contains(actualUrl, fmt.Sprintf("/v1/kv/%s/%s/%s", instanceName, s.registry.ServiceName, e.key))
@@ -478,6 +501,29 @@ func TestConsulUnitTestSuite(t *testing.T) {
 		SkipCheck:            true,
 		ConsulTemplateFePath: "ConsulTemplateFePath",
 		ConsulTemplateBePath: "ConsulTemplateBePath",
+		HealthCheckPath:      "/health",
+		HealthCheckMethod:    "HEAD",
+		HealthCheckStatus:    204,
+		HealthCheckInterval:  2000,
+		HealthCheckRise:      2,
+		HealthCheckFall:      3,
+		HealthCheckPort:      8081,
+		TimeoutServer:        300,
+		TimeoutConnect:       2,
+		TimeoutQueue:         10,
+		TimeoutTunnel:        3600,
+		MaxConn:              50,
+		FullConn:             500,
+		Balance:              "leastconn",
+		StickySessions:       true,
+		TaskRouting:          true,
+		MaxTasks:             20,
+		ColorWeights:         "blue:90,green:10",
+		HeaderMatches:        `[{"Name":"X-Canary","Value":"true","Regexp":false}]`,
+		CookieMatches:        `[{"Name":"tester","Value":"yes","Regexp":false}]`,
+		QueryMatches:         `[{"Name":"beta","Value":"1","Regexp":false}]`,
+		Methods:              "GET,POST",
+		Priority:             10,
 	}
 	suite.Run(t, s)
 }
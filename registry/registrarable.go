@@ -11,6 +11,29 @@ const (
 	CONSUL_TEMPLATE_FE_PATH_KEY = "consultemplatefepath"
 	CONSUL_TEMPLATE_BE_PATH_KEY = "consultemplatebepath"
 	PORT                        = "port"
+	HEALTH_CHECK_PATH_KEY       = "healthcheckpath"
+	HEALTH_CHECK_METHOD_KEY     = "healthcheckmethod"
+	HEALTH_CHECK_STATUS_KEY     = "healthcheckstatus"
+	HEALTH_CHECK_INTERVAL_KEY   = "healthcheckinterval"
+	HEALTH_CHECK_RISE_KEY       = "healthcheckrise"
+	HEALTH_CHECK_FALL_KEY       = "healthcheckfall"
+	HEALTH_CHECK_PORT_KEY       = "healthcheckport"
+	TIMEOUT_SERVER_KEY          = "timeoutserver"
+	TIMEOUT_CONNECT_KEY         = "timeoutconnect"
+	TIMEOUT_QUEUE_KEY           = "timeoutqueue"
+	TIMEOUT_TUNNEL_KEY          = "timeouttunnel"
+	MAX_CONN_KEY                = "maxconn"
+	FULL_CONN_KEY               = "fullconn"
+	BALANCE_KEY                 = "balance"
+	STICKY_SESSIONS_KEY         = "stickysessions"
+	TASK_ROUTING_KEY            = "taskrouting"
+	MAX_TASKS_KEY               = "maxtasks"
+	COLOR_WEIGHTS_KEY           = "colorweights"
+	HEADER_MATCHES_KEY          = "headermatches"
+	COOKIE_MATCHES_KEY          = "cookiematches"
+	QUERY_MATCHES_KEY           = "querymatches"
+	METHODS_KEY                 = "methods"
+	PRIORITY_KEY                = "priority"
 )
 
 type Registry struct {
@@ -25,6 +48,29 @@ type Registry struct {
 	SkipCheck            bool
 	ConsulTemplateFePath string
 	ConsulTemplateBePath string
+	HealthCheckPath      string
+	HealthCheckMethod    string
+	HealthCheckStatus    int
+	HealthCheckInterval  int
+	HealthCheckRise      int
+	HealthCheckFall      int
+	HealthCheckPort      int
+	TimeoutServer        int
+	TimeoutConnect       int
+	TimeoutQueue         int
+	TimeoutTunnel        int
+	MaxConn              int
+	FullConn             int
+	Balance              string
+	StickySessions       bool
+	TaskRouting          bool
+	MaxTasks             int
+	ColorWeights         string
+	HeaderMatches        string
+	CookieMatches        string
+	QueryMatches         string
+	Methods              string
+	Priority             int
 }
 
 type Registrarable interface {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"./actions"
 	haproxy "./proxy"
 	"fmt"
 	"strings"
@@ -8,6 +9,8 @@ import (
 
 type Removable interface {
 	Executable
+	GetDiff() (string, error)
+	GetConfigChange() actions.ConfigChange
 }
 
 type Remove struct {
@@ -18,6 +21,8 @@ type Remove struct {
 	TemplatesPath   string `short:"t" long:"templates-path" default:"/cfg/tmpl" description:"The path to the templates directory"`
 	Mode            string
 	AclName         string
+	DryRun          bool `long:"dry-run" description:"If set, the service is not removed. Instead, the difference between the current and the proposed configuration is output."`
+	configChange    actions.ConfigChange
 }
 
 var remove Remove
@@ -37,38 +42,74 @@ var NewRemove = func(serviceName, aclName, configsPath, templatesPath string, co
 
 // TODO: Remove args
 func (m *Remove) Execute(args []string) error {
+	if m.DryRun {
+		diff, err := m.GetDiff()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, diff)
+		return nil
+	}
 	logPrintf("Removing %s configuration", m.ServiceName)
-	if err := m.removeFiles(m.TemplatesPath, m.ServiceName, m.AclName, m.ConsulAddresses, m.InstanceName, m.Mode); err != nil {
+	configChange, err := actions.ChangeServiceConfigs(m.TemplatesPath, m.getAclName(), m.ServiceName, actions.HistoryActionRemove, func() error {
+		return m.removeFiles(m.TemplatesPath, m.ServiceName, m.getAclName())
+	})
+	if err != nil {
 		logPrintf(err.Error())
 		return err
 	}
-	if err := haproxy.Instance.CreateConfigFromTemplates(); err != nil {
+	m.configChange = configChange
+	if _, err := haproxy.Instance.Reload(); err != nil {
 		logPrintf(err.Error())
 		return err
 	}
-	if err := haproxy.Instance.Reload(); err != nil {
+	if err := m.removeFromRegistry(m.ConsulAddresses, m.ServiceName, m.InstanceName, m.Mode); err != nil {
 		logPrintf(err.Error())
 		return err
 	}
 	return nil
 }
 
-func (m *Remove) removeFiles(templatesPath, serviceName, aclName string, registryAddresses []string, instanceName, mode string) error {
-	logPrintf("Removing the %s configuration files", serviceName)
-	if len(aclName) == 0 {
-		aclName = serviceName
+// GetDiff returns the difference between the current and the proposed proxy configuration
+// in the unified diff format. Nothing is removed or reloaded.
+func (m *Remove) GetDiff() (string, error) {
+	aclName := m.getAclName()
+	return haproxy.Instance.GetConfigDiff(haproxy.ConfigChanges{
+		Remove: []string{
+			fmt.Sprintf("%s-fe.cfg", aclName),
+			fmt.Sprintf("%s-be.cfg", aclName),
+		},
+	})
+}
+
+// GetConfigChange returns the proxy configuration before and after the last execution
+func (m *Remove) GetConfigChange() actions.ConfigChange {
+	return m.configChange
+}
+
+func (m *Remove) getAclName() string {
+	if len(m.AclName) == 0 {
+		return m.ServiceName
 	}
+	return m.AclName
+}
+
+func (m *Remove) removeFiles(templatesPath, serviceName, aclName string) error {
+	logPrintf("Removing the %s configuration files", serviceName)
 	paths := []string{
 		fmt.Sprintf("%s/%s-fe.cfg", templatesPath, aclName),
 		fmt.Sprintf("%s/%s-be.cfg", templatesPath, aclName),
 	}
-	mu.Lock()
-	defer mu.Unlock()
 	for _, path := range paths {
 		if err := osRemove(path); err != nil {
 			return err
 		}
 	}
+	actions.RemoveService(serviceName)
+	return nil
+}
+
+func (m *Remove) removeFromRegistry(registryAddresses []string, serviceName, instanceName, mode string) error {
 	if !strings.EqualFold(mode, "service") && !strings.EqualFold(mode, "swarm") {
 		var err error
 		if len(registryAddresses) > 0 {
@@ -3,7 +3,9 @@
 package main
 
 import (
+	"./actions"
 	haproxy "./proxy"
+	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -18,6 +20,7 @@ type RemoveTestSuite struct {
 	TemplatesPath string
 	ConsulAddress string
 	InstanceName  string
+	proxyOrig     haproxy.Proxy
 }
 
 func (s *RemoveTestSuite) SetupTest() {
@@ -29,6 +32,8 @@ func (s *RemoveTestSuite) SetupTest() {
 	osRemove = func(name string) error {
 		return nil
 	}
+	s.proxyOrig = haproxy.Instance
+	haproxy.Instance = getProxyMock("")
 	s.remove = Remove{
 		ServiceName:     s.ServiceName,
 		ConfigsPath:     s.ConfigsPath,
@@ -38,6 +43,10 @@ func (s *RemoveTestSuite) SetupTest() {
 	}
 }
 
+func (s *RemoveTestSuite) TearDownTest() {
+	haproxy.Instance = s.proxyOrig
+}
+
 // Execute
 
 func (s RemoveTestSuite) Test_Execute_RemovesConfigurationFile() {
@@ -83,6 +92,16 @@ func (s RemoveTestSuite) Test_Execute_ReturnsError_WhenFailure() {
 	s.Error(err)
 }
 
+func (s RemoveTestSuite) Test_Execute_RemovesService() {
+	actions.PutService(actions.ServiceReconfigure{ServiceName: s.ServiceName})
+
+	s.remove.Execute([]string{})
+
+	for _, sr := range actions.GetServices() {
+		s.NotEqual(s.ServiceName, sr.ServiceName)
+	}
+}
+
 func (s RemoveTestSuite) Test_Execute_Invokes_HaProxyCreateConfigFromTemplates() {
 	proxyOrig := haproxy.Instance
 	defer func() { haproxy.Instance = proxyOrig }()
@@ -106,6 +125,30 @@ func (s RemoveTestSuite) Test_Execute_ReturnsError_WhenHaProxyCreateConfigFromTe
 	s.Error(err)
 }
 
+func (s RemoveTestSuite) Test_Execute_RestoresService_WhenHaProxyCreateConfigFromTemplatesFails() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	mockObj := getProxyMock("CreateConfigFromTemplates")
+	mockObj.On("CreateConfigFromTemplates", mock.Anything, mock.Anything).Return(fmt.Errorf("This is an error"))
+	haproxy.Instance = mockObj
+	registryMock := getRegistrarableMock("")
+	registryInstanceOrig := registryInstance
+	defer func() { registryInstance = registryInstanceOrig }()
+	registryInstance = registryMock
+	actions.PutService(actions.ServiceReconfigure{ServiceName: s.ServiceName})
+	defer actions.RemoveService(s.ServiceName)
+
+	s.remove.Execute([]string{})
+
+	found := false
+	for _, sr := range actions.GetServices() {
+		found = found || sr.ServiceName == s.ServiceName
+	}
+	s.True(found)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+	registryMock.AssertNotCalled(s.T(), "DeleteService", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func (s RemoveTestSuite) Test_Execute_Invokes_HaProxyReload() {
 	proxyOrig := haproxy.Instance
 	defer func() { haproxy.Instance = proxyOrig }()
@@ -176,6 +219,50 @@ func (s RemoveTestSuite) Test_Execute_ReturnsError_WhenDeleteRequestToRegistryFa
 	s.Error(err)
 }
 
+// GetDiff
+
+func (s RemoveTestSuite) Test_GetDiff_InvokesProxyGetConfigDiff() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	mockObj := getProxyMock("GetConfigDiff")
+	mockObj.On("GetConfigDiff", mock.Anything).Return("this is a diff", nil)
+	haproxy.Instance = mockObj
+	expected := haproxy.ConfigChanges{
+		Remove: []string{
+			fmt.Sprintf("%s-fe.cfg", s.ServiceName),
+			fmt.Sprintf("%s-be.cfg", s.ServiceName),
+		},
+	}
+
+	actual, err := s.remove.GetDiff()
+
+	s.NoError(err)
+	s.Equal("this is a diff", actual)
+	mockObj.AssertCalled(s.T(), "GetConfigDiff", expected)
+}
+
+func (s RemoveTestSuite) Test_Execute_DoesNotRemoveFiles_WhenDryRun() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	mockObj := getProxyMock("")
+	haproxy.Instance = mockObj
+	stdoutOrig := stdout
+	defer func() { stdout = stdoutOrig }()
+	stdout = &bytes.Buffer{}
+	removed := false
+	osRemove = func(name string) error {
+		removed = true
+		return nil
+	}
+	s.remove.DryRun = true
+
+	err := s.remove.Execute([]string{})
+
+	s.NoError(err)
+	s.False(removed)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
 // Suite
 
 func TestRemoveUnitTestSuite(t *testing.T) {
@@ -198,10 +285,26 @@ func (m *RemoveMock) Execute(args []string) error {
 	return params.Error(0)
 }
 
+func (m *RemoveMock) GetDiff() (string, error) {
+	params := m.Called()
+	return params.String(0), params.Error(1)
+}
+
+func (m *RemoveMock) GetConfigChange() actions.ConfigChange {
+	params := m.Called()
+	return params.Get(0).(actions.ConfigChange)
+}
+
 func getRemoveMock(skipMethod string) *RemoveMock {
 	mockObj := new(RemoveMock)
 	if skipMethod != "Execute" {
 		mockObj.On("Execute", mock.Anything).Return(nil)
 	}
+	if skipMethod != "GetDiff" {
+		mockObj.On("GetDiff").Return("", nil)
+	}
+	if skipMethod != "GetConfigChange" {
+		mockObj.On("GetConfigChange").Return(actions.ConfigChange{})
+	}
 	return mockObj
 }
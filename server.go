@@ -2,20 +2,27 @@ package main
 
 import (
 	"./actions"
+	"./events"
+	"./metrics"
 	"./proxy"
 	"./server"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"io/ioutil"
 	"bytes"
 )
 
 const (
-	DISTRIBUTED = "Distributed to all instances"
+	DISTRIBUTED  = "Distributed to all instances"
+	SERVERS_PATH = "/v1/docker-flow-proxy/servers/"
 )
 
 type Server interface {
@@ -24,17 +31,27 @@ type Server interface {
 }
 
 type Serve struct {
-	IP              string `short:"i" long:"ip" default:"0.0.0.0" env:"IP" description:"IP the server listens to."`
-	Mode            string `short:"m" long:"mode" env:"MODE" description:"If set to 'swarm', proxy will operate assuming that Docker service from v1.12+ is used."`
-	ListenerAddress string `short:"l" long:"listener-address" env:"LISTENER_ADDRESS" description:"The address of the Docker Flow: Swarm Listener. The address matches the name of the Swarm service (e.g. swarm-listener)"`
-	Port            string `short:"p" long:"port" default:"8080" env:"PORT" description:"Port the server listens to."`
-	ServiceName     string `short:"n" long:"service-name" default:"proxy" env:"SERVICE_NAME" description:"The name of the proxy service. It is used only when running in 'swarm' mode and must match the '--name' parameter used to launch the service."`
+	IP                  string `short:"i" long:"ip" default:"0.0.0.0" env:"IP" description:"IP the server listens to."`
+	Mode                string `short:"m" long:"mode" env:"MODE" description:"If set to 'swarm', proxy will operate assuming that Docker service from v1.12+ is used."`
+	ListenerAddress     string `short:"l" long:"listener-address" env:"LISTENER_ADDRESS" description:"The address of the Docker Flow: Swarm Listener. The address matches the name of the Swarm service (e.g. swarm-listener)"`
+	Port                string `short:"p" long:"port" default:"8080" env:"PORT" description:"Port the server listens to."`
+	ServiceName         string `short:"n" long:"service-name" default:"proxy" env:"SERVICE_NAME" description:"The name of the proxy service. It is used only when running in 'swarm' mode and must match the '--name' parameter used to launch the service."`
+	ReloadWindow        int    `long:"reload-window" default:"250" env:"RELOAD_WINDOW" description:"The window in milliseconds within which reload requests are coalesced into a single HAProxy reload."`
+	ReloadsPerSec       int    `long:"reloads-per-second" default:"0" env:"RELOADS_PER_SECOND" description:"The maximum number of HAProxy reloads per second. Zero means that reloads are not limited."`
+	HistorySize         int    `long:"history-size" default:"10" env:"HISTORY_SIZE" description:"The number of configuration versions kept for rollbacks. Zero disables the history."`
+	AuthTokensPath      string `long:"auth-tokens-path" env:"AUTH_TOKENS_PATH" description:"The path to the JSON file with the tokens allowed to call the API. If not set, /run/secrets/dfp_auth_tokens is used when it exists. Authentication is disabled when there are no tokens."`
+	ShutdownGracePeriod int    `long:"shutdown-grace-period" default:"10" env:"SHUTDOWN_GRACE_PERIOD" description:"The number of seconds the requests in flight and the open HAProxy connections are given to finish on SIGTERM."`
+	LookupRetry         int    `long:"lookup-retry" default:"3" env:"LOOKUP_RETRY" description:"The number of times the lookup of a service host is retried before a reconfigure request fails."`
+	LookupRetryInterval int    `long:"lookup-retry-interval" default:"500" env:"LOOKUP_RETRY_INTERVAL" description:"The number of milliseconds between the lookups of a service host."`
 	actions.BaseReconfigure
 }
 
 var serverImpl = Serve{}
 var cert server.Certer = server.NewCert("/certs")
 
+// eventsHeartbeat is the interval of the comments sent to the events stream so that idle connections are not closed
+var eventsHeartbeat = 15 * time.Second
+
 type SwarmService struct {
 	Name string `json:"name,omitempty"`
 	Path string `json:"path,omitempty"`
@@ -63,6 +80,85 @@ type Response struct {
 	ReqRepReplace        string
 	TemplateFePath       string
 	TemplateBePath       string
+	HealthCheckPath      string                `json:",omitempty"`
+	HealthCheckMethod    string                `json:",omitempty"`
+	HealthCheckStatus    int                   `json:",omitempty"`
+	HealthCheckInterval  int                   `json:",omitempty"`
+	HealthCheckRise      int                   `json:",omitempty"`
+	HealthCheckFall      int                   `json:",omitempty"`
+	HealthCheckPort      int                   `json:",omitempty"`
+	TimeoutServer        int                   `json:",omitempty"`
+	TimeoutConnect       int                   `json:",omitempty"`
+	TimeoutQueue         int                   `json:",omitempty"`
+	TimeoutTunnel        int                   `json:",omitempty"`
+	MaxConn              int                   `json:",omitempty"`
+	FullConn             int                   `json:",omitempty"`
+	Balance              string                `json:",omitempty"`
+	StickySessions       bool                  `json:",omitempty"`
+	TaskRouting          bool                  `json:",omitempty"`
+	MaxTasks             int                   `json:",omitempty"`
+	ColorWeights         []actions.ColorWeight `json:",omitempty"`
+	HeaderMatches        []actions.RouteMatch  `json:",omitempty"`
+	CookieMatches        []actions.RouteMatch  `json:",omitempty"`
+	QueryMatches         []actions.RouteMatch  `json:",omitempty"`
+	Methods              []string              `json:",omitempty"`
+	Priority             int                   `json:",omitempty"`
+	DryRun               bool
+	Diff                 string
+	Reloaded             bool                      `json:"reloaded"`
+	Errors               []actions.ValidationError `json:",omitempty"`
+}
+
+type ServerResponse struct {
+	Status  string
+	Message string
+	proxy.ServerState
+}
+
+type HistoryResponse struct {
+	Status   string
+	Message  string
+	Versions []actions.ConfigVersion
+}
+
+type RollbackResponse struct {
+	Status   string
+	Message  string
+	Version  int
+	Reloaded bool `json:"reloaded"`
+}
+
+type CanaryResponse struct {
+	Status       string
+	Message      string
+	ServiceName  string
+	ColorWeights []actions.ColorWeight
+	Runtime      bool
+	Reloaded     bool `json:"reloaded"`
+}
+
+type ExplainResponse struct {
+	Status  string
+	Message string
+	Host    string
+	Path    string
+	Port    int
+	Method  string
+	Rules   []proxy.ExplainRule
+	Backend string
+	Servers []string
+}
+
+type AuditResponse struct {
+	Status  string
+	Message string
+	Records []AuditRecord
+}
+
+type HealthResponse struct {
+	Status  string
+	Message string
+	proxy.ProcessState
 }
 
 func (m *Serve) Execute(args []string) error {
@@ -70,6 +166,22 @@ func (m *Serve) Execute(args []string) error {
 	if proxy.Instance == nil {
 		proxy.Instance = proxy.NewHaProxy(m.TemplatesPath, m.ConfigsPath, map[string]bool{})
 	}
+	proxy.SetReloadSchedule(time.Duration(m.ReloadWindow)*time.Millisecond, m.ReloadsPerSec)
+	if path := getAuthTokensPath(m.AuthTokensPath); len(path) > 0 {
+		tokens, err := loadAuthTokens(path)
+		if err != nil {
+			return err
+		}
+		authTokens = tokens
+		logPrintf("Loaded %d auth tokens from %s", len(tokens), path)
+	}
+	if len(m.ConfigsPath) > 0 {
+		auditPath = fmt.Sprintf("%s/audit.jsonl", m.ConfigsPath)
+	}
+	shutdownGracePeriod = time.Duration(m.ShutdownGracePeriod) * time.Second
+	if m.HistorySize > 0 {
+		actions.SetHistory(fmt.Sprintf("%s/history.json", m.ConfigsPath), m.HistorySize)
+	}
 	logPrintf("Starting HAProxy")
 	m.setConsulAddresses()
 	NewRun().Execute([]string{})
@@ -79,7 +191,9 @@ func (m *Serve) Execute(args []string) error {
 	if len(m.ListenerAddress) > 0 {
 		lAddr = fmt.Sprintf("http://%s:8080", m.ListenerAddress)
 	}
-	cert.Init()
+	if err := cert.Init(getPeerAuthorization()); err != nil {
+		logPrintf("Could not initialize the certificates\n%s", err.Error())
+	}
 	if err := recon.ReloadAllServices(
 		m.ConsulAddresses,
 		m.InstanceName,
@@ -99,18 +213,53 @@ func (m *Serve) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if !strings.EqualFold(req.URL.Path, "/v1/test") {
 		logPrintf("Processing request %s", req.URL)
 	}
+	req, ok := authorize(w, req)
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, SERVERS_PATH) {
+		m.servers(w, req)
+		return
+	}
 	switch req.URL.Path {
 	case "/v1/docker-flow-proxy/services":
 		m.services(w, req)
+	case "/v2/docker-flow-proxy/services":
+		m.servicesJSON(w, req)
 	case "/v1/docker-flow-proxy/reconfigure":
-		m.reconfigure(w, req)
+		m.serveChange("reconfigure", w, req, m.reconfigure)
 	case "/v1/docker-flow-proxy/remove":
-		m.remove(w, req)
+		m.serveChange("remove", w, req, m.remove)
 	case "/v1/docker-flow-proxy/config":
 		m.config(w, req)
+	case "/v1/docker-flow-proxy/history":
+		m.history(w, req)
+	case "/v1/docker-flow-proxy/explain":
+		m.explain(w, req)
+	case "/v1/docker-flow-proxy/rollback":
+		m.serveChange("rollback", w, req, m.rollback)
+	case "/v1/docker-flow-proxy/canary":
+		m.serveChange("canary", w, req, m.canary)
+	case "/v1/docker-flow-proxy/audit":
+		m.audit(w, req)
+	case "/v1/docker-flow-proxy/events":
+		m.events(w, req)
 	case "/v1/docker-flow-proxy/cert":
 		if req.Method == "PUT" {
-			cert.Put(w, req)
+			m.serveChange("cert", w, req, func(w http.ResponseWriter, req *http.Request) actions.ConfigChange {
+				certName := req.URL.Query().Get("certName")
+				if !canAccessService(req, certName) {
+					writeAuthError(w, http.StatusForbidden, fmt.Sprintf("The credentials do not allow changes to the certificate %s", certName))
+					return actions.ConfigChange{}
+				}
+				_, configChange, err := cert.Put(w, req)
+				eventType := events.TypeCert
+				if distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute")); distribute {
+					eventType = events.TypeDistribute
+				}
+				publishEvent(events.Event{Type: eventType, CertName: certName}, err)
+				return configChange
+			})
 		} else {
 			logPrintf("/v1/docker-flow-proxy/cert endpoint allows only PUT requests. Your was %s", req.Method)
 			w.WriteHeader(http.StatusNotFound)
@@ -118,19 +267,110 @@ func (m *Serve) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	case "/v1/docker-flow-proxy/certs":
 		cert.GetAll(w, req)
 	case "/v1/test", "/v2/test":
-		js, _ := json.Marshal(Response{Status: "OK"})
-		httpWriterSetContentType(w, "application/json")
-		if !strings.EqualFold(req.URL.Path, "/v1/test") {
-
-		}
-		w.WriteHeader(http.StatusOK)
-		w.Write(js)
+		m.test(w, req)
+	case "/metrics":
+		m.metrics(w, req)
 	default:
 		logPrintf("The endpoint %s is not supported", req.URL.Path)
 		w.WriteHeader(http.StatusNotFound)
 	}
 }
 
+// serveChange serves a request that changes the configuration. The request is counted by its outcome and,
+// if the audit is enabled, recorded in the audit log together with the difference it made to the configuration.
+func (m *Serve) serveChange(endpoint string, w http.ResponseWriter, req *http.Request, handler func(http.ResponseWriter, *http.Request) actions.ConfigChange) {
+	rec := newStatusRecorder(w)
+	if len(auditPath) == 0 {
+		handler(rec, req)
+		metrics.IncRequest(endpoint, rec.getOutcome())
+		return
+	}
+	record := newAuditRecord(endpoint, req, endpoint == "reconfigure")
+	configChange := handler(rec, req)
+	metrics.IncRequest(endpoint, rec.getOutcome())
+	record.Diff = proxy.RedactConfig(proxy.ConfigDiff(configChange.Before, configChange.After))
+	record.Status = rec.status
+	record.Success = rec.status < 300
+	writeAuditRecord(record)
+}
+
+// test responds with the state of the HAProxy process. The status is 500 if HAProxy is not running
+// so that health checks fail.
+func (m *Serve) test(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	response := HealthResponse{Status: "OK", ProcessState: proxy.Instance.GetProcessState()}
+	if response.Running {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		response.Status = "NOK"
+		response.Message = "HAProxy is not running"
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// metrics outputs the metrics of the proxy and HAProxy in the Prometheus text format
+func (m *Serve) metrics(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		logPrintf("/metrics endpoint allows only GET requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	metrics.WriteControllerMetrics(&buf)
+	metrics.Write(&buf, "docker_flow_proxy_services", "The number of services the proxy is routing to.", "gauge", []metrics.Sample{
+		{Value: float64(len(actions.GetServices()))},
+	})
+	certs := proxy.Instance.GetCerts()
+	certNames := []string{}
+	for name := range certs {
+		certNames = append(certNames, name)
+	}
+	sort.Strings(certNames)
+	expiry := []metrics.Sample{}
+	for _, name := range certNames {
+		notAfter, err := getCertExpiry([]byte(certs[name]))
+		if err != nil {
+			logPrintf("Could not get the expiry time of the certificate %s\n%s", name, err.Error())
+			continue
+		}
+		expiry = append(expiry, metrics.Sample{Labels: map[string]string{"cert": name}, Value: float64(notAfter.Unix())})
+	}
+	metrics.Write(&buf, "docker_flow_proxy_certificate_expiry_timestamp_seconds", "The time the certificate expires.", "gauge", expiry)
+	haUp := float64(1)
+	stats, err := proxy.Instance.GetStats()
+	if err != nil {
+		logPrintf("Could not get the HAProxy stats\n%s", err.Error())
+		haUp = 0
+	}
+	metrics.Write(&buf, "haproxy_up", "Whether the HAProxy stats could be read.", "gauge", []metrics.Sample{{Value: haUp}})
+	if err == nil {
+		proxy.WriteStatsMetrics(&buf, stats)
+	}
+	httpWriterSetContentType(w, "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// getCertExpiry returns the expiry time of the first certificate in the PEM-encoded content
+func getCertExpiry(content []byte) (time.Time, error) {
+	for {
+		block, rest := pem.Decode(content)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("The content does not contain a PEM-encoded certificate")
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return cert.NotAfter, nil
+		}
+		content = rest
+	}
+}
+
 func (m *Serve) isValidReconf(name string, path, domain []string, templateFePath string) bool {
 	return len(name) > 0 && (len(path) > 0 || len(templateFePath) > 0)
 }
@@ -142,12 +382,12 @@ func (m *Serve) services(w http.ResponseWriter, req *http.Request) {
 		lAddr = fmt.Sprintf("http://%s:8080/v1/docker-flow-swarm-listener/services", m.ListenerAddress)
 	} 
 	resp, err := http.Get(lAddr)
-	defer resp.Body.Close()
 	if err != nil {
 		logPrintf("Unable to get registered services")
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`<h3>Unable to get registered services</h3>`))
 	} else {
+		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			logPrintf("Cannot read response body")
@@ -179,45 +419,88 @@ func (m *Serve) services(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
-	sr := actions.ServiceReconfigure{
-		ServiceName:          req.URL.Query().Get("serviceName"),
-		AclName:              req.URL.Query().Get("aclName"),
-		ServiceColor:         req.URL.Query().Get("serviceColor"),
-		ServiceCert:          req.URL.Query().Get("serviceCert"),
-		OutboundHostname:     req.URL.Query().Get("outboundHostname"),
-		ConsulTemplateFePath: req.URL.Query().Get("consulTemplateFePath"),
-		ConsulTemplateBePath: req.URL.Query().Get("consulTemplateBePath"),
-		PathType:             req.URL.Query().Get("pathType"),
-		Port:                 req.URL.Query().Get("port"),
-		Mode:                 m.Mode,
-		ReqRepSearch:         req.URL.Query().Get("reqRepSearch"),
-		ReqRepReplace:        req.URL.Query().Get("reqRepReplace"),
-		TemplateFePath:       req.URL.Query().Get("templateFePath"),
-		TemplateBePath:       req.URL.Query().Get("templateBePath"),
-	}
-	if len(req.URL.Query().Get("httpsPort")) > 0 {
-		sr.HttpsPort, _ = strconv.Atoi(req.URL.Query().Get("httpsPort"))
+func (m *Serve) servicesJSON(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "GET" {
+		logPrintf("/v2/docker-flow-proxy/services endpoint allows only GET requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
-	if len(req.URL.Query().Get("servicePath")) > 0 {
-		sr.ServicePath = strings.Split(req.URL.Query().Get("servicePath"), ",")
+	js, _ := json.Marshal(actions.GetServices())
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// servers reads or changes the state and the weight of a backend server through the HAProxy runtime API.
+// The path has the format /v1/docker-flow-proxy/servers/{backend}/{server}.
+func (m *Serve) servers(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	response := ServerResponse{Status: "OK"}
+	status := http.StatusOK
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, SERVERS_PATH), "/")
+	state := req.URL.Query().Get("state")
+	weight := req.URL.Query().Get("weight")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		status = http.StatusBadRequest
+		response.Message = "The path must have the format " + SERVERS_PATH + "{backend}/{server}"
+	} else if req.Method == "PUT" && !canAccessService(req, parts[0]) {
+		status = http.StatusForbidden
+		response.Message = fmt.Sprintf("The credentials do not allow changes to the backend %s", parts[0])
+	} else if req.Method == "PUT" {
+		if len(state) == 0 && len(weight) == 0 {
+			status = http.StatusBadRequest
+			response.Message = "The state or the weight query is mandatory"
+		} else if len(state) > 0 {
+			if err := proxy.Instance.SetServerState(parts[0], parts[1], state); err != nil {
+				status = http.StatusInternalServerError
+				response.Message = err.Error()
+			}
+		}
+		if status == http.StatusOK && len(weight) > 0 {
+			if n, err := strconv.Atoi(weight); err != nil {
+				status = http.StatusBadRequest
+				response.Message = fmt.Sprintf("The weight %s is not a number", weight)
+			} else if err := proxy.Instance.SetServerWeight(parts[0], parts[1], n); err != nil {
+				status = http.StatusInternalServerError
+				response.Message = err.Error()
+			}
+		}
+	} else if req.Method != "GET" {
+		logPrintf("%s endpoint allows only GET and PUT requests. Your was %s", SERVERS_PATH, req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
-	if len(req.URL.Query().Get("serviceDomain")) > 0 {
-		sr.ServiceDomain = strings.Split(req.URL.Query().Get("serviceDomain"), ",")
+	if status == http.StatusOK {
+		if serverState, err := proxy.Instance.GetServerState(parts[0], parts[1]); err != nil {
+			status = http.StatusInternalServerError
+			response.Message = err.Error()
+		} else {
+			response.ServerState = serverState
+		}
 	}
-	if len(req.URL.Query().Get("skipCheck")) > 0 {
-		sr.SkipCheck, _ = strconv.ParseBool(req.URL.Query().Get("skipCheck"))
+	if status != http.StatusOK {
+		response.Status = "NOK"
 	}
-	if len(req.URL.Query().Get("distribute")) > 0 {
-		sr.Distribute, _ = strconv.ParseBool(req.URL.Query().Get("distribute"))
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) (configChange actions.ConfigChange) {
+	sr, fromBody, fieldErrors, err := m.getServiceReconfigure(req)
+	if err != nil {
+		response := Response{Status: "NOK"}
+		m.writeBadRequest(w, &response, err.Error())
+		httpWriterSetContentType(w, "application/json")
+		js, _ := json.Marshal(response)
+		w.Write(js)
+		return
 	}
-	if len(req.URL.Query().Get("users")) > 0 {
-		users := strings.Split(req.URL.Query().Get("users"), ",")
-		for _, user := range users {
-			userPass := strings.Split(user, ":")
-			sr.Users = append(sr.Users, actions.User{Username: userPass[0], Password: userPass[1]})
-		}
+	if err := sr.LoadUsers(); err != nil {
+		fieldErrors = append(fieldErrors, actions.ValidationError{Field: "usersSecret", Message: err.Error()})
 	}
+	sr.LookupRetry = m.LookupRetry
+	sr.LookupRetryInterval = m.LookupRetryInterval
 	response := Response{
 		Status:               "OK",
 		ServiceName:          sr.ServiceName,
@@ -225,7 +508,7 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 		ServiceColor:         sr.ServiceColor,
 		ServicePath:          sr.ServicePath,
 		ServiceDomain:        sr.ServiceDomain,
-		ServiceCert:          sr.ServiceCert,
+		ServiceCert:          actions.RedactCert(sr.ServiceCert),
 		OutboundHostname:     sr.OutboundHostname,
 		ConsulTemplateFePath: sr.ConsulTemplateFePath,
 		ConsulTemplateBePath: sr.ConsulTemplateBePath,
@@ -235,23 +518,78 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 		Port:                 sr.Port,
 		HttpsPort:		      sr.HttpsPort,
 		Distribute:           sr.Distribute,
-		Users:                sr.Users,
+		Users:                actions.RedactUsers(sr.Users),
 		ReqRepSearch:         sr.ReqRepSearch,
 		ReqRepReplace:        sr.ReqRepReplace,
 		TemplateFePath:       sr.TemplateFePath,
 		TemplateBePath:       sr.TemplateBePath,
+		HealthCheckPath:      sr.HealthCheckPath,
+		HealthCheckMethod:    sr.HealthCheckMethod,
+		HealthCheckStatus:    sr.HealthCheckStatus,
+		HealthCheckInterval:  sr.HealthCheckInterval,
+		HealthCheckRise:      sr.HealthCheckRise,
+		HealthCheckFall:      sr.HealthCheckFall,
+		HealthCheckPort:      sr.HealthCheckPort,
+		TimeoutServer:        sr.TimeoutServer,
+		TimeoutConnect:       sr.TimeoutConnect,
+		TimeoutQueue:         sr.TimeoutQueue,
+		TimeoutTunnel:        sr.TimeoutTunnel,
+		MaxConn:              sr.MaxConn,
+		FullConn:             sr.FullConn,
+		Balance:              sr.Balance,
+		StickySessions:       sr.StickySessions,
+		TaskRouting:          sr.TaskRouting,
+		MaxTasks:             sr.MaxTasks,
+		ColorWeights:         sr.ColorWeights,
+		HeaderMatches:        sr.HeaderMatches,
+		CookieMatches:        sr.CookieMatches,
+		QueryMatches:         sr.QueryMatches,
+		Methods:              sr.Methods,
+		Priority:             sr.Priority,
+		DryRun:               sr.DryRun,
 	}
-	if m.isValidReconf(sr.ServiceName, sr.ServicePath, sr.ServiceDomain, sr.ConsulTemplateFePath) {
+	fieldErrors = append(fieldErrors, sr.Validate()...)
+	if conflict, ok := actions.FindRouteConflict(sr); ok {
+		fieldErrors = append(fieldErrors, actions.ValidationError{
+			Field:   "servicePath",
+			Message: fmt.Sprintf("The paths, domains, and matches of the service %s are the same as those of the service %s", sr.ServiceName, conflict),
+		})
+	}
+	if !canAccessService(req, sr.ServiceName) {
+		m.writeForbidden(w, &response, fmt.Sprintf("The credentials do not allow changes to the service %s", sr.ServiceName))
+	} else if len(fieldErrors) > 0 {
+		m.writeBadRequest(w, &response, "The request is not valid")
+		response.Errors = fieldErrors
+	} else if m.isValidReconf(sr.ServiceName, sr.ServicePath, sr.ServiceDomain, sr.ConsulTemplateFePath) {
 		if (strings.EqualFold("service", m.Mode) || strings.EqualFold("swarm", m.Mode)) && len(sr.Port) == 0 {
 			m.writeBadRequest(w, &response, `When MODE is set to "service" or "swarm", the port query is mandatory`)
+		} else if sr.DryRun {
+			action := actions.NewReconfigure(m.BaseReconfigure, sr)
+			if diff, err := action.GetDiff(); err != nil {
+				m.writeInternalServerError(w, &response, err.Error())
+			} else {
+				response.Diff = proxy.RedactConfig(diff)
+				w.WriteHeader(http.StatusOK)
+			}
 		} else if sr.Distribute {
+			if fromBody {
+				// The body is forwarded as-is so it must not request distribution again
+				body := sr
+				body.Distribute = false
+				// The users from the secret are already loaded
+				body.UsersSecret = ""
+				js, _ := json.Marshal(body)
+				req.Body = ioutil.NopCloser(bytes.NewReader(js))
+			}
 			srv := server.Serve{}
-			if status, err := srv.SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || status >= 300 {
+			err := m.getDistributeError(srv.SendDistributeRequests(req, m.Port, m.ServiceName))
+			if err != nil {
 				m.writeInternalServerError(w, &response, err.Error())
 			} else {
 				response.Message = DISTRIBUTED
 				w.WriteHeader(http.StatusOK)
 			}
+			publishEvent(events.Event{Type: events.TypeDistribute, ServiceName: sr.ServiceName}, err)
 		} else {
 			if len(sr.ServiceCert) > 0 {
 				// Replace \n with proper carriage return as new lines are not supported in labels
@@ -263,11 +601,15 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 				}
 			}
 			action := actions.NewReconfigure(m.BaseReconfigure, sr)
-			if err := action.Execute([]string{}); err != nil {
+			err := action.Execute([]string{})
+			configChange = action.GetConfigChange()
+			if err != nil {
 				m.writeInternalServerError(w, &response, err.Error())
 			} else {
+				response.Reloaded = action.IsReloaded()
 				w.WriteHeader(http.StatusOK)
 			}
+			publishEvent(events.Event{Type: events.TypeReconfigure, ServiceName: sr.ServiceName}, err)
 		}
 	} else {
 		m.writeBadRequest(w, &response, "The following queries are mandatory: (serviceName and servicePath) or (serviceName, consulTemplateFePath, and consulTemplateBePath)")
@@ -275,6 +617,155 @@ func (m *Serve) reconfigure(w http.ResponseWriter, req *http.Request) {
 	httpWriterSetContentType(w, "application/json")
 	js, _ := json.Marshal(response)
 	w.Write(js)
+	return configChange
+}
+
+// getServiceReconfigure returns the service configuration from the JSON body or, if there is no body, from the query.
+// Query arguments that cannot be parsed are returned as field errors.
+func (m *Serve) getServiceReconfigure(req *http.Request) (sr actions.ServiceReconfigure, fromBody bool, fieldErrors []actions.ValidationError, err error) {
+	if (req.Method == "POST" || req.Method == "PUT") && req.Body != nil {
+		defer req.Body.Close()
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return sr, false, nil, fmt.Errorf("Could not read the request body\n%s", err.Error())
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &sr); err != nil {
+				return sr, true, nil, fmt.Errorf("Could not parse the request body as JSON\n%s", err.Error())
+			}
+			sr.Mode = m.Mode
+			return sr, true, []actions.ValidationError{}, nil
+		}
+	}
+	sr, fieldErrors = m.getServiceReconfigureFromQuery(req)
+	return sr, false, fieldErrors, nil
+}
+
+func (m *Serve) getServiceReconfigureFromQuery(req *http.Request) (actions.ServiceReconfigure, []actions.ValidationError) {
+	fieldErrors := []actions.ValidationError{}
+	sr := actions.ServiceReconfigure{
+		ServiceName:          req.URL.Query().Get("serviceName"),
+		AclName:              req.URL.Query().Get("aclName"),
+		ServiceColor:         req.URL.Query().Get("serviceColor"),
+		ServiceCert:          req.URL.Query().Get("serviceCert"),
+		OutboundHostname:     req.URL.Query().Get("outboundHostname"),
+		ConsulTemplateFePath: req.URL.Query().Get("consulTemplateFePath"),
+		ConsulTemplateBePath: req.URL.Query().Get("consulTemplateBePath"),
+		PathType:             req.URL.Query().Get("pathType"),
+		Port:                 req.URL.Query().Get("port"),
+		Mode:                 m.Mode,
+		ReqRepSearch:         req.URL.Query().Get("reqRepSearch"),
+		ReqRepReplace:        req.URL.Query().Get("reqRepReplace"),
+		TemplateFePath:       req.URL.Query().Get("templateFePath"),
+		TemplateBePath:       req.URL.Query().Get("templateBePath"),
+		UsersSecret:          req.URL.Query().Get("usersSecret"),
+		HealthCheckPath:      req.URL.Query().Get("healthCheckPath"),
+		HealthCheckMethod:    req.URL.Query().Get("healthCheckMethod"),
+		Balance:              req.URL.Query().Get("balance"),
+	}
+	if len(req.URL.Query().Get("httpsPort")) > 0 {
+		var err error
+		if sr.HttpsPort, err = strconv.Atoi(req.URL.Query().Get("httpsPort")); err != nil {
+			fieldErrors = append(fieldErrors, actions.ValidationError{
+				Field:   "httpsPort",
+				Message: fmt.Sprintf("The HTTPS port %s must be a number between 1 and 65535", req.URL.Query().Get("httpsPort")),
+			})
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{
+		{"healthCheckStatus", &sr.HealthCheckStatus},
+		{"healthCheckInterval", &sr.HealthCheckInterval},
+		{"healthCheckRise", &sr.HealthCheckRise},
+		{"healthCheckFall", &sr.HealthCheckFall},
+		{"healthCheckPort", &sr.HealthCheckPort},
+		{"timeoutServer", &sr.TimeoutServer},
+		{"timeoutConnect", &sr.TimeoutConnect},
+		{"timeoutQueue", &sr.TimeoutQueue},
+		{"timeoutTunnel", &sr.TimeoutTunnel},
+		{"maxConn", &sr.MaxConn},
+		{"fullConn", &sr.FullConn},
+		{"maxTasks", &sr.MaxTasks},
+		{"priority", &sr.Priority},
+	} {
+		if len(req.URL.Query().Get(field.name)) > 0 {
+			var err error
+			if *field.value, err = strconv.Atoi(req.URL.Query().Get(field.name)); err != nil {
+				fieldErrors = append(fieldErrors, actions.ValidationError{
+					Field:   field.name,
+					Message: fmt.Sprintf("The value %s of %s must be a number", req.URL.Query().Get(field.name), field.name),
+				})
+			}
+		}
+	}
+	if len(req.URL.Query().Get("colorWeights")) > 0 {
+		var err error
+		if sr.ColorWeights, err = actions.ParseColorWeights(req.URL.Query().Get("colorWeights")); err != nil {
+			fieldErrors = append(fieldErrors, actions.ValidationError{Field: "colorWeights", Message: err.Error()})
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value *[]actions.RouteMatch
+	}{
+		{"headerMatches", &sr.HeaderMatches},
+		{"cookieMatches", &sr.CookieMatches},
+		{"queryMatches", &sr.QueryMatches},
+	} {
+		if len(req.URL.Query().Get(field.name)) > 0 {
+			var err error
+			if *field.value, err = actions.ParseRouteMatches(req.URL.Query().Get(field.name)); err != nil {
+				fieldErrors = append(fieldErrors, actions.ValidationError{Field: field.name, Message: err.Error()})
+			}
+		}
+	}
+	if len(req.URL.Query().Get("methods")) > 0 {
+		sr.Methods = strings.Split(strings.ToUpper(req.URL.Query().Get("methods")), ",")
+	}
+	if len(req.URL.Query().Get("servicePath")) > 0 {
+		sr.ServicePath = strings.Split(req.URL.Query().Get("servicePath"), ",")
+	}
+	if len(req.URL.Query().Get("serviceDomain")) > 0 {
+		sr.ServiceDomain = strings.Split(req.URL.Query().Get("serviceDomain"), ",")
+	}
+	for _, field := range []struct {
+		name  string
+		value *bool
+	}{
+		{"skipCheck", &sr.SkipCheck},
+		{"distribute", &sr.Distribute},
+		{"dryRun", &sr.DryRun},
+		{"usersPassEncrypted", &sr.UsersPassEncrypted},
+		{"stickySessions", &sr.StickySessions},
+		{"taskRouting", &sr.TaskRouting},
+	} {
+		if len(req.URL.Query().Get(field.name)) > 0 {
+			var err error
+			if *field.value, err = strconv.ParseBool(req.URL.Query().Get(field.name)); err != nil {
+				fieldErrors = append(fieldErrors, actions.ValidationError{
+					Field:   field.name,
+					Message: fmt.Sprintf("The value %s must be true or false", req.URL.Query().Get(field.name)),
+				})
+			}
+		}
+	}
+	if len(req.URL.Query().Get("users")) > 0 {
+		users := strings.Split(req.URL.Query().Get("users"), ",")
+		for _, user := range users {
+			userPass := strings.SplitN(user, ":", 2)
+			if len(userPass) != 2 {
+				fieldErrors = append(fieldErrors, actions.ValidationError{
+					Field:   "users",
+					Message: fmt.Sprintf("The user %s must have the format user:password", user),
+				})
+				continue
+			}
+			sr.Users = append(sr.Users, actions.User{Username: userPass[0], Password: userPass[1]})
+		}
+	}
+	return sr, fieldErrors
 }
 
 func (m *Serve) writeBadRequest(w http.ResponseWriter, resp *Response, msg string) {
@@ -289,14 +780,23 @@ func (m *Serve) writeInternalServerError(w http.ResponseWriter, resp *Response,
 	w.WriteHeader(http.StatusInternalServerError)
 }
 
-func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
+func (m *Serve) writeForbidden(w http.ResponseWriter, resp *Response, msg string) {
+	resp.Status = "NOK"
+	resp.Message = msg
+	w.WriteHeader(http.StatusForbidden)
+}
+
+func (m *Serve) remove(w http.ResponseWriter, req *http.Request) (configChange actions.ConfigChange) {
 	serviceName := req.URL.Query().Get("serviceName")
 	distribute := false
 	response := Response{
 		Status:      "OK",
 		ServiceName: serviceName,
 	}
-	if len(req.URL.Query().Get("distribute")) > 0 {
+	if len(req.URL.Query().Get("dryRun")) > 0 {
+		response.DryRun, _ = strconv.ParseBool(req.URL.Query().Get("dryRun"))
+	}
+	if len(req.URL.Query().Get("distribute")) > 0 && !response.DryRun {
 		distribute, _ = strconv.ParseBool(req.URL.Query().Get("distribute"))
 		if distribute {
 			response.Distribute = distribute
@@ -307,14 +807,34 @@ func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
 		response.Status = "NOK"
 		response.Message = "The serviceName query is mandatory"
 		w.WriteHeader(http.StatusBadRequest)
+	} else if !canAccessService(req, serviceName) {
+		m.writeForbidden(w, &response, fmt.Sprintf("The credentials do not allow changes to the service %s", serviceName))
+	} else if response.DryRun {
+		action := NewRemove(
+			serviceName,
+			req.URL.Query().Get("aclName"),
+			m.BaseReconfigure.ConfigsPath,
+			m.BaseReconfigure.TemplatesPath,
+			m.ConsulAddresses,
+			m.InstanceName,
+			m.Mode,
+		)
+		if diff, err := action.GetDiff(); err != nil {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			response.Diff = proxy.RedactConfig(diff)
+			w.WriteHeader(http.StatusOK)
+		}
 	} else if distribute {
 		srv := server.Serve{}
-		if status, err := srv.SendDistributeRequests(req, m.Port, m.ServiceName); err != nil || status >= 300 {
+		err := m.getDistributeError(srv.SendDistributeRequests(req, m.Port, m.ServiceName))
+		if err != nil {
 			m.writeInternalServerError(w, &response, err.Error())
 		} else {
 			response.Message = DISTRIBUTED
 			w.WriteHeader(http.StatusOK)
 		}
+		publishEvent(events.Event{Type: events.TypeDistribute, ServiceName: serviceName}, err)
 	} else {
 		logPrintf("Processing remove request %s", req.URL.Path)
 		aclName := req.URL.Query().Get("aclName")
@@ -327,12 +847,19 @@ func (m *Serve) remove(w http.ResponseWriter, req *http.Request) {
 			m.InstanceName,
 			m.Mode,
 		)
-		action.Execute([]string{})
-		w.WriteHeader(http.StatusOK)
+		err := action.Execute([]string{})
+		configChange = action.GetConfigChange()
+		if err != nil {
+			m.writeInternalServerError(w, &response, err.Error())
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		publishEvent(events.Event{Type: events.TypeRemove, ServiceName: serviceName}, err)
 	}
 	httpWriterSetContentType(w, "application/json")
 	js, _ := json.Marshal(response)
 	w.Write(js)
+	return configChange
 }
 
 func (m *Serve) config(w http.ResponseWriter, req *http.Request) {
@@ -343,7 +870,319 @@ func (m *Serve) config(w http.ResponseWriter, req *http.Request) {
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
-	w.Write([]byte(out))
+	w.Write([]byte(proxy.RedactConfig(out)))
+}
+
+// explain returns the routing rules of the current configuration evaluated against the request described by the query,
+// and the backend and the servers the request would be sent to
+func (m *Serve) explain(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "GET" {
+		logPrintf("/v1/docker-flow-proxy/explain endpoint allows only GET requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	explainReq := proxy.ExplainRequest{
+		Host:    req.URL.Query().Get("host"),
+		Path:    req.URL.Query().Get("path"),
+		Port:    80,
+		Method:  strings.ToUpper(req.URL.Query().Get("method")),
+		Headers: http.Header{},
+	}
+	if len(explainReq.Method) == 0 {
+		explainReq.Method = "GET"
+	}
+	response := ExplainResponse{Status: "OK", Rules: []proxy.ExplainRule{}, Servers: []string{}}
+	status := http.StatusOK
+	var err error
+	if len(req.URL.Query().Get("port")) > 0 {
+		if explainReq.Port, err = strconv.Atoi(req.URL.Query().Get("port")); err != nil {
+			err = fmt.Errorf("The port %s is not a number", req.URL.Query().Get("port"))
+		}
+	}
+	for _, header := range req.URL.Query()["header"] {
+		nameValue := strings.SplitN(header, ":", 2)
+		if len(nameValue) != 2 {
+			err = fmt.Errorf("The header %s must have the format name:value", header)
+			break
+		}
+		explainReq.Headers.Add(strings.TrimSpace(nameValue[0]), strings.TrimSpace(nameValue[1]))
+	}
+	if len(explainReq.Host) == 0 {
+		explainReq.Host = explainReq.Headers.Get("Host")
+	}
+	if len(explainReq.Path) == 0 {
+		status = http.StatusBadRequest
+		response.Message = "The path query is mandatory"
+	} else if err != nil {
+		status = http.StatusBadRequest
+		response.Message = err.Error()
+	} else if config, err := proxy.Instance.ReadConfig(); err != nil {
+		status = http.StatusInternalServerError
+		response.Message = err.Error()
+	} else {
+		explanation := proxy.Explain(config, explainReq)
+		response.Rules = explanation.Rules
+		response.Backend = explanation.Backend
+		response.Servers = explanation.Servers
+		if len(response.Backend) == 0 {
+			response.Message = "None of the rules matches the request"
+		}
+	}
+	if status != http.StatusOK {
+		response.Status = "NOK"
+	}
+	response.Host = explainReq.Host
+	response.Path = explainReq.Path
+	response.Port = explainReq.Port
+	response.Method = explainReq.Method
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// history lists the stored configuration versions. If the version query is set, the configuration,
+// the snippets, and the services of that version are returned as well.
+func (m *Serve) history(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "GET" {
+		logPrintf("/v1/docker-flow-proxy/history endpoint allows only GET requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	response := HistoryResponse{Status: "OK", Versions: []actions.ConfigVersion{}}
+	status := http.StatusOK
+	if len(req.URL.Query().Get("version")) > 0 {
+		if number, err := strconv.Atoi(req.URL.Query().Get("version")); err != nil {
+			status = http.StatusBadRequest
+			response.Message = fmt.Sprintf("The version %s is not a number", req.URL.Query().Get("version"))
+		} else if version, err := actions.GetConfigVersion(number); err != nil {
+			status = http.StatusNotFound
+			response.Message = err.Error()
+		} else {
+			response.Versions = append(response.Versions, version.Redacted())
+		}
+	} else if versions, err := actions.GetConfigVersions(); err != nil {
+		status = http.StatusInternalServerError
+		response.Message = err.Error()
+	} else {
+		for _, version := range versions {
+			response.Versions = append(response.Versions, version.Summary())
+		}
+	}
+	if status != http.StatusOK {
+		response.Status = "NOK"
+	}
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// audit lists the audit records filtered by the service query and by the from and to queries in the RFC 3339 format
+func (m *Serve) audit(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "GET" {
+		logPrintf("/v1/docker-flow-proxy/audit endpoint allows only GET requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	response := AuditResponse{Status: "OK", Records: []AuditRecord{}}
+	status := http.StatusOK
+	filter := AuditFilter{ServiceName: req.URL.Query().Get("service")}
+	for _, param := range []string{"from", "to"} {
+		value := req.URL.Query().Get(param)
+		if len(value) == 0 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			status = http.StatusBadRequest
+			response.Message = fmt.Sprintf("The %s query must be in the RFC 3339 format (e.g. 2017-01-02T15:04:05Z)", param)
+		} else if param == "from" {
+			filter.From = t
+		} else {
+			filter.To = t
+		}
+	}
+	if status == http.StatusOK {
+		if records, err := readAuditRecords(filter); err != nil {
+			status = http.StatusInternalServerError
+			response.Message = err.Error()
+		} else {
+			response.Records = records
+		}
+	}
+	if status != http.StatusOK {
+		response.Status = "NOK"
+	}
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+}
+
+// rollback restores the configuration version set through the version query and reloads the proxy
+func (m *Serve) rollback(w http.ResponseWriter, req *http.Request) (configChange actions.ConfigChange) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "POST" {
+		logPrintf("/v1/docker-flow-proxy/rollback endpoint allows only POST requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	response := RollbackResponse{Status: "OK"}
+	status := http.StatusOK
+	if token := getAuthToken(req); token != nil && len(token.ServicePrefixes) > 0 {
+		status = http.StatusForbidden
+		response.Message = "The credentials limited to service prefixes do not allow rollbacks"
+	} else if version, err := strconv.Atoi(req.URL.Query().Get("version")); err != nil {
+		status = http.StatusBadRequest
+		response.Message = "The version query is mandatory and must be a number"
+	} else {
+		response.Version = version
+		action := actions.NewRollback(m.BaseReconfigure, m.Mode, version)
+		err := action.Execute([]string{})
+		configChange = action.GetConfigChange()
+		if err != nil {
+			status = http.StatusInternalServerError
+			response.Message = err.Error()
+		} else {
+			response.Reloaded = action.IsReloaded()
+		}
+		publishEvent(events.Event{Type: events.TypeRollback}, err)
+	}
+	if status != http.StatusOK {
+		response.Status = "NOK"
+	}
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+	return configChange
+}
+
+// canary changes the weights of the colors of a service. The weights are set explicitly (colorWeights),
+// shifted towards a color (color and step), or all the colors but one are dropped (promote).
+func (m *Serve) canary(w http.ResponseWriter, req *http.Request) (configChange actions.ConfigChange) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != "PUT" {
+		logPrintf("/v1/docker-flow-proxy/canary endpoint allows only PUT requests. Your was %s", req.Method)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	change := actions.CanaryChange{
+		ServiceName: req.URL.Query().Get("serviceName"),
+		Color:       req.URL.Query().Get("color"),
+		Promote:     req.URL.Query().Get("promote"),
+	}
+	response := CanaryResponse{Status: "OK", ServiceName: change.ServiceName}
+	status := http.StatusOK
+	distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute"))
+	var err error
+	if len(req.URL.Query().Get("colorWeights")) > 0 {
+		change.ColorWeights, err = actions.ParseColorWeights(req.URL.Query().Get("colorWeights"))
+	} else if len(change.Color) > 0 {
+		if change.Step, err = strconv.Atoi(req.URL.Query().Get("step")); err != nil {
+			err = fmt.Errorf("The step query is mandatory with the color query and must be a number")
+		}
+	}
+	if len(change.ServiceName) == 0 {
+		status = http.StatusBadRequest
+		response.Message = "The serviceName query is mandatory"
+	} else if !canAccessService(req, change.ServiceName) {
+		status = http.StatusForbidden
+		response.Message = fmt.Sprintf("The credentials do not allow changes to the service %s", change.ServiceName)
+	} else if err != nil {
+		status = http.StatusBadRequest
+		response.Message = err.Error()
+	} else if len(change.ColorWeights) == 0 && len(change.Color) == 0 && len(change.Promote) == 0 {
+		status = http.StatusBadRequest
+		response.Message = "The colorWeights, color, or promote query is mandatory"
+	} else if distribute {
+		srv := server.Serve{}
+		err := m.getDistributeError(srv.SendDistributeRequests(req, m.Port, m.ServiceName))
+		if err != nil {
+			status = http.StatusInternalServerError
+			response.Message = err.Error()
+		} else {
+			response.Message = DISTRIBUTED
+		}
+		publishEvent(events.Event{Type: events.TypeDistribute, ServiceName: change.ServiceName}, err)
+	} else {
+		action := actions.NewCanary(m.BaseReconfigure, change)
+		err := action.Execute([]string{})
+		configChange = action.GetConfigChange()
+		if err != nil {
+			status = http.StatusInternalServerError
+			response.Message = err.Error()
+		} else {
+			response.ColorWeights = action.GetColorWeights()
+			response.Runtime = action.IsRuntime()
+			response.Reloaded = action.IsReloaded()
+		}
+		publishEvent(events.Event{Type: events.TypeCanary, ServiceName: change.ServiceName}, err)
+	}
+	if status != http.StatusOK {
+		response.Status = "NOK"
+	}
+	w.WriteHeader(status)
+	js, _ := json.Marshal(response)
+	w.Write(js)
+	return configChange
+}
+
+// events streams the changes of the configuration as server-sent events until the client disconnects.
+// Events published after the one in the Last-Event-ID header are replayed first.
+func (m *Serve) events(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	lastID, _ := strconv.ParseInt(req.Header.Get("Last-Event-ID"), 10, 64)
+	ch := events.Subscribe(lastID)
+	defer events.Unsubscribe(ch)
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			js, _ := json.Marshal(e)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, js)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-closed:
+			return
+		}
+	}
+}
+
+// getDistributeError returns the error of the distribution. Distributions that failed on any of the instances are errors.
+func (m *Serve) getDistributeError(status int, err error) error {
+	if err == nil && status >= 300 {
+		err = fmt.Errorf("The request could not be distributed to all instances. The status code is %d", status)
+	}
+	return err
+}
+
+// publishEvent publishes the outcome of the change together with the hash of the configuration HAProxy runs with
+func publishEvent(e events.Event, err error) {
+	e.Success = err == nil
+	if err != nil {
+		e.Message = err.Error()
+	}
+	e.ConfigHash = getConfigHash()
+	events.Publish(e)
 }
 
 func (m *Serve) setConsulAddresses() {
@@ -12,16 +12,17 @@ import (
 	"strings"
 	"sync"
 
+	"../actions"
 	"../proxy"
 )
 
 var mu = &sync.Mutex{}
 
 type Certer interface {
-	Put(w http.ResponseWriter, req *http.Request) (string, error)
+	Put(w http.ResponseWriter, req *http.Request) (string, actions.ConfigChange, error)
 	PutCert(certName string, certContent []byte) (string, error)
 	GetAll(w http.ResponseWriter, req *http.Request) (CertResponse, error)
-	Init() error
+	Init(authorization string) error
 }
 
 type Cert struct {
@@ -32,9 +33,10 @@ type Cert struct {
 }
 
 type CertResponse struct {
-	Status  string
-	Message string
-	Certs   []Cert
+	Status   string
+	Message  string
+	Certs    []Cert
+	Reloaded bool `json:"reloaded"`
 }
 
 func (m *Cert) GetAll(w http.ResponseWriter, req *http.Request) (CertResponse, error) {
@@ -61,33 +63,52 @@ func (m *Cert) PutCert(certName string, certContent []byte) (string, error) {
 	}
 }
 
-func (m *Cert) Put(w http.ResponseWriter, req *http.Request) (string, error) {
+// Put stores the certificate from the request and reloads the proxy.
+// It returns the path of the certificate and the proxy configuration before and after it was created.
+func (m *Cert) Put(w http.ResponseWriter, req *http.Request) (string, actions.ConfigChange, error) {
 	distribute, _ := strconv.ParseBool(req.URL.Query().Get("distribute"))
 	if distribute {
-		return "", m.sendDistributeRequests(w, req)
+		return "", actions.ConfigChange{}, m.sendDistributeRequests(w, req)
 	}
 	certName, certContent, err := m.getCertFromRequest(w, req)
 	if err != nil {
 		m.writeError(w, err)
-		return "", err
+		return "", actions.ConfigChange{}, err
 	}
 
+	previous, readErr := readCertFile(fmt.Sprintf("%s/%s", m.CertsDir, certName))
+	_, listed := proxy.Instance.GetCerts()[certName]
 	path, err := m.PutCert(certName, certContent)
 	if err != nil {
 		m.writeError(w, err)
-		return "", err
+		return "", actions.ConfigChange{}, err
 	}
 
-	proxy.Instance.CreateConfigFromTemplates()
-	proxy.Instance.Reload()
+	configChange, err := actions.CreateConfig()
+	if err != nil {
+		m.restoreCert(certName, previous, readErr == nil, listed)
+		m.writeInternalServerError(w, err)
+		return "", actions.ConfigChange{}, err
+	}
+	reloaded, err := proxy.Instance.Reload()
+	if err != nil {
+		m.restoreCert(certName, previous, readErr == nil, listed)
+		if _, err := actions.CreateConfig(); err != nil {
+			logPrintf("Could not create the configuration after the certificate %s was restored\n%s", certName, err.Error())
+		}
+		m.writeInternalServerError(w, err)
+		return "", configChange, err
+	}
 
-	msg := CertResponse{Status: "OK", Message: ""}
+	msg := CertResponse{Status: "OK", Message: "", Reloaded: reloaded}
 	m.writeOK(w, msg)
 
-	return path, nil
+	return path, configChange, nil
 }
 
-func (m *Cert) Init() error {
+// Init copies the certificates from the other instances of the proxy service.
+// The authorization is sent with the requests so that the instances accept them when authentication is enabled.
+func (m *Cert) Init(authorization string) error {
 	dns := fmt.Sprintf("tasks.%s", m.ProxyServiceName)
 	client := &http.Client{}
 	if ips, err := lookupHost(dns); err != nil {
@@ -101,8 +122,15 @@ func (m *Cert) Init() error {
 			}
 			addr := fmt.Sprintf("http://%s/v1/docker-flow-proxy/certs", hostPort)
 			req, _ := http.NewRequest("GET", addr, nil)
+			if len(authorization) > 0 {
+				req.Header.Set("Authorization", authorization)
+			}
 			if resp, err := client.Do(req); err == nil {
 				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					logPrintf("Could not get the certificates from %s. The status code is %d", addr, resp.StatusCode)
+					continue
+				}
 				body, _ := ioutil.ReadAll(resp.Body)
 				data := CertResponse{}
 				json.Unmarshal(body, &data)
@@ -116,13 +144,31 @@ func (m *Cert) Init() error {
 				proxy.Instance.AddCert(cert.ProxyServiceName)
 				m.writeFile(cert.ProxyServiceName, []byte(cert.CertContent))
 			}
-			proxy.Instance.CreateConfigFromTemplates()
-			proxy.Instance.Reload()
+			if _, err := actions.CreateConfig(); err != nil {
+				return err
+			}
+			if _, err := proxy.Instance.Reload(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// restoreCert puts back the certificate replaced by a failed request or removes the certificate the request added
+// so that the following configurations do not reference it
+func (m *Cert) restoreCert(certName string, previous []byte, existed, listed bool) {
+	if existed {
+		m.writeFile(certName, previous)
+	} else {
+		removeCertFile(fmt.Sprintf("%s/%s", m.CertsDir, certName))
+	}
+	if !listed {
+		proxy.Instance.RemoveCert(certName)
+	}
+	logPrintf("Restored the certificate %s", certName)
+}
+
 func (m *Cert) getCertFromRequest(w http.ResponseWriter, req *http.Request) (certName string, certContent []byte, err error) {
 	certName = req.URL.Query().Get("certName")
 	if len(certName) == 0 {
@@ -184,6 +230,16 @@ func (m *Cert) writeError(w http.ResponseWriter, err error) error {
 	return err
 }
 
+func (m *Cert) writeInternalServerError(w http.ResponseWriter, err error) {
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	js, _ := json.Marshal(CertResponse{
+		Status:  "NOK",
+		Message: err.Error(),
+	})
+	w.Write(js)
+}
+
 func NewCert(certsDir string) *Cert {
 	return &Cert{
 		CertsDir:         certsDir,
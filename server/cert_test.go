@@ -120,7 +120,7 @@ func (s *ServerTestSuite) Test_Init_InvokesLookupHost() {
 	c := NewCert("../certs")
 	c.ProxyServiceName = s.ServiceName
 
-	c.Init()
+	c.Init("")
 
 	s.Assert().Equal(fmt.Sprintf("tasks.%s", s.ServiceName), actualHost)
 }
@@ -133,7 +133,7 @@ func (s *ServerTestSuite) Test_Init_ReturnsError_WhenLookupHostFails() {
 	}
 	c := NewCert("../certs")
 
-	err := c.Init()
+	err := c.Init("")
 
 	s.Assertions.Error(err)
 }
@@ -160,11 +160,64 @@ func (s *ServerTestSuite) Test_Init_SendsHttpRequestForEachIp() {
 	c := NewCert("../certs")
 	c.ProxyServiceName = s.ServiceName
 
-	c.Init()
+	c.Init("")
 
 	s.Assert().Equal("/v1/docker-flow-proxy/certs", actualPath)
 }
 
+func (s *ServerTestSuite) Test_Init_SendsAuthorization() {
+	actualAuth := ""
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actualAuth = r.Header.Get("Authorization")
+	}))
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	lookupHost = func(host string) (addrs []string, err error) {
+		return []string{tsAddr}, nil
+	}
+	c := NewCert("../certs")
+	c.ProxyServiceName = s.ServiceName
+
+	c.Init("Bearer my-token")
+
+	s.Equal("Bearer my-token", actualAuth)
+}
+
+func (s *ServerTestSuite) Test_Init_IgnoresCerts_WhenStatusIsNotOK() {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		js, _ := json.Marshal(CertResponse{Status: "NOK", Certs: []Cert{{ProxyServiceName: "my-cert.pem"}}})
+		w.Write(js)
+	}))
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	lookupHost = func(host string) (addrs []string, err error) {
+		return []string{tsAddr}, nil
+	}
+	logPrintfOrig := logPrintf
+	defer func() { logPrintf = logPrintfOrig }()
+	logged := ""
+	logPrintf = func(format string, v ...interface{}) {
+		logged = fmt.Sprintf(format, v...)
+	}
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("")
+	proxy.Instance = proxyMock
+	c := NewCert("../certs")
+	c.ProxyServiceName = s.ServiceName
+
+	err := c.Init("")
+
+	s.NoError(err)
+	s.Contains(logged, "401")
+	proxyMock.AssertNotCalled(s.T(), "AddCert", mock.Anything)
+}
+
 func (s *ServerTestSuite) Test_Init_DoesNotFail_WhenRequestFails() {
 	lookupHostOrig := lookupHost
 	defer func() { lookupHost = lookupHostOrig }()
@@ -174,7 +227,7 @@ func (s *ServerTestSuite) Test_Init_DoesNotFail_WhenRequestFails() {
 	c := NewCert("../certs")
 	c.ProxyServiceName = s.ServiceName
 
-	err := c.Init()
+	err := c.Init("")
 
 	s.NoError(err)
 }
@@ -200,7 +253,7 @@ func (s *ServerTestSuite) Test_Init_WritesCertToFile() {
 	proxyMock := getProxyMock("")
 	proxy.Instance = proxyMock
 
-	c.Init()
+	c.Init("")
 
 	actual, err := ioutil.ReadFile(path)
 
@@ -226,7 +279,7 @@ func (s *ServerTestSuite) Test_Init_InvokesProxyAddCert() {
 	proxyMock := getProxyMock("")
 	proxy.Instance = proxyMock
 
-	c.Init()
+	c.Init("")
 
 	proxyMock.AssertCalled(s.T(), "AddCert", "my-cert-2.pem")
 }
@@ -250,7 +303,7 @@ func (s *ServerTestSuite) Test_Init_InvokesProxyCreateConfigFromTemplates() {
 	proxyMock := getProxyMock("")
 	proxy.Instance = proxyMock
 
-	c.Init()
+	c.Init("")
 
 	proxyMock.AssertCalled(s.T(), "CreateConfigFromTemplates")
 }
@@ -274,11 +327,37 @@ func (s *ServerTestSuite) Test_Init_InvokesProxyReload() {
 	proxyMock := getProxyMock("")
 	proxy.Instance = proxyMock
 
-	c.Init()
+	c.Init("")
 
 	proxyMock.AssertCalled(s.T(), "Reload")
 }
 
+func (s *ServerTestSuite) Test_Init_ReturnsError_WhenProxyCreateConfigFromTemplatesFails() {
+	testServer := s.getCertGetAllMockServer(1, 3)
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	ip, port, _ := net.SplitHostPort(tsAddr)
+	lookupHostOrig := lookupHost
+	defer func() { lookupHost = lookupHostOrig }()
+	lookupHost = func(host string) (addrs []string, err error) {
+		hostPort := net.JoinHostPort(ip, port)
+		return []string{hostPort}, nil
+	}
+	c := NewCert("../certs")
+	c.ProxyServiceName = s.ServiceName
+	c.ServicePort = port
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("CreateConfigFromTemplates")
+	proxyMock.On("CreateConfigFromTemplates").Return(fmt.Errorf("This is an error"))
+	proxy.Instance = proxyMock
+
+	err := c.Init("")
+
+	s.Error(err)
+	proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
 func (s *ServerTestSuite) Test_Init_WritesCertToFile_WhenItComesFromTheBiggestResponse() {
 	testServer1 := s.getCertGetAllMockServer(1, 2)
 	testServer2 := s.getCertGetAllMockServer(3, 5)
@@ -308,7 +387,7 @@ func (s *ServerTestSuite) Test_Init_WritesCertToFile_WhenItComesFromTheBiggestRe
 	proxyMock := getProxyMock("")
 	proxy.Instance = proxyMock
 
-	c.Init()
+	c.Init("")
 
 	_, err := ioutil.ReadFile(path2)
 	s.Error(err)
@@ -414,6 +493,29 @@ func (s *CertTestSuite) Test_Put_WritesHeaderStatus200() {
 	w.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
+func (s *CertTestSuite) Test_Put_ReturnsReloaded() {
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("Reload")
+	proxyMock.On("Reload").Return(true, nil)
+	proxy.Instance = proxyMock
+	expected, _ := json.Marshal(CertResponse{
+		Status:   "OK",
+		Reloaded: true,
+	})
+	c := NewCert("../certs")
+	w := getResponseWriterMock()
+	req, _ := http.NewRequest(
+		"PUT",
+		"http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem",
+		strings.NewReader("cert content"),
+	)
+
+	c.Put(w, req)
+
+	w.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
 func (s *CertTestSuite) Test_Put_SendsDistributeRequests_WhenDistruibuteParamIsPresent() {
 	serviceName := "my-proxy-service"
 	serviceNameOrig := os.Getenv("SERVICE_NAME")
@@ -445,7 +547,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenCertNameIsNotPresent() {
 		strings.NewReader("cert content"),
 	)
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -490,7 +592,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenSendDistributeRequestsReturnsE
 	mockObj.On("SendDistributeRequests", mock.Anything, mock.Anything, mock.Anything).Return(200, fmt.Errorf("This is an error"))
 	server = mockObj
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -513,7 +615,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenSendDistributeRequestsReturnsN
 	mockObj.On("SendDistributeRequests", mock.Anything, mock.Anything, mock.Anything).Return(400, nil)
 	server = mockObj
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -527,7 +629,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenDirectoryDoesNotExist() {
 		strings.NewReader("cert content"),
 	)
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -554,7 +656,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenCannotReadBody() {
 	}
 	req, _ := http.NewRequest("PUT", "http://acme.com/v1/docker-flow-proxy/cert?certName=test.pem", r)
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -583,7 +685,7 @@ func (s *CertTestSuite) Test_Put_ReturnsCertPath() {
 		strings.NewReader("cert content"),
 	)
 
-	actual, _ := c.Put(w, req)
+	actual, _, _ := c.Put(w, req)
 
 	s.Equal(expected, actual)
 }
@@ -597,7 +699,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenCertNameDoesNotExist() {
 		strings.NewReader("cert content"),
 	)
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -611,7 +713,7 @@ func (s *CertTestSuite) Test_Put_ReturnsError_WhenBodyIsEmpty() {
 		strings.NewReader(""),
 	)
 
-	_, err := c.Put(w, req)
+	_, _, err := c.Put(w, req)
 
 	s.Error(err)
 }
@@ -648,6 +750,96 @@ func (s *CertTestSuite) Test_Put_InvokesProxyReload() {
 	proxyMock.AssertCalled(s.T(), "Reload")
 }
 
+func (s *CertTestSuite) Test_Put_WritesHeaderStatus500_WhenProxyCreateConfigFromTemplatesFails() {
+	c := NewCert("../certs")
+	w := getResponseWriterMock()
+	req, _ := http.NewRequest(
+		"PUT",
+		"http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem",
+		strings.NewReader("cert content"),
+	)
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("CreateConfigFromTemplates")
+	proxyMock.On("CreateConfigFromTemplates").Return(fmt.Errorf("The certificate is not valid"))
+	proxy.Instance = proxyMock
+	expected, _ := json.Marshal(CertResponse{Status: "NOK", Message: "The certificate is not valid"})
+
+	_, _, err := c.Put(w, req)
+
+	s.Error(err)
+	w.AssertCalled(s.T(), "WriteHeader", 500)
+	w.AssertCalled(s.T(), "Write", expected)
+	proxyMock.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *CertTestSuite) Test_Put_WritesHeaderStatus500_WhenProxyReloadFails() {
+	c := NewCert("../certs")
+	w := getResponseWriterMock()
+	req, _ := http.NewRequest(
+		"PUT",
+		"http://acme.com/v1/docker-flow-proxy/cert?certName=my-cert.pem",
+		strings.NewReader("cert content"),
+	)
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("Reload")
+	proxyMock.On("Reload").Return(false, fmt.Errorf("This is an error"))
+	proxy.Instance = proxyMock
+
+	_, _, err := c.Put(w, req)
+
+	s.Error(err)
+	w.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *CertTestSuite) Test_Put_RestoresPreviousCert_WhenProxyCreateConfigFromTemplatesFails() {
+	c := NewCert("../certs")
+	path := fmt.Sprintf("%s/%s", c.CertsDir, "my-old-cert.pem")
+	ioutil.WriteFile(path, []byte("old cert content"), 0644)
+	defer os.Remove(path)
+	w := getResponseWriterMock()
+	req, _ := http.NewRequest(
+		"PUT",
+		"http://acme.com/v1/docker-flow-proxy/cert?certName=my-old-cert.pem",
+		strings.NewReader("new cert content"),
+	)
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("CreateConfigFromTemplates")
+	proxyMock.On("CreateConfigFromTemplates").Return(fmt.Errorf("The certificate is not valid"))
+	proxy.Instance = proxyMock
+
+	c.Put(w, req)
+
+	actual, _ := ioutil.ReadFile(path)
+	s.Equal("old cert content", string(actual))
+}
+
+func (s *CertTestSuite) Test_Put_RemovesNewCert_WhenProxyReloadFails() {
+	c := NewCert("../certs")
+	path := fmt.Sprintf("%s/%s", c.CertsDir, "my-new-cert.pem")
+	os.Remove(path)
+	w := getResponseWriterMock()
+	req, _ := http.NewRequest(
+		"PUT",
+		"http://acme.com/v1/docker-flow-proxy/cert?certName=my-new-cert.pem",
+		strings.NewReader("cert content"),
+	)
+	proxyOrig := proxy.Instance
+	defer func() { proxy.Instance = proxyOrig }()
+	proxyMock := getProxyMock("Reload")
+	proxyMock.On("Reload").Return(false, fmt.Errorf("This is an error"))
+	proxy.Instance = proxyMock
+
+	c.Put(w, req)
+
+	_, err := os.Stat(path)
+	s.True(os.IsNotExist(err))
+	proxyMock.AssertCalled(s.T(), "RemoveCert", "my-new-cert.pem")
+	proxyMock.AssertNumberOfCalls(s.T(), "CreateConfigFromTemplates", 2)
+}
+
 // NewCert
 
 func (s *CertTestSuite) Test_NewCert_SetsCertsDir() {
@@ -727,20 +919,54 @@ func (m *ProxyMock) ReadConfig() (string, error) {
 	return params.String(0), params.Error(1)
 }
 
-func (m *ProxyMock) Reload() error {
+func (m *ProxyMock) GetConfigDiff(changes proxy.ConfigChanges) (string, error) {
+	params := m.Called(changes)
+	return params.String(0), params.Error(1)
+}
+
+func (m *ProxyMock) Reload() (bool, error) {
 	params := m.Called()
-	return params.Error(0)
+	return params.Bool(0), params.Error(1)
 }
 
 func (m *ProxyMock) AddCert(certName string) {
 	m.Called(certName)
 }
 
+func (m *ProxyMock) RemoveCert(certName string) {
+	m.Called(certName)
+}
+
 func (m *ProxyMock) GetCerts() map[string]string {
 	params := m.Called()
 	return params.Get(0).(map[string]string)
 }
 
+func (m *ProxyMock) SetServerState(backend, server, state string) error {
+	params := m.Called(backend, server, state)
+	return params.Error(0)
+}
+
+func (m *ProxyMock) SetServerWeight(backend, server string, weight int) error {
+	params := m.Called(backend, server, weight)
+	return params.Error(0)
+}
+
+func (m *ProxyMock) GetServerState(backend, server string) (proxy.ServerState, error) {
+	params := m.Called(backend, server)
+	return params.Get(0).(proxy.ServerState), params.Error(1)
+}
+
+func (m *ProxyMock) GetProcessState() proxy.ProcessState {
+	params := m.Called()
+	return params.Get(0).(proxy.ProcessState)
+}
+
+func (m *ProxyMock) GetStats() ([]proxy.Stats, error) {
+	params := m.Called()
+	return params.Get(0).([]proxy.Stats), params.Error(1)
+}
+
 func getProxyMock(skipMethod string) *ProxyMock {
 	mockObj := new(ProxyMock)
 	if skipMethod != "RunCmd" {
@@ -752,14 +978,35 @@ func getProxyMock(skipMethod string) *ProxyMock {
 	if skipMethod != "ReadConfig" {
 		mockObj.On("ReadConfig").Return("", nil)
 	}
+	if skipMethod != "GetConfigDiff" {
+		mockObj.On("GetConfigDiff", mock.Anything).Return("", nil)
+	}
 	if skipMethod != "Reload" {
-		mockObj.On("Reload").Return(nil)
+		mockObj.On("Reload").Return(false, nil)
 	}
 	if skipMethod != "AddCert" {
 		mockObj.On("AddCert", mock.Anything).Return(nil)
 	}
+	if skipMethod != "RemoveCert" {
+		mockObj.On("RemoveCert", mock.Anything).Return(nil)
+	}
 	if skipMethod != "GetCerts" {
 		mockObj.On("GetCerts").Return(map[string]string{})
 	}
+	if skipMethod != "SetServerState" {
+		mockObj.On("SetServerState", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "SetServerWeight" {
+		mockObj.On("SetServerWeight", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	}
+	if skipMethod != "GetServerState" {
+		mockObj.On("GetServerState", mock.Anything, mock.Anything).Return(proxy.ServerState{}, nil)
+	}
+	if skipMethod != "GetProcessState" {
+		mockObj.On("GetProcessState").Return(proxy.ProcessState{Running: true})
+	}
+	if skipMethod != "GetStats" {
+		mockObj.On("GetStats").Return([]proxy.Stats{}, nil)
+	}
 	return mockObj
 }
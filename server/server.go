@@ -22,6 +22,8 @@ func (m *Serve) SendDistributeRequests(req *http.Request, port, proxyServiceName
 	dns := fmt.Sprintf("tasks.%s", proxyServiceName)
 	failedDns := []string{}
 	method := req.Method
+	// Credentials are forwarded so that other instances authorize the request as well
+	auth := req.Header.Get("Authorization")
 	body := ""
 	if req.Body != nil {
 		defer func() { req.Body.Close() }()
@@ -35,6 +37,9 @@ func (m *Serve) SendDistributeRequests(req *http.Request, port, proxyServiceName
 			addr := fmt.Sprintf("http://%s:%s%s?%s", ips[i], port, req.URL.Path, req.URL.RawQuery)
 			logPrintf("Sending distribution request to %s", addr)
 			req, _ := http.NewRequest(method, addr, strings.NewReader(body))
+			if len(auth) > 0 {
+				req.Header.Set("Authorization", auth)
+			}
 			if resp, err := client.Do(req); err != nil || resp.StatusCode >= 300 {
 				failedDns = append(failedDns, ips[i])
 			}
@@ -176,6 +176,28 @@ func (s *ServerTestSuite) Test_SendDistributeRequests_SendsHttpRequestForEachIpW
 	s.Assert().Equal(expectedBody, actualBody)
 }
 
+func (s *ServerTestSuite) Test_SendDistributeRequests_ForwardsAuthorizationHeader() {
+	actualAuth := ""
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		actualAuth = req.Header.Get("Authorization")
+	}))
+	defer func() { testServer.Close() }()
+	tsAddr := strings.Replace(testServer.URL, "http://", "", -1)
+	dnsIpsOrig := s.DnsIps
+	defer func() { s.DnsIps = dnsIpsOrig }()
+	s.DnsIps = []string{strings.Split(tsAddr, ":")[0]}
+	port := strings.Split(tsAddr, ":")[1]
+
+	srv := Serve{}
+	addr := fmt.Sprintf("http://initial-proxy-address:%s%s&distribute=true", port, s.ReconfigureUrl)
+	req, _ := http.NewRequest("PUT", addr, nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	srv.SendDistributeRequests(req, port, s.ServiceName)
+
+	s.Equal("Bearer my-token", actualAuth)
+}
+
 func (s *ServerTestSuite) Test_SendDistributeRequests_ReturnsError_WhenRequestFail() {
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"os"
 )
 
 var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
@@ -11,3 +13,5 @@ var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
 }
 var logPrintf = log.Printf
 var lookupHost = net.LookupHost
+var readCertFile = ioutil.ReadFile
+var removeCertFile = os.Remove
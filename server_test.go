@@ -3,15 +3,19 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 
 	"./actions"
+	"./events"
 	haproxy "./proxy"
 	"./server"
 	"github.com/stretchr/testify/mock"
@@ -115,6 +119,38 @@ func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenHTTPListenAndServeFails(
 	s.Error(actual)
 }
 
+func (s *ServerTestSuite) Test_Execute_LoadsAuthTokens_WhenAuthTokensPathIsSet() {
+	readFileOrig := readFile
+	defer func() {
+		readFile = readFileOrig
+		authTokens = nil
+	}()
+	readFile = func(path string) ([]byte, error) {
+		return []byte(`[{"name": "reader", "token": "my-token", "scopes": ["read"]}]`), nil
+	}
+	srv := serverImpl
+	srv.AuthTokensPath = "/run/secrets/my-tokens"
+
+	err := srv.Execute([]string{})
+
+	s.NoError(err)
+	s.Equal([]AuthToken{{Name: "reader", Token: "my-token", Scopes: []string{SCOPE_READ}}}, authTokens)
+}
+
+func (s *ServerTestSuite) Test_Execute_ReturnsError_WhenAuthTokensCannotBeLoaded() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(path string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	srv := serverImpl
+	srv.AuthTokensPath = "/run/secrets/my-tokens"
+
+	err := srv.Execute([]string{})
+
+	s.Error(err)
+}
+
 func (s *ServerTestSuite) Test_Execute_InvokesRunExecute() {
 	orig := NewRun
 	defer func() {
@@ -136,7 +172,7 @@ func (s *ServerTestSuite) Test_Execute_InvokesCertInit() {
 	certOrig := cert
 	defer func() { cert = certOrig }()
 	cert = CertMock{
-		GetInitMock: func() error {
+		GetInitMock: func(authorization string) error {
 			invoked = true
 			return nil
 		},
@@ -147,6 +183,32 @@ func (s *ServerTestSuite) Test_Execute_InvokesCertInit() {
 	s.True(invoked)
 }
 
+func (s *ServerTestSuite) Test_Execute_InvokesCertInitWithCertsCredential_WhenAuthTokensAreLoaded() {
+	readFileOrig := readFile
+	certOrig := cert
+	defer func() {
+		readFile = readFileOrig
+		cert = certOrig
+		authTokens = nil
+	}()
+	readFile = func(path string) ([]byte, error) {
+		return []byte(`[{"name": "reader", "token": "read-token", "scopes": ["read"]}, {"name": "certs", "token": "certs-token", "scopes": ["certs"]}]`), nil
+	}
+	actual := ""
+	cert = CertMock{
+		GetInitMock: func(authorization string) error {
+			actual = authorization
+			return nil
+		},
+	}
+	srv := serverImpl
+	srv.AuthTokensPath = "/run/secrets/my-tokens"
+
+	srv.Execute([]string{})
+
+	s.Equal("Bearer certs-token", actual)
+}
+
 func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServices() {
 	mockObj := getReconfigureMock("")
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
@@ -292,6 +354,9 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404WhenURLIsUnknown() {
 }
 
 func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus200WhenUrlIsTest() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = getProxyMock("")
 	for ver := 1; ver <= 2; ver++ {
 		rw := getResponseWriterMock()
 		req, _ := http.NewRequest("GET", fmt.Sprintf("/v%d/test", ver), nil)
@@ -310,9 +375,9 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesCertPut_WhenUrlIsCert() {
 	certOrig := cert
 	defer func() { cert = certOrig }()
 	cert = CertMock{
-		PutMock: func(http.ResponseWriter, *http.Request) (string, error) {
+		PutMock: func(http.ResponseWriter, *http.Request) (string, actions.ConfigChange, error) {
 			invoked = true
-			return "", nil
+			return "", actions.ConfigChange{}, nil
 		},
 	}
 	req, _ := http.NewRequest("PUT", s.CertUrl, nil)
@@ -328,9 +393,9 @@ func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvoke_WhenUrlIsCertAndMethodIsN
 	certOrig := cert
 	defer func() { cert = certOrig }()
 	cert = CertMock{
-		PutMock: func(http.ResponseWriter, *http.Request) (string, error) {
+		PutMock: func(http.ResponseWriter, *http.Request) (string, actions.ConfigChange, error) {
 			invoked = true
-			return "", nil
+			return "", actions.ConfigChange{}, nil
 		},
 	}
 	req, _ := http.NewRequest("GET", s.CertUrl, nil)
@@ -370,6 +435,41 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesCertGetAll_WhenUrlIsCerts() {
 	s.Assert().True(invoked)
 }
 
+// ServeHTTP > Services
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenUrlIsServicesAndListenerAddressIsNotSet() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/services", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsServicesJSON_WhenUrlIsV2Services() {
+	var actualContentType string
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		actualContentType = value
+	}
+	actions.PutService(actions.ServiceReconfigure{
+		ServiceName: "my-service",
+		ServicePath: []string{"/my/path"},
+		Port:        "1234",
+		Users:       []actions.User{{Username: "my-user", Password: "my-pass"}},
+	})
+	defer actions.RemoveService("my-service")
+	expected, _ := json.Marshal(actions.GetServices())
+	req, _ := http.NewRequest("GET", "/v2/docker-flow-proxy/services", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("application/json", actualContentType)
+	s.NotContains(string(expected), "my-pass")
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", expected)
+}
+
 // ServeHTTP > Reconfigure
 
 func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToJSON_WhenUrlIsReconfigure() {
@@ -472,10 +572,10 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithTemplatePaths_WhenPresen
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithUsers_WhenPresent() {
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithRedactedUsers_WhenPresent() {
 	users := []actions.User{
-		{Username: "user1", Password: "pass1"},
-		{Username: "user2", Password: "pass2"},
+		{Username: "user1", Password: "*****"},
+		{Username: "user2", Password: "*****"},
 	}
 	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&users=user1:pass1,user2:pass2", nil)
 	expected, _ := json.Marshal(Response{
@@ -494,6 +594,31 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithUsers_WhenPresent() {
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithRedactedCert_WhenPresent() {
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			return "", nil
+		},
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&serviceCert=my-private-key", nil)
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		ServiceCert:      "*****",
+		OutboundHostname: s.OutboundHostname,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
 func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithPorts_WhenPresent() {
 	port := "1234"
 	httpsPort := 4321
@@ -542,6 +667,127 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJsonWithSkipCheck_WhenPresent()
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenReconfigureBodyIsJSON() {
+	users := []actions.User{
+		{Username: "user1", Password: "*****"},
+		{Username: "user2", Password: "*****"},
+	}
+	body := `{
+		"serviceName": "myService",
+		"serviceColor": "pink",
+		"servicePath": ["/path/to/my/service/api", "/path/to/my/other/service/api"],
+		"serviceDomain": ["my-domain.com"],
+		"outboundHostname": "machine-123.my-company.com",
+		"users": [{"username": "user1", "password": "pass1"}, {"username": "user2", "password": "pass2"}]
+	}`
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		OutboundHostname: s.OutboundHostname,
+		Users:            users,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenBodyIsJSON() {
+	s.ServiceReconfigure.ReqRepSearch = `^([^\ ]*)\ /something/(.*)`
+	defer func() { s.ServiceReconfigure.ReqRepSearch = "" }()
+	sr := s.ServiceReconfigure
+	sr.Port = ""
+	js, _ := json.Marshal(sr)
+	req, _ := http.NewRequest("PUT", s.ReconfigureBaseUrl, strings.NewReader(string(js)))
+
+	s.invokesReconfigure(req, true)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenReconfigureBodyIsMalformed() {
+	body := `{"serviceName": "myService"`
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+	jsonErr := json.Unmarshal([]byte(body), &actions.ServiceReconfigure{})
+	expected, _ := json.Marshal(Response{
+		Status:  "NOK",
+		Message: fmt.Sprintf("Could not parse the request body as JSON\n%s", jsonErr.Error()),
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsDiff_WhenReconfigureDryRunIsTrue() {
+	mockObj := getReconfigureMock("GetDiff")
+	mockObj.On("GetDiff").Return("this is a diff", nil)
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&dryRun=true", nil)
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		OutboundHostname: s.OutboundHostname,
+		DryRun:           true,
+		Diff:             "this is a diff",
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsReloaded_WhenProxyIsReloaded() {
+	mockObj := getReconfigureMock("IsReloaded")
+	mockObj.On("IsReloaded").Return(true)
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	expected, _ := json.Marshal(Response{
+		Status:           "OK",
+		ServiceName:      s.ServiceName,
+		ServiceColor:     s.ServiceColor,
+		ServicePath:      s.ServicePath,
+		ServiceDomain:    s.ServiceDomain,
+		OutboundHostname: s.OutboundHostname,
+		Reloaded:         true,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	s.Contains(string(expected), `"reloaded":true`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureDryRunFails() {
+	mockObj := getReconfigureMock("GetDiff")
+	mockObj.On("GetDiff").Return("", fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&dryRun=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
 func (s *ServerTestSuite) Test_ServeHTTP_WritesErrorHeader_WhenReconfigureDistributeIsTrueAndError() {
 	serve := Serve{}
 	serve.Port = s.Port
@@ -601,113 +847,419 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenModeIsSwarmAndPort
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute() {
-	s.ServiceReconfigure.AclName = "my-acl"
-	url := fmt.Sprintf("%s&aclName=my-acl", s.ReconfigureUrl)
-	req, _ := http.NewRequest("GET", url, nil)
-	//	s.RequestReconfigure.u
-	s.invokesReconfigure(req, true)
-}
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsFieldErrors_WhenReconfigureQueriesCannotBeParsed() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&users=user-without-password&httpsPort=https&skipCheck=maybe", nil)
 
-func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenDistributeIsTrue() {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s&distribute=true", s.ReconfigureUrl),
-		nil,
-	)
-	s.invokesReconfigure(req, false)
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 400)
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+	response := Response{}
+	json.Unmarshal([]byte(actual), &response)
+	fields := []string{}
+	for _, e := range response.Errors {
+		fields = append(fields, e.Field)
+	}
+	s.Equal([]string{"httpsPort", "skipCheck", "users"}, fields)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemoveExecute_WhenDistributeIsTrue() {
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("%s&distribute=true", s.RemoveUrl),
-		nil,
-	)
-	s.invokesReconfigure(req, false)
-}
+func (s *ServerTestSuite) Test_ServeHTTP_PassesHealthCheckToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	address := s.ReconfigureUrl + "&healthCheckPath=/health&healthCheckMethod=HEAD&healthCheckStatus=204" +
+		"&healthCheckInterval=2000&healthCheckRise=2&healthCheckFall=3&healthCheckPort=8081"
+	req, _ := http.NewRequest("GET", address, nil)
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureExecuteFails() {
-	mockObj := getReconfigureMock("Execute")
-	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal("/health", actualService.HealthCheckPath)
+	s.Equal("HEAD", actualService.HealthCheckMethod)
+	s.Equal(204, actualService.HealthCheckStatus)
+	s.Equal(2000, actualService.HealthCheckInterval)
+	s.Equal(2, actualService.HealthCheckRise)
+	s.Equal(3, actualService.HealthCheckFall)
+	s.Equal(8081, actualService.HealthCheckPort)
+	response := Response{}
+	json.Unmarshal([]byte(actual), &response)
+	s.Equal("/health", response.HealthCheckPath)
+	s.Equal(8081, response.HealthCheckPort)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PassesTimeoutsAndConnectionLimitsToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
 		return mockObj
 	}
+	address := s.ReconfigureUrl + "&timeoutServer=300&timeoutConnect=2&timeoutQueue=10&timeoutTunnel=3600&maxConn=50&fullConn=500"
+	req, _ := http.NewRequest("GET", address, nil)
 
 	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.Equal(300, actualService.TimeoutServer)
+	s.Equal(2, actualService.TimeoutConnect)
+	s.Equal(10, actualService.TimeoutQueue)
+	s.Equal(3600, actualService.TimeoutTunnel)
+	s.Equal(50, actualService.MaxConn)
+	s.Equal(500, actualService.FullConn)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJson_WhenConsulTemplatePathIsPresent() {
-	pathFe := "/path/to/consul/fe/template"
-	pathBe := "/path/to/consul/fe/template"
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		pathFe,
-		pathBe)
-	req, _ := http.NewRequest("GET", address, nil)
-	expected, _ := json.Marshal(Response{
-		Status:               "OK",
-		ServiceName:          s.ServiceName,
-		ConsulTemplateFePath: pathFe,
-		ConsulTemplateBePath: pathBe,
-		PathType:             s.PathType,
-	})
+func (s *ServerTestSuite) Test_ServeHTTP_PassesBalanceAndStickySessionsToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&balance=leastconn&stickySessions=true", nil)
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	s.Equal("leastconn", actualService.Balance)
+	s.True(actualService.StickySessions)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenConsulTemplatePathIsPresent() {
-	pathFe := "/path/to/consul/fe/template"
-	pathBe := "/path/to/consul/be/template"
+func (s *ServerTestSuite) Test_ServeHTTP_PassesTaskRoutingToReconfigure() {
 	mockObj := getReconfigureMock("")
-	var actualBase actions.BaseReconfigure
-	expectedBase := actions.BaseReconfigure{
-		ConsulAddresses: []string{s.ConsulAddress},
-	}
-	expectedService := actions.ServiceReconfigure{
-		ServiceName:          s.ServiceName,
-		ConsulTemplateFePath: pathFe,
-		ConsulTemplateBePath: pathBe,
-		PathType:             s.PathType,
-	}
 	var actualService actions.ServiceReconfigure
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
-		actualBase = baseData
 		actualService = serviceData
 		return mockObj
 	}
-	serverImpl := Serve{BaseReconfigure: expectedBase}
-	address := fmt.Sprintf(
-		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
-		s.ReconfigureBaseUrl,
-		s.ServiceName,
-		pathFe,
-		pathBe)
+	address := fmt.Sprintf("%s?serviceName=%s&servicePath=%s&taskRouting=true&maxTasks=20", s.ReconfigureBaseUrl, s.ServiceName, s.ServicePath[0])
 	req, _ := http.NewRequest("GET", address, nil)
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.Equal(expectedBase, actualBase)
-	s.Equal(expectedService, actualService)
-	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.True(actualService.TaskRouting)
+	s.Equal(20, actualService.MaxTasks)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent() {
-	actualCertName := ""
-	expectedCert := "my-cert with new line \\n"
-	actualCert := ""
-	certOrig := cert
-	defer func() { cert = certOrig }()
-	cert = CertMock{
-		PutCertMock: func(certName string, certContent []byte) (string, error) {
+func (s *ServerTestSuite) Test_ServeHTTP_PassesColorWeightsToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	address := fmt.Sprintf("%s?serviceName=%s&servicePath=%s&colorWeights=blue:90,green:10", s.ReconfigureBaseUrl, s.ServiceName, s.ServicePath[0])
+	req, _ := http.NewRequest("GET", address, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal([]actions.ColorWeight{{Color: "blue", Weight: 90}, {Color: "green", Weight: 10}}, actualService.ColorWeights)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PassesMatchesToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&servicePath=%s&headerMatches=X-Canary:true,X-Api-Version~^2&cookieMatches=tester:yes&queryMatches=beta:1&methods=get,POST",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		s.ServicePath[0],
+	)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal([]actions.RouteMatch{{Name: "X-Canary", Value: "true"}, {Name: "X-Api-Version", Value: "^2", Regexp: true}}, actualService.HeaderMatches)
+	s.Equal([]actions.RouteMatch{{Name: "tester", Value: "yes"}}, actualService.CookieMatches)
+	s.Equal([]actions.RouteMatch{{Name: "beta", Value: "1"}}, actualService.QueryMatches)
+	s.Equal([]string{"GET", "POST"}, actualService.Methods)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenMatchesAreNotValid() {
+	for _, query := range []string{"headerMatches=X-Canary", "cookieMatches=:yes", "queryMatches=beta:a<b", "methods=FETCH"} {
+		rw := getResponseWriterMock()
+		address := fmt.Sprintf("%s?serviceName=%s&servicePath=%s&%s", s.ReconfigureBaseUrl, s.ServiceName, s.ServicePath[0], query)
+		req, _ := http.NewRequest("GET", address, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PassesPriorityToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	address := fmt.Sprintf("%s?serviceName=%s&servicePath=%s&priority=-10", s.ReconfigureBaseUrl, s.ServiceName, s.ServicePath[0])
+	req, _ := http.NewRequest("GET", address, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(-10, actualService.Priority)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenRoutesConflictWithAnotherService() {
+	defer actions.RemoveService("other-service")
+	actions.PutService(actions.ServiceReconfigure{ServiceName: "other-service", ServicePath: []string{s.ServicePath[0]}})
+	invoked := false
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		invoked = true
+		return getReconfigureMock("")
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	address := fmt.Sprintf("%s?serviceName=%s&servicePath=%s", s.ReconfigureBaseUrl, s.ServiceName, s.ServicePath[0])
+	req, _ := http.NewRequest("GET", address, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 400)
+	s.Contains(actual, "other-service")
+	s.False(invoked)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenColorWeightsAreNotValid() {
+	address := fmt.Sprintf("%s?serviceName=%s&servicePath=%s&colorWeights=blue:ninety", s.ReconfigureBaseUrl, s.ServiceName, s.ServicePath[0])
+	req, _ := http.NewRequest("GET", address, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PassesLookupRetryToReconfigure() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+
+	srv := Serve{LookupRetry: 5, LookupRetryInterval: 250}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(5, actualService.LookupRetry)
+	s.Equal(250, actualService.LookupRetryInterval)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsFieldErrors_WhenHealthCheckQueriesAreNotNumbers() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&healthCheckInterval=2s&healthCheckPort=http", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 400)
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+	response := Response{}
+	json.Unmarshal([]byte(actual), &response)
+	fields := []string{}
+	for _, e := range response.Errors {
+		fields = append(fields, e.Field)
+	}
+	s.Equal([]string{"healthCheckInterval", "healthCheckPort"}, fields)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_MarksPasswordsAsEncrypted_WhenUsersPassEncryptedIsTrue() {
+	mockObj := getReconfigureMock("")
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualService = serviceData
+		return mockObj
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&users=my-user:$6$salt$hash&usersPassEncrypted=true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Equal([]actions.User{{Username: "my-user", Password: "$6$salt$hash", PassEncrypted: true}}, actualService.Users)
+	s.NotContains(actual, "$6$salt$hash")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsFieldError_WhenUsersSecretCannotBeLoaded() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl+"&usersSecret=../dfp_auth_tokens", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 400)
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+	response := Response{}
+	json.Unmarshal([]byte(actual), &response)
+	s.Len(response.Errors, 1)
+	s.Equal("usersSecret", response.Errors[0].Field)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsFieldErrors_WhenReconfigureFieldsAreNotValid() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+	body := `{"ServiceName": "my-service", "ServicePath": ["/my/path"], "PathType": "path_beg if TRUE", "ServiceDomain": ["my domain.com"]}`
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("POST", s.ReconfigureBaseUrl, strings.NewReader(body))
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 400)
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+	response := Response{}
+	json.Unmarshal([]byte(actual), &response)
+	s.Equal("NOK", response.Status)
+	s.Len(response.Errors, 2)
+	s.Equal("pathType", response.Errors[0].Field)
+	s.Equal("serviceDomain", response.Errors[1].Field)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute() {
+	s.ServiceReconfigure.AclName = "my-acl"
+	url := fmt.Sprintf("%s&aclName=my-acl", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", url, nil)
+	//	s.RequestReconfigure.u
+	s.invokesReconfigure(req, true)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeReconfigureExecute_WhenDistributeIsTrue() {
+	req, _ := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s&distribute=true", s.ReconfigureUrl),
+		nil,
+	)
+	s.invokesReconfigure(req, false)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_DoesNotInvokeRemoveExecute_WhenDistributeIsTrue() {
+	req, _ := http.NewRequest(
+		"GET",
+		fmt.Sprintf("%s&distribute=true", s.RemoveUrl),
+		nil,
+	)
+	s.invokesReconfigure(req, false)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReconfigureExecuteFails() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", []string{}).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJson_WhenConsulTemplatePathIsPresent() {
+	pathFe := "/path/to/consul/fe/template"
+	pathBe := "/path/to/consul/fe/template"
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		pathFe,
+		pathBe)
+	req, _ := http.NewRequest("GET", address, nil)
+	expected, _ := json.Marshal(Response{
+		Status:               "OK",
+		ServiceName:          s.ServiceName,
+		ConsulTemplateFePath: pathFe,
+		ConsulTemplateBePath: pathBe,
+		PathType:             s.PathType,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureExecute_WhenConsulTemplatePathIsPresent() {
+	pathFe := "/path/to/consul/fe/template"
+	pathBe := "/path/to/consul/be/template"
+	mockObj := getReconfigureMock("")
+	var actualBase actions.BaseReconfigure
+	expectedBase := actions.BaseReconfigure{
+		ConsulAddresses: []string{s.ConsulAddress},
+	}
+	expectedService := actions.ServiceReconfigure{
+		ServiceName:          s.ServiceName,
+		ConsulTemplateFePath: pathFe,
+		ConsulTemplateBePath: pathBe,
+		PathType:             s.PathType,
+	}
+	var actualService actions.ServiceReconfigure
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		actualBase = baseData
+		actualService = serviceData
+		return mockObj
+	}
+	serverImpl := Serve{BaseReconfigure: expectedBase}
+	address := fmt.Sprintf(
+		"%s?serviceName=%s&consulTemplateFePath=%s&consulTemplateBePath=%s",
+		s.ReconfigureBaseUrl,
+		s.ServiceName,
+		pathFe,
+		pathBe)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(expectedBase, actualBase)
+	s.Equal(expectedService, actualService)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent() {
+	actualCertName := ""
+	expectedCert := "my-cert with new line \\n"
+	actualCert := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
 			actualCertName = certName
 			actualCert = string(certContent[:])
 			return "", nil
@@ -722,63 +1274,792 @@ func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCert_WhenServiceCertIsPresent
 	)
 	req, _ := http.NewRequest("GET", address, nil)
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(s.ServiceName, actualCertName)
+	s.Equal(strings.Replace(expectedCert, "\\n", "\n", -1), actualCert)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithDomainName_WhenServiceCertIsPresent() {
+	actualCertName := ""
+	expectedCert := "my-cert"
+	actualCert := ""
+	certOrig := cert
+	defer func() { cert = certOrig }()
+	cert = CertMock{
+		PutCertMock: func(certName string, certContent []byte) (string, error) {
+			actualCertName = certName
+			actualCert = string(certContent[:])
+			return "", nil
+		},
+	}
+	address := fmt.Sprintf("%s&serviceDomain=%s&serviceCert=%s", s.ReconfigureUrl, s.ServiceDomain[0], expectedCert)
+	req, _ := http.NewRequest("GET", address, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(s.ServiceDomain[0], actualCertName)
+	s.Equal(expectedCert, actualCert)
+}
+
+// ServeHTTP > Remove
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToJSON_WhenUrlIsRemove() {
+	var actual string
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		actual = value
+	}
+	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("application/json", actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenUrlIsRemove() {
+	expected, _ := json.Marshal(Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+	})
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestRemove)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsRemoveAndServiceNameQueryIsNotPresent() {
+	req, _ := http.NewRequest("GET", s.RemoveBaseUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveExecute() {
+	mockObj := getRemoveMock("")
+	aclName := "my-acl"
+	var actual Remove
+	expected := Remove{
+		ServiceName:     s.ServiceName,
+		TemplatesPath:   "",
+		ConfigsPath:     "",
+		ConsulAddresses: []string{s.ConsulAddress},
+		InstanceName:    s.InstanceName,
+		AclName:         aclName,
+	}
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		actual = Remove{
+			ServiceName:     serviceName,
+			AclName:         aclName,
+			TemplatesPath:   templatesPath,
+			ConfigsPath:     configsPath,
+			ConsulAddresses: consulAddresses,
+			InstanceName:    instanceName,
+			Mode:            mode,
+		}
+		return mockObj
+	}
+	url := fmt.Sprintf("%s?serviceName=%s&aclName=%s", s.RemoveBaseUrl, s.ServiceName, aclName)
+	req, _ := http.NewRequest("GET", url, nil)
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(expected, actual)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenRemoveExecuteFails() {
+	mockObj := getRemoveMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("The configuration is not valid"))
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+	expected, _ := json.Marshal(Response{
+		Status:      "NOK",
+		Message:     "The configuration is not valid",
+		ServiceName: s.ServiceName,
+	})
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsDiff_WhenRemoveDryRunIsTrue() {
+	mockObj := getRemoveMock("GetDiff")
+	mockObj.On("GetDiff").Return("this is a diff", nil)
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.RemoveUrl+"&dryRun=true&distribute=true", nil)
+	expected, _ := json.Marshal(Response{
+		Status:      "OK",
+		ServiceName: s.ServiceName,
+		DryRun:      true,
+		Diff:        "this is a diff",
+	})
+
+	serverImpl.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	mockObj.AssertNotCalled(s.T(), "Execute", mock.Anything)
+}
+
+// ServeHTTP > Config
+
+func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToText_WhenUrlIsConfig() {
+	var actual string
+	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+		actual = value
+	}
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal("text/html", actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConfig_WhenUrlIsConfig() {
+	expected := "some text"
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte(expected), nil
+	}
+
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+// ServeHTTP > Explain
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsMatchingBackend_WhenUrlIsExplain() {
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte(`frontend services
+    acl url_go-demo path_beg /demo
+    acl domain_go-demo hdr_dom(host) -i my-domain.com
+    acl header_go-demo_1 req.hdr(X-Canary) -m str true
+    use_backend go-demo-be if url_go-demo domain_go-demo header_go-demo_1
+
+backend go-demo-be
+    server go-demo go-demo:8080`), nil
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/explain?host=my-domain.com&path=/demo/hello&header=X-Canary:true", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	response := ExplainResponse{}
+	json.Unmarshal([]byte(actual), &response)
+	s.Equal("OK", response.Status)
+	s.Equal("go-demo-be", response.Backend)
+	s.Equal([]string{"server go-demo go-demo:8080"}, response.Servers)
+	s.Len(response.Rules, 1)
+	s.Equal(80, response.Port)
+	s.Equal("GET", response.Method)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsMessage_WhenExplainDoesNotMatch() {
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte(`frontend services
+    acl url_go-demo path_beg /demo
+    use_backend go-demo-be if url_go-demo`), nil
+	}
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/explain?path=/other", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	response := ExplainResponse{}
+	json.Unmarshal([]byte(actual), &response)
+	s.Empty(response.Backend)
+	s.NotEmpty(response.Message)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenExplainQueryIsNotValid() {
+	for _, query := range []string{"host=my-domain.com", "path=/demo&port=https", "path=/demo&header=X-Canary"} {
+		rw := getResponseWriterMock()
+		req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/explain?"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenExplainCannotReadConfig() {
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/explain?path=/demo", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenExplainMethodIsNotGet() {
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-proxy/explain?path=/demo", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 404)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_RedactsPasswords_WhenUrlIsConfig() {
+	readFileOrig := haproxy.ReadFile
+	defer func() { haproxy.ReadFile = readFileOrig }()
+	haproxy.ReadFile = func(filename string) ([]byte, error) {
+		return []byte("userlist defaultUsers\n    user my-user insecure-password my-pass\n"), nil
+	}
+
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte("userlist defaultUsers\n    user my-user insecure-password *****\n"))
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReadFileFails() {
+	readFileOrig := readFile
+	defer func() { readFile = readFileOrig }()
+	readFile = func(filename string) ([]byte, error) {
+		return []byte(""), fmt.Errorf("This is an error")
+	}
+
+	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+// ServeHTTP > History
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsVersionSummaries_WhenUrlIsHistory() {
+	dir, _ := ioutil.TempDir("", "history")
+	defer func() {
+		os.RemoveAll(dir)
+		actions.SetHistory("", 0)
+	}()
+	versions := []actions.ConfigVersion{
+		{Version: 1, ServiceName: "service-1", Action: actions.HistoryActionReconfigure, Config: "config-1"},
+		{Version: 2, ServiceName: "service-2", Action: actions.HistoryActionRemove, Config: "config-2"},
+	}
+	js, _ := json.Marshal(versions)
+	ioutil.WriteFile(dir+"/history.json", js, 0664)
+	actions.SetHistory(dir+"/history.json", 10)
+	expected, _ := json.Marshal(HistoryResponse{Status: "OK", Versions: []actions.ConfigVersion{versions[0].Summary(), versions[1].Summary()}})
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/history", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	s.Equal(string(expected), actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsRedactedVersion_WhenHistoryVersionIsSet() {
+	dir, _ := ioutil.TempDir("", "history")
+	defer func() {
+		os.RemoveAll(dir)
+		actions.SetHistory("", 0)
+	}()
+	version := actions.ConfigVersion{
+		Version:  1,
+		Config:   "config-1",
+		Services: []actions.ServiceReconfigure{{ServiceName: "service-1", Users: []actions.User{{Username: "my-user", Password: "my-pass"}}}},
+	}
+	js, _ := json.Marshal([]actions.ConfigVersion{version})
+	ioutil.WriteFile(dir+"/history.json", js, 0664)
+	actions.SetHistory(dir+"/history.json", 10)
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/history?version=1", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	s.Contains(actual, `"Config":"config-1"`)
+	s.NotContains(actual, "my-pass")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenHistoryVersionDoesNotExist() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/history?version=7", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 404)
+}
+
+// ServeHTTP > Rollback
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesRollbackExecute_WhenUrlIsRollback() {
+	mockObj := getReconfigureMock("IsReloaded")
+	mockObj.On("IsReloaded").Return(true)
+	var actualVersion int
+	var actualMode string
+	rollbackOrig := actions.NewRollback
+	defer func() { actions.NewRollback = rollbackOrig }()
+	actions.NewRollback = func(baseData actions.BaseReconfigure, mode string, version int) actions.Rollbackable {
+		actualMode = mode
+		actualVersion = version
+		return mockObj
+	}
+	expected, _ := json.Marshal(RollbackResponse{Status: "OK", Version: 3, Reloaded: true})
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-proxy/rollback?version=3", nil)
+
+	srv := Serve{Mode: "swarm"}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Equal(3, actualVersion)
+	s.Equal("swarm", actualMode)
+	s.Equal(string(expected), actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenRollbackFails() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+	rollbackOrig := actions.NewRollback
+	defer func() { actions.NewRollback = rollbackOrig }()
+	actions.NewRollback = func(baseData actions.BaseReconfigure, mode string, version int) actions.Rollbackable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-proxy/rollback?version=3", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenRollbackVersionIsNotANumber() {
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-proxy/rollback?version=latest", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenRollbackMethodIsNotPost() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/rollback?version=1", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 404)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenRollbackTokenIsLimitedToServicePrefixes() {
+	defer func() { authTokens = nil }()
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"team-a-"}}}
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-proxy/rollback?version=1", nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 403)
+}
+
+// ServeHTTP > Canary
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesCanaryExecute_WhenUrlIsCanary() {
+	weights := []actions.ColorWeight{{Color: "blue", Weight: 70}, {Color: "green", Weight: 30}}
+	mockObj := getReconfigureMock("GetColorWeights")
+	mockObj.On("GetColorWeights").Return(weights)
+	var actualChange actions.CanaryChange
+	canaryOrig := actions.NewCanary
+	defer func() { actions.NewCanary = canaryOrig }()
+	actions.NewCanary = func(baseData actions.BaseReconfigure, change actions.CanaryChange) actions.Canaryable {
+		actualChange = change
+		return mockObj
+	}
+	expected, _ := json.Marshal(CanaryResponse{Status: "OK", ServiceName: "my-service", ColorWeights: weights})
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/canary?serviceName=my-service&color=green&step=20", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Equal(actions.CanaryChange{ServiceName: "my-service", Color: "green", Step: 20}, actualChange)
+	s.Equal(string(expected), actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PassesColorWeightsAndPromoteToCanary() {
+	var actualChanges []actions.CanaryChange
+	canaryOrig := actions.NewCanary
+	defer func() { actions.NewCanary = canaryOrig }()
+	actions.NewCanary = func(baseData actions.BaseReconfigure, change actions.CanaryChange) actions.Canaryable {
+		actualChanges = append(actualChanges, change)
+		return getReconfigureMock("")
+	}
+
+	srv := Serve{}
+	for _, query := range []string{"colorWeights=blue:50,green:50", "promote=green"} {
+		req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/canary?serviceName=my-service&"+query, nil)
+		srv.ServeHTTP(s.ResponseWriter, req)
+	}
+
+	s.Equal([]actions.CanaryChange{
+		{ServiceName: "my-service", ColorWeights: []actions.ColorWeight{{Color: "blue", Weight: 50}, {Color: "green", Weight: 50}}},
+		{ServiceName: "my-service", Promote: "green"},
+	}, actualChanges)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenCanaryFails() {
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+	canaryOrig := actions.NewCanary
+	defer func() { actions.NewCanary = canaryOrig }()
+	actions.NewCanary = func(baseData actions.BaseReconfigure, change actions.CanaryChange) actions.Canaryable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/canary?serviceName=my-service&promote=green", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenCanaryQueryIsNotValid() {
+	for _, query := range []string{
+		"promote=green",
+		"serviceName=my-service",
+		"serviceName=my-service&color=green",
+		"serviceName=my-service&colorWeights=blue",
+	} {
+		rw := getResponseWriterMock()
+		req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/canary?"+query, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(rw, req)
+
+		rw.AssertCalled(s.T(), "WriteHeader", 400)
+	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus404_WhenCanaryMethodIsNotPut() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/canary?serviceName=my-service&promote=green", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 404)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenCanaryTokenIsLimitedToServicePrefixes() {
+	defer func() { authTokens = nil }()
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"team-a-"}}}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/canary?serviceName=my-service&promote=green", nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 403)
+}
+
+// ServeHTTP > Audit
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesAuditRecord_WhenUrlIsReconfigure() {
+	dir, _ := ioutil.TempDir("", "audit")
+	defer func() {
+		os.RemoveAll(dir)
+		auditPath = ""
+	}()
+	auditPath = dir + "/audit.jsonl"
+	mockObj := getReconfigureMock("GetConfigChange")
+	mockObj.On("GetConfigChange").Return(actions.ConfigChange{
+		Before: "frontend services\n",
+		After:  "frontend services\n    use_backend my-service-be\n",
+	})
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
+	}
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	records, _ := readAuditRecords(AuditFilter{})
+	s.Len(records, 1)
+	s.Equal("reconfigure", records[0].Endpoint)
+	s.Equal(s.ServiceName, records[0].ServiceName)
+	s.Equal(200, records[0].Status)
+	s.True(records[0].Success)
+	s.Contains(records[0].Diff, "+    use_backend my-service-be")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesFailedAuditRecord_WhenRemoveFails() {
+	dir, _ := ioutil.TempDir("", "audit")
+	defer func() {
+		os.RemoveAll(dir)
+		auditPath = ""
+	}()
+	auditPath = dir + "/audit.jsonl"
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = getProxyMock("")
+	req, _ := http.NewRequest("GET", s.RemoveBaseUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	records, _ := readAuditRecords(AuditFilter{})
+	s.Len(records, 1)
+	s.Equal("remove", records[0].Endpoint)
+	s.Equal(400, records[0].Status)
+	s.False(records[0].Success)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_WritesFailedAuditRecord_WhenRemoveExecuteFails() {
+	dir, _ := ioutil.TempDir("", "audit")
+	defer func() {
+		os.RemoveAll(dir)
+		auditPath = ""
+	}()
+	auditPath = dir + "/audit.jsonl"
+	mockObj := getRemoveMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("The configuration is not valid"))
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}
+	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	records, _ := readAuditRecords(AuditFilter{})
+	s.Len(records, 1)
+	s.Equal(500, records[0].Status)
+	s.False(records[0].Success)
+	s.Empty(records[0].Diff)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsAuditRecords_WhenUrlIsAudit() {
+	dir, _ := ioutil.TempDir("", "audit")
+	defer func() {
+		os.RemoveAll(dir)
+		auditPath = ""
+	}()
+	auditPath = dir + "/audit.jsonl"
+	writeAuditRecord(AuditRecord{Endpoint: "reconfigure", ServiceName: "service-1"})
+	writeAuditRecord(AuditRecord{Endpoint: "reconfigure", ServiceName: "service-2"})
+	expected, _ := json.Marshal(AuditResponse{Status: "OK", Records: []AuditRecord{{Endpoint: "reconfigure", ServiceName: "service-2"}}})
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/audit?service=service-2", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	s.Equal(string(expected), actual)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenAuditTimeIsNotValid() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/audit?from=yesterday", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+// ServeHTTP > Events
 
-	s.Equal(s.ServiceName, actualCertName)
-	s.Equal(strings.Replace(expectedCert, "\\n", "\n", -1), actualCert)
+func (s *ServerTestSuite) Test_ServeHTTP_StreamsEvents_WhenUrlIsEvents() {
+	ts := httptest.NewServer(&Serve{})
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/docker-flow-proxy/events")
+	s.NoError(err)
+	defer resp.Body.Close()
+	events.Publish(events.Event{Type: events.TypeReconfigure, ServiceName: "my-service", Success: true})
+
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+	id, _ := reader.ReadString('\n')
+	eventType, _ := reader.ReadString('\n')
+	data, _ := reader.ReadString('\n')
+	s.True(strings.HasPrefix(id, "id: "))
+	s.Equal("event: reconfigure\n", eventType)
+	actual := events.Event{}
+	json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &actual)
+	s.Equal("my-service", actual.ServiceName)
+	s.True(actual.Success)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReplaysEventsAfterLastEventID_WhenUrlIsEvents() {
+	ts := httptest.NewServer(&Serve{})
+	defer ts.Close()
+	events.Publish(events.Event{Type: events.TypeRemove, ServiceName: "service-1"})
+	last := events.Subscribe(0)
+	events.Publish(events.Event{Type: events.TypeRemove, ServiceName: "service-2"})
+	lastID := (<-last).ID
+	events.Unsubscribe(last)
+	req, _ := http.NewRequest("GET", ts.URL+"/v1/docker-flow-proxy/events", nil)
+	req.Header.Set("Last-Event-ID", fmt.Sprintf("%d", lastID-1))
+
+	resp, err := http.DefaultClient.Do(req)
+	s.NoError(err)
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	id, _ := reader.ReadString('\n')
+	s.Equal(fmt.Sprintf("id: %d\n", lastID), id)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesPutCertWithDomainName_WhenServiceCertIsPresent() {
-	actualCertName := ""
-	expectedCert := "my-cert"
-	actualCert := ""
-	certOrig := cert
-	defer func() { cert = certOrig }()
-	cert = CertMock{
-		PutCertMock: func(certName string, certContent []byte) (string, error) {
-			actualCertName = certName
-			actualCert = string(certContent[:])
-			return "", nil
-		},
+func (s *ServerTestSuite) Test_ServeHTTP_PublishesEvent_WhenReconfigureIsExecuted() {
+	ch := events.Subscribe(0)
+	defer events.Unsubscribe(ch)
+	getConfigHashOrig := getConfigHash
+	defer func() { getConfigHash = getConfigHashOrig }()
+	getConfigHash = func() string {
+		return "my-hash"
+	}
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
 	}
-	address := fmt.Sprintf("%s&serviceDomain=%s&serviceCert=%s", s.ReconfigureUrl, s.ServiceDomain[0], expectedCert)
-	req, _ := http.NewRequest("GET", address, nil)
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
 
-	s.Equal(s.ServiceDomain[0], actualCertName)
-	s.Equal(expectedCert, actualCert)
+	actual := <-ch
+	s.Equal(events.TypeReconfigure, actual.Type)
+	s.Equal(s.ServiceName, actual.ServiceName)
+	s.Equal("my-hash", actual.ConfigHash)
+	s.True(actual.Success)
 }
 
-// ServeHTTP > Remove
-
-func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToJSON_WhenUrlIsRemove() {
-	var actual string
-	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
-		actual = value
+func (s *ServerTestSuite) Test_ServeHTTP_PublishesFailureEvent_WhenReconfigureFails() {
+	ch := events.Subscribe(0)
+	defer events.Unsubscribe(ch)
+	mockObj := getReconfigureMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("This is an error"))
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
+		return mockObj
 	}
-	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
 
 	srv := Serve{}
-	srv.ServeHTTP(s.ResponseWriter, req)
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
 
-	s.Equal("application/json", actual)
+	actual := <-ch
+	s.Equal(events.TypeReconfigure, actual.Type)
+	s.False(actual.Success)
+	s.Equal("This is an error", actual.Message)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsJSON_WhenUrlIsRemove() {
-	expected, _ := json.Marshal(Response{
-		Status:      "OK",
-		ServiceName: s.ServiceName,
-	})
+func (s *ServerTestSuite) Test_ServeHTTP_PublishesEvent_WhenRemoveIsExecuted() {
+	ch := events.Subscribe(0)
+	defer events.Unsubscribe(ch)
+	mockObj := getRemoveMock("")
+	NewRemove = func(serviceName, aclName, cfgsPath, templatesPath string, consulAddress []string, instanceName, mode string) Removable {
+		return mockObj
+	}
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, s.RequestRemove)
 
+	actual := <-ch
+	s.Equal(events.TypeRemove, actual.Type)
+	s.Equal(s.ServiceName, actual.ServiceName)
+	s.True(actual.Success)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_PublishesDistributeEvent_WhenDistributionFails() {
+	ch := events.Subscribe(0)
+	defer events.Unsubscribe(ch)
+	serve := Serve{}
+	serve.Port = s.Port
+	addr := fmt.Sprintf("http://127.0.0.1:8080%s&distribute=true&returnError=true", s.ReconfigureUrl)
+	req, _ := http.NewRequest("GET", addr, nil)
+
+	serve.ServeHTTP(s.ResponseWriter, req)
+
+	actual := <-ch
+	s.Equal(events.TypeDistribute, actual.Type)
+	s.Equal(s.ServiceName, actual.ServiceName)
+	s.False(actual.Success)
+}
+
+// ServeHTTP > Servers
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsServerState_WhenUrlIsServers() {
+	state := haproxy.ServerState{Backend: "my-service-be", Server: "my-service", State: "ready", Weight: 1}
+	mockObj := getProxyMock("GetServerState")
+	mockObj.On("GetServerState", "my-service-be", "my-service").Return(state, nil)
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	expected, _ := json.Marshal(ServerResponse{Status: "OK", ServerState: state})
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/servers/my-service-be/my-service", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
 	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	mockObj.AssertNotCalled(s.T(), "Reload")
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsRemoveAndServiceNameQueryIsNotPresent() {
-	req, _ := http.NewRequest("GET", s.RemoveBaseUrl, nil)
+func (s *ServerTestSuite) Test_ServeHTTP_SetsServerStateAndWeight_WhenServersMethodIsPut() {
+	mockObj := getProxyMock("")
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/servers/my-service-be/my-service?state=drain&weight=10", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+	mockObj.AssertCalled(s.T(), "SetServerState", "my-service-be", "my-service", "drain")
+	mockObj.AssertCalled(s.T(), "SetServerWeight", "my-service-be", "my-service", 10)
+	mockObj.AssertNotCalled(s.T(), "Reload")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServersPutHasNoStateNorWeight() {
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/servers/my-service-be/my-service", nil)
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
@@ -786,83 +2067,287 @@ func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenUrlIsRemoveAndServ
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveExecute() {
-	mockObj := getRemoveMock("")
-	aclName := "my-acl"
-	var actual Remove
-	expected := Remove{
-		ServiceName:     s.ServiceName,
-		TemplatesPath:   "",
-		ConfigsPath:     "",
-		ConsulAddresses: []string{s.ConsulAddress},
-		InstanceName:    s.InstanceName,
-		AclName:         aclName,
-	}
-	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
-		actual = Remove{
-			ServiceName:     serviceName,
-			AclName:         aclName,
-			TemplatesPath:   templatesPath,
-			ConfigsPath:     configsPath,
-			ConsulAddresses: consulAddresses,
-			InstanceName:    instanceName,
-			Mode:            mode,
-		}
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServersWeightIsNotANumber() {
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/servers/my-service-be/my-service?weight=heavy", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus400_WhenServersPathIsNotComplete() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/servers/my-service-be", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 400)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenSetServerStateFails() {
+	mockObj := getProxyMock("SetServerState")
+	mockObj.On("SetServerState", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("This is an error"))
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/servers/my-service-be/my-service?state=maint", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
+}
+
+// ServeHTTP > Auth
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus401_WhenAuthTokensAreSetAndCredentialsAreMissing() {
+	defer func() { authTokens = nil }()
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}}}
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 401)
+	s.ResponseWriter.AssertNotCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenReconfigureServiceDoesNotMatchTokenPrefixes() {
+	defer func() { authTokens = nil }()
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"other-"}}}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 403)
+	s.ResponseWriter.AssertNotCalled(s.T(), "WriteHeader", 200)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigure_WhenServiceMatchesTokenPrefixes() {
+	mockObj := getReconfigureMock("")
+	reconfigureOrig := actions.NewReconfigure
+	defer func() {
+		actions.NewReconfigure = reconfigureOrig
+		authTokens = nil
+	}()
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData actions.ServiceReconfigure) actions.Reconfigurable {
 		return mockObj
 	}
-	url := fmt.Sprintf("%s?serviceName=%s&aclName=%s", s.RemoveBaseUrl, s.ServiceName, aclName)
-	req, _ := http.NewRequest("GET", url, nil)
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"other-", s.ServiceName}}}
+	req, _ := http.NewRequest("GET", s.ReconfigureUrl, nil)
+	req.Header.Set("Authorization", "Bearer my-token")
 
-	serverImpl.ServeHTTP(s.ResponseWriter, req)
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.Equal(expected, actual)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
 	mockObj.AssertCalled(s.T(), "Execute", []string{})
 }
 
-// ServeHTTP > Config
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenRemoveServiceDoesNotMatchTokenPrefixes() {
+	defer func() { authTokens = nil }()
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"other-"}}}
+	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+	req.Header.Set("Authorization", "Bearer my-token")
 
-func (s *ServerTestSuite) Test_ServeHTTP_SetsContentTypeToText_WhenUrlIsConfig() {
-	var actual string
-	httpWriterSetContentType = func(w http.ResponseWriter, value string) {
-		actual = value
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 403)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenCertNameDoesNotMatchTokenPrefixes() {
+	invoked := false
+	certOrig := cert
+	defer func() {
+		cert = certOrig
+		authTokens = nil
+	}()
+	cert = CertMock{
+		PutMock: func(http.ResponseWriter, *http.Request) (string, actions.ConfigChange, error) {
+			invoked = true
+			return "", actions.ConfigChange{}, nil
+		},
 	}
-	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_CERTS}, ServicePrefixes: []string{"other-"}}}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-service.pem", strings.NewReader("cert content"))
+	req.Header.Set("Authorization", "Bearer my-token")
 
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.Equal("text/html", actual)
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 403)
+	s.False(invoked)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsConfig_WhenUrlIsConfig() {
-	expected := "some text"
-	readFileOrig := haproxy.ReadFile
-	defer func() { haproxy.ReadFile = readFileOrig }()
-	haproxy.ReadFile = func(filename string) ([]byte, error) {
-		return []byte(expected), nil
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesCertPut_WhenCertNameMatchesTokenPrefixes() {
+	invoked := false
+	certOrig := cert
+	defer func() {
+		cert = certOrig
+		authTokens = nil
+	}()
+	cert = CertMock{
+		PutMock: func(http.ResponseWriter, *http.Request) (string, actions.ConfigChange, error) {
+			invoked = true
+			return "", actions.ConfigChange{}, nil
+		},
 	}
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_CERTS}, ServicePrefixes: []string{"my-"}}}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/cert?certName=my-service.pem", strings.NewReader("cert content"))
+	req.Header.Set("Authorization", "Bearer my-token")
 
-	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
-	s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
+	s.True(invoked)
 }
 
-func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenReadFileFails() {
-	readFileOrig := readFile
-	defer func() { readFile = readFileOrig }()
-	readFile = func(filename string) ([]byte, error) {
-		return []byte(""), fmt.Errorf("This is an error")
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus403_WhenServersBackendDoesNotMatchTokenPrefixes() {
+	mockObj := getProxyMock("")
+	proxyOrig := haproxy.Instance
+	defer func() {
+		haproxy.Instance = proxyOrig
+		authTokens = nil
+	}()
+	haproxy.Instance = mockObj
+	authTokens = []AuthToken{{Name: "deployer", Token: "my-token", Scopes: []string{SCOPE_RECONFIGURE}, ServicePrefixes: []string{"other-"}}}
+	req, _ := http.NewRequest("PUT", "/v1/docker-flow-proxy/servers/my-service-be/my-service?state=drain", nil)
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 403)
+	mockObj.AssertNotCalled(s.T(), "SetServerState", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// ServeHTTP > Test
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsProcessState_WhenUrlIsTest() {
+	state := haproxy.ProcessState{Running: true, Pid: 123, Uptime: 60}
+	mockObj := getProxyMock("GetProcessState")
+	mockObj.On("GetProcessState").Return(state)
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	expected, _ := json.Marshal(HealthResponse{Status: "OK", ProcessState: state})
+
+	for _, path := range []string{"/v1/test", "/v2/test"} {
+		req, _ := http.NewRequest("GET", path, nil)
+
+		srv := Serve{}
+		srv.ServeHTTP(s.ResponseWriter, req)
+
+		s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 200)
+		s.ResponseWriter.AssertCalled(s.T(), "Write", []byte(expected))
 	}
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsStatus500_WhenUrlIsTestAndHaProxyIsNotRunning() {
+	mockObj := getProxyMock("GetProcessState")
+	mockObj.On("GetProcessState").Return(haproxy.ProcessState{Running: false, LastExitStatus: "exit status 1"})
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	req, _ := http.NewRequest("GET", "/v1/test", nil)
 
-	req, _ := http.NewRequest("GET", s.ConfigUrl, nil)
 	srv := Serve{}
 	srv.ServeHTTP(s.ResponseWriter, req)
 
 	s.ResponseWriter.AssertCalled(s.T(), "WriteHeader", 500)
 }
 
+// ServeHTTP > Metrics
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsMetrics_WhenUrlIsMetrics() {
+	certContent, _ := ioutil.ReadFile("certs/xip.io.pem")
+	mockObj := getProxyMock("GetCerts")
+	mockObj.On("GetCerts").Return(map[string]string{"xip.io.pem": string(certContent), "invalid.pem": "not a cert"})
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	rw.AssertCalled(s.T(), "WriteHeader", 200)
+	s.Contains(actual, "docker_flow_proxy_reload_duration_seconds_count")
+	s.Contains(actual, "docker_flow_proxy_services ")
+	s.Contains(actual, `docker_flow_proxy_certificate_expiry_timestamp_seconds{cert="xip.io.pem"} 1510269808`)
+	s.NotContains(actual, `cert="invalid.pem"`)
+	s.Contains(actual, "haproxy_up 1")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_ReturnsHaProxyDown_WhenStatsCannotBeRead() {
+	mockObj := getProxyMock("GetStats")
+	mockObj.On("GetStats").Return([]haproxy.Stats{}, fmt.Errorf("This is an error"))
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = mockObj
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+
+	s.Contains(actual, "haproxy_up 0")
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_CountsReconfigureRequestsByOutcome() {
+	proxyOrig := haproxy.Instance
+	defer func() { haproxy.Instance = proxyOrig }()
+	haproxy.Instance = getProxyMock("")
+	srv := Serve{}
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/reconfigure", nil)
+	srv.ServeHTTP(s.ResponseWriter, req)
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+
+	srv.ServeHTTP(rw, req)
+
+	s.Contains(actual, `docker_flow_proxy_requests_total{endpoint="reconfigure",outcome="success"}`)
+	s.Contains(actual, `docker_flow_proxy_requests_total{endpoint="reconfigure",outcome="rejected"}`)
+}
+
+func (s *ServerTestSuite) Test_ServeHTTP_CountsFailedRemoveRequestsAsErrors() {
+	mockObj := getRemoveMock("Execute")
+	mockObj.On("Execute", mock.Anything).Return(fmt.Errorf("The configuration is not valid"))
+	NewRemove = func(serviceName, aclName, configsPath, templatesPath string, consulAddresses []string, instanceName, mode string) Removable {
+		return mockObj
+	}
+	srv := Serve{}
+	before := s.getRequestsTotal(`docker_flow_proxy_requests_total{endpoint="remove",outcome="error"}`)
+	req, _ := http.NewRequest("GET", s.RemoveUrl, nil)
+
+	srv.ServeHTTP(s.ResponseWriter, req)
+
+	s.Equal(before+1, s.getRequestsTotal(`docker_flow_proxy_requests_total{endpoint="remove",outcome="error"}`))
+}
+
+func (s *ServerTestSuite) getRequestsTotal(series string) int {
+	var actual string
+	rw := getResponseWriterMockWithBody(&actual)
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	srv := Serve{}
+	srv.ServeHTTP(rw, req)
+	for _, line := range strings.Split(actual, "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			total, _ := strconv.Atoi(strings.TrimPrefix(line, series+" "))
+			return total
+		}
+	}
+	return 0
+}
+
 // Suite
 
 func TestServerUnitTestSuite(t *testing.T) {
@@ -954,14 +2439,24 @@ func getResponseWriterMock() *ResponseWriterMock {
 	return mockObj
 }
 
+func getResponseWriterMockWithBody(body *string) *ResponseWriterMock {
+	mockObj := new(ResponseWriterMock)
+	mockObj.On("Header").Return(nil)
+	mockObj.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		*body = string(args.Get(0).([]byte))
+	}).Return(0, nil)
+	mockObj.On("WriteHeader", mock.Anything)
+	return mockObj
+}
+
 type CertMock struct {
-	PutMock     func(http.ResponseWriter, *http.Request) (string, error)
+	PutMock     func(http.ResponseWriter, *http.Request) (string, actions.ConfigChange, error)
 	PutCertMock func(certName string, certContent []byte) (string, error)
 	GetAllMock  func(w http.ResponseWriter, req *http.Request) (server.CertResponse, error)
-	GetInitMock func() error
+	GetInitMock func(authorization string) error
 }
 
-func (m CertMock) Put(w http.ResponseWriter, req *http.Request) (string, error) {
+func (m CertMock) Put(w http.ResponseWriter, req *http.Request) (string, actions.ConfigChange, error) {
 	return m.PutMock(w, req)
 }
 
@@ -973,8 +2468,8 @@ func (m CertMock) GetAll(w http.ResponseWriter, req *http.Request) (server.CertR
 	return m.GetAllMock(w, req)
 }
 
-func (m CertMock) Init() error {
-	return m.GetInitMock()
+func (m CertMock) Init(authorization string) error {
+	return m.GetInitMock(authorization)
 }
 
 type RunMock struct {
@@ -1018,6 +2513,31 @@ func (m *ReconfigureMock) GetTemplates(sr *actions.ServiceReconfigure) (front, b
 	return params.String(0), params.String(1), params.Error(2)
 }
 
+func (m *ReconfigureMock) GetDiff() (string, error) {
+	params := m.Called()
+	return params.String(0), params.Error(1)
+}
+
+func (m *ReconfigureMock) IsReloaded() bool {
+	params := m.Called()
+	return params.Bool(0)
+}
+
+func (m *ReconfigureMock) GetConfigChange() actions.ConfigChange {
+	params := m.Called()
+	return params.Get(0).(actions.ConfigChange)
+}
+
+func (m *ReconfigureMock) IsRuntime() bool {
+	params := m.Called()
+	return params.Bool(0)
+}
+
+func (m *ReconfigureMock) GetColorWeights() []actions.ColorWeight {
+	params := m.Called()
+	return params.Get(0).([]actions.ColorWeight)
+}
+
 func getReconfigureMock(skipMethod string) *ReconfigureMock {
 	mockObj := new(ReconfigureMock)
 	if skipMethod != "Execute" {
@@ -1032,6 +2552,21 @@ func getReconfigureMock(skipMethod string) *ReconfigureMock {
 	if skipMethod != "GetTemplates" {
 		mockObj.On("GetTemplates", mock.Anything).Return("", "", nil)
 	}
+	if skipMethod != "GetDiff" {
+		mockObj.On("GetDiff").Return("", nil)
+	}
+	if skipMethod != "IsReloaded" {
+		mockObj.On("IsReloaded").Return(false)
+	}
+	if skipMethod != "GetConfigChange" {
+		mockObj.On("GetConfigChange").Return(actions.ConfigChange{})
+	}
+	if skipMethod != "IsRuntime" {
+		mockObj.On("IsRuntime").Return(false)
+	}
+	if skipMethod != "GetColorWeights" {
+		mockObj.On("GetColorWeights").Return([]actions.ColorWeight{})
+	}
 	return mockObj
 }
 
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"./actions"
+	"./events"
+	"./proxy"
+)
+
+// shutdownGracePeriod is the time the requests in flight and the open HAProxy connections are given to finish on SIGTERM
+var shutdownGracePeriod = 10 * time.Second
+var netListen = net.Listen
+var signalNotify = signal.Notify
+var signalStop = signal.Stop
+var stopProxy = proxy.Stop
+var recreateConfig = actions.RecreateConfig
+
+// drainHandler tracks the requests in flight so that the server can wait for them before it exits.
+// Requests received after the draining started are rejected.
+type drainHandler struct {
+	handler  http.Handler
+	mu       sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+}
+
+func (h *drainHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mu.Lock()
+	if h.draining {
+		h.mu.Unlock()
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	h.inFlight.Add(1)
+	h.mu.Unlock()
+	defer h.inFlight.Done()
+	h.handler.ServeHTTP(w, req)
+}
+
+// drain rejects new requests and waits until the requests in flight finish.
+// It returns false if they did not finish within the timeout.
+func (h *drainHandler) drain(timeout time.Duration) bool {
+	h.mu.Lock()
+	h.draining = true
+	h.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		h.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// listenAndServeGracefully serves the API until SIGTERM or SIGINT is received. The server then stops accepting
+// requests, waits for those in flight, and soft-stops HAProxy. SIGHUP recreates the proxy configuration.
+func listenAndServeGracefully(addr string, handler http.Handler) error {
+	listener, err := netListen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	drain := &drainHandler{handler: handler}
+	srv := &http.Server{Handler: drain}
+	signals := make(chan os.Signal, 1)
+	signalNotify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signalStop(signals)
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(listener)
+	}()
+	for {
+		select {
+		case err := <-served:
+			return err
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				logPrintf("Received %s. Recreating the configuration", sig)
+				if _, err := recreateConfig(); err != nil {
+					logPrintf("Could not recreate the configuration\n%s", err.Error())
+				}
+				continue
+			}
+			return shutdown(sig, listener, srv, drain)
+		}
+	}
+}
+
+func shutdown(sig os.Signal, listener net.Listener, srv *http.Server, drain *drainHandler) error {
+	logPrintf("Received %s. Shutting down within %s", sig, shutdownGracePeriod)
+	deadline := time.Now().Add(shutdownGracePeriod)
+	srv.SetKeepAlivesEnabled(false)
+	listener.Close()
+	events.UnsubscribeAll()
+	if !drain.drain(shutdownGracePeriod) {
+		logPrintf("The requests in flight did not finish within %s", shutdownGracePeriod)
+	}
+	remaining := deadline.Sub(time.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	if err := stopProxy(remaining); err != nil {
+		return err
+	}
+	logPrintf(`"Docker Flow: Proxy" stopped`)
+	return nil
+}
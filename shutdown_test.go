@@ -0,0 +1,171 @@
+// +build !integration
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ShutdownTestSuite struct {
+	suite.Suite
+	signals      chan chan<- os.Signal
+	addresses    chan string
+	stopped      chan time.Duration
+	gracePeriod  time.Duration
+	netListen    func(network, address string) (net.Listener, error)
+	signalNotify func(c chan<- os.Signal, sig ...os.Signal)
+	signalStop   func(c chan<- os.Signal)
+	stopProxy    func(grace time.Duration) error
+	recreate     func() (bool, error)
+}
+
+func (s *ShutdownTestSuite) SetupTest() {
+	s.netListen, s.signalNotify, s.signalStop = netListen, signalNotify, signalStop
+	s.stopProxy, s.recreate, s.gracePeriod = stopProxy, recreateConfig, shutdownGracePeriod
+	s.signals = make(chan chan<- os.Signal, 1)
+	s.addresses = make(chan string, 1)
+	s.stopped = make(chan time.Duration, 1)
+	netListen = func(network, address string) (net.Listener, error) {
+		listener, err := net.Listen(network, "127.0.0.1:0")
+		if err == nil {
+			s.addresses <- listener.Addr().String()
+		}
+		return listener, err
+	}
+	signalNotify = func(c chan<- os.Signal, sig ...os.Signal) {
+		s.signals <- c
+	}
+	signalStop = func(c chan<- os.Signal) {}
+	stopProxy = func(grace time.Duration) error {
+		s.stopped <- grace
+		return nil
+	}
+	shutdownGracePeriod = time.Second
+}
+
+func (s *ShutdownTestSuite) TearDownTest() {
+	netListen, signalNotify, signalStop = s.netListen, s.signalNotify, s.signalStop
+	stopProxy, recreateConfig, shutdownGracePeriod = s.stopProxy, s.recreate, s.gracePeriod
+}
+
+// listenAndServeGracefully
+
+func (s *ShutdownTestSuite) Test_ListenAndServeGracefully_WaitsForRequestsInFlight_WhenSigtermIsReceived() {
+	started := make(chan bool)
+	release := make(chan bool)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		started <- true
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	result := make(chan error)
+	go func() { result <- listenAndServeGracefully("0.0.0.0:8080", handler) }()
+	address, signals := <-s.addresses, <-s.signals
+	responses := make(chan int)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/v1/docker-flow-proxy/reconfigure", address))
+		if err != nil {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+	<-started
+
+	signals <- syscall.SIGTERM
+	time.Sleep(10 * time.Millisecond)
+	s.Len(s.stopped, 0)
+	close(release)
+
+	s.Equal(http.StatusOK, <-responses)
+	s.NoError(<-result)
+	s.True(<-s.stopped > 0)
+	_, err := net.Dial("tcp", address)
+	s.Error(err)
+}
+
+func (s *ShutdownTestSuite) Test_ListenAndServeGracefully_RecreatesConfig_WhenSighupIsReceived() {
+	recreated := make(chan bool, 1)
+	recreateConfig = func() (bool, error) {
+		recreated <- true
+		return true, nil
+	}
+	result := make(chan error)
+	go func() { result <- listenAndServeGracefully("0.0.0.0:8080", http.NotFoundHandler()) }()
+	<-s.addresses
+	signals := <-s.signals
+
+	signals <- syscall.SIGHUP
+
+	s.True(<-recreated)
+	s.Len(s.stopped, 0)
+	signals <- syscall.SIGTERM
+	s.NoError(<-result)
+}
+
+func (s *ShutdownTestSuite) Test_ListenAndServeGracefully_ReturnsError_WhenListenFails() {
+	netListen = func(network, address string) (net.Listener, error) {
+		return nil, fmt.Errorf("This is an error")
+	}
+
+	err := listenAndServeGracefully("0.0.0.0:8080", http.NotFoundHandler())
+
+	s.Error(err)
+}
+
+func (s *ShutdownTestSuite) Test_ListenAndServeGracefully_ReturnsError_WhenProxyCannotBeStopped() {
+	stopProxy = func(grace time.Duration) error {
+		return fmt.Errorf("This is an error")
+	}
+	result := make(chan error)
+	go func() { result <- listenAndServeGracefully("0.0.0.0:8080", http.NotFoundHandler()) }()
+	<-s.addresses
+	signals := <-s.signals
+
+	signals <- syscall.SIGTERM
+
+	s.Error(<-result)
+}
+
+// drainHandler
+
+func (s *ShutdownTestSuite) Test_DrainHandler_RejectsRequests_WhenDraining() {
+	served := false
+	handler := &drainHandler{handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		served = true
+	})}
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-proxy/reconfigure", nil)
+	rec := httptest.NewRecorder()
+
+	s.True(handler.drain(time.Second))
+	handler.ServeHTTP(rec, req)
+
+	s.False(served)
+	s.Equal(http.StatusServiceUnavailable, rec.Code)
+	s.Equal("close", rec.Header().Get("Connection"))
+}
+
+func (s *ShutdownTestSuite) Test_DrainHandler_ReturnsFalse_WhenRequestsDoNotFinishWithinTimeout() {
+	handler := &drainHandler{}
+	handler.inFlight.Add(1)
+	defer handler.inFlight.Done()
+
+	s.False(handler.drain(time.Millisecond))
+}
+
+// Suite
+
+func TestShutdownUnitTestSuite(t *testing.T) {
+	logPrintf = func(format string, v ...interface{}) {}
+	suite.Run(t, new(ShutdownTestSuite))
+}
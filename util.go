@@ -1,14 +1,15 @@
 package main
 
 import (
+	"./proxy"
 	"./registry"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 )
 
 var readTemplateFile = ioutil.ReadFile
@@ -16,12 +17,14 @@ var readFile = ioutil.ReadFile
 var writeFeTemplate = ioutil.WriteFile
 var writeBeTemplate = ioutil.WriteFile
 var osRemove = os.Remove
-var httpListenAndServe = http.ListenAndServe
+var httpListenAndServe = listenAndServeGracefully
 var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
 	w.Header().Set("Content-Type", value)
 }
 var httpGet = http.Get
 var logPrintf = log.Printf
+var getConfigHash = proxy.GetConfigHash
+var stdout io.Writer = os.Stdout
 
 type Executable interface {
 	Execute(args []string) error
@@ -32,5 +35,28 @@ func isSwarm(mode string) bool {
 }
 
 var lookupHost = net.LookupHost
-var mu = &sync.Mutex{}
 var registryInstance registry.Registrarable = registry.Consul{}
+
+// statusRecorder remembers the status code written to the response so that requests can be counted by outcome
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) getOutcome() string {
+	if r.status >= 500 {
+		return "error"
+	} else if r.status >= 400 {
+		return "rejected"
+	}
+	return "success"
+}